@@ -5,6 +5,7 @@ import 'package:shared_preferences/shared_preferences.dart';
 class HttpService {
   static String baseUrl = "https://replace-me.cpolar.top";
   static String? _token; 
+  static String? _refreshToken; // 访问令牌过期后用它换发新令牌
 
   static Future<void> initBaseUrl() async {
     final prefs = await SharedPreferences.getInstance();
@@ -29,9 +30,7 @@ class HttpService {
         );
         if (response.statusCode == 200) {
           final data = jsonDecode(response.body);
-          _token = data['token'];
-          final prefs = await SharedPreferences.getInstance();
-          await prefs.setString('token', _token!);
+          await _saveTokens(data);
           return true;
         }
       } catch (e) {}
@@ -47,15 +46,76 @@ class HttpService {
 
   static Future<bool> tryAutoLogin() async {
     final prefs = await SharedPreferences.getInstance();
-    if (prefs.containsKey('token')) { _token = prefs.getString('token'); return true; }
+    if (prefs.containsKey('token')) {
+      _token = prefs.getString('token');
+      _refreshToken = prefs.getString('refresh_token');
+      return true;
+    }
     return false;
   }
-  static Future<void> logout() async { _token = null; final prefs = await SharedPreferences.getInstance(); await prefs.remove('token'); }
+
+  static Future<void> logout() async {
+    // 通知服务端吊销令牌，失败不影响本地退出
+    if (_token != null) {
+      try {
+        await http.post(
+          Uri.parse('$baseUrl/logout'),
+          headers: {'Authorization': _token!},
+          body: jsonEncode({'refresh_token': _refreshToken ?? ''}),
+        );
+      } catch (e) {}
+    }
+    _token = null;
+    _refreshToken = null;
+    final prefs = await SharedPreferences.getInstance();
+    await prefs.remove('token');
+    await prefs.remove('refresh_token');
+  }
+
+  static Future<void> _saveTokens(Map<String, dynamic> data) async {
+    _token = data['token'];
+    _refreshToken = data['refresh_token'];
+    final prefs = await SharedPreferences.getInstance();
+    await prefs.setString('token', _token!);
+    if (_refreshToken != null) await prefs.setString('refresh_token', _refreshToken!);
+  }
+
+  // 正在进行的换发，多个请求同时遇到 401 时共用同一次换发
+  static Future<bool>? _refreshing;
+
+  // 访问令牌过期 (401) 时用刷新令牌换发一次，成功后重发原请求
+  static Future<http.Response> _authed(Future<http.Response> Function() send) async {
+    final sentWith = _token;
+    final response = await send();
+    if (response.statusCode != 401 || _refreshToken == null) return response;
+    // 等待期间其他请求已经换发过，直接用新令牌重发
+    if (_token != sentWith) return send();
+    if (!await _refresh()) return response;
+    return send();
+  }
+
+  static Future<bool> _refresh() {
+    return _refreshing ??= _doRefresh().whenComplete(() => _refreshing = null);
+  }
+
+  static Future<bool> _doRefresh() async {
+    try {
+      final refreshed = await http.post(
+        Uri.parse('$baseUrl/refresh'),
+        body: jsonEncode({'refresh_token': _refreshToken}),
+      );
+      if (refreshed.statusCode != 200) return false;
+      await _saveTokens(jsonDecode(refreshed.body));
+      return true;
+    } catch (e) {
+      return false;
+    }
+  }
 
   static Future<Map<String, dynamic>?> getMyData() async {
     if (_token == null) return null;
     try {
-      final response = await http.get(Uri.parse('$baseUrl/my_data'), headers: {'Authorization': _token!});
+      final response = await _authed(() => http.get(Uri.parse('$baseUrl/my_data'), headers: {'Authorization': _token!}));
       if (response.statusCode == 200) return jsonDecode(utf8.decode(response.bodyBytes));
     } catch (e) {}
     return null;
@@ -64,7 +124,7 @@ class HttpService {
   static Future<List<dynamic>> refreshMarketData() async {
     if (_token == null) return [];
     try {
-      final response = await http.get(Uri.parse('$baseUrl/refresh_market'), headers: {'Authorization': _token!});
+      final response = await _authed(() => http.get(Uri.parse('$baseUrl/refresh_market'), headers: {'Authorization': _token!}));
       if (response.statusCode == 200) {
         final json = jsonDecode(utf8.decode(response.bodyBytes));
         return json['data'] as List<dynamic>;
@@ -76,11 +136,11 @@ class HttpService {
   static Future<bool> addFundDB(String code, String type, double amount) async {
     if (_token == null) return false;
     try {
-      final response = await http.post(
+      final response = await _authed(() => http.post(
         Uri.parse('$baseUrl/add'),
         headers: {'Authorization': _token!},
         body: jsonEncode({'code': code, 'type': type, 'amount': amount}),
-      );
+      ));
       return response.statusCode == 200;
     } catch (e) { return false; }
   }
@@ -88,11 +148,11 @@ class HttpService {
   static Future<bool> deleteFund(String code, String type) async {
     if (_token == null) return false;
     try {
-      final response = await http.post(
+      final response = await _authed(() => http.post(
         Uri.parse('$baseUrl/delete'),
         headers: {'Authorization': _token!},
         body: jsonEncode({'code': code, 'type': type}),
-      );
+      ));
       return response.statusCode == 200;
     } catch (e) { return false; }
   }
@@ -108,7 +168,7 @@ class HttpService {
   static Future<List<dynamic>> searchFund(String keyword) async {
     if (_token == null) return [];
     try {
-      final response = await http.get(Uri.parse('$baseUrl/search?key=$keyword'), headers: {'Authorization': _token!});
+      final response = await _authed(() => http.get(Uri.parse('$baseUrl/search?key=$keyword'), headers: {'Authorization': _token!}));
       if (response.statusCode == 200) {
         final json = jsonDecode(utf8.decode(response.bodyBytes));
         return json['data'] as List<dynamic>;
@@ -121,10 +181,10 @@ class HttpService {
   static Future<String> settleHoldings() async {
     if (_token == null) return "未登录";
     try {
-      final response = await http.post(
+      final response = await _authed(() => http.post(
         Uri.parse('$baseUrl/settle'),
         headers: {'Authorization': _token!},
-      );
+      ));
       if (response.statusCode == 200) {
         final data = jsonDecode(utf8.decode(response.bodyBytes));
         return data['message']; 
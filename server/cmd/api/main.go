@@ -1,17 +1,44 @@
 ﻿package main
 
 import (
+	"expvar"
+	"flag"
 	"fmt"
 	"fund-tracker-server/internal/api"
+	"fund-tracker-server/internal/config"
 	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/jobs"
+	"fund-tracker-server/internal/notify"
+	"fund-tracker-server/internal/ratelimit"
+	"fund-tracker-server/internal/repo"
 	"fund-tracker-server/internal/service"
+	"fund-tracker-server/internal/ws"
+	"log"
+	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Cors() gin.HandlerFunc {
+// Cors 允许配置中的来源跨域访问，["*"] 表示任意来源
+func Cors(origins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := c.GetHeader("Origin")
+		if allowAll {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else if allowed[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
@@ -24,13 +51,72 @@ func Cors() gin.HandlerFunc {
 	}
 }
 
+// limiter 按配置的额度创建限流器
+func limiter(b config.Budget) *ratelimit.Limiter {
+	return ratelimit.New(b.Requests, b.Per.Std())
+}
+
 func main() {
-	db.InitDB()
-	r := gin.Default()
-	r.Use(Cors())
+	configPath := flag.String("config", os.Getenv("FUND_CONFIG"), "配置文件路径 (默认读取当前目录的 config.json)")
+	flag.Parse()
 
-	r.POST("/register", api.Register)
-	r.POST("/login", api.Login)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("❌ 配置无效:\n", err)
+	}
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		runMigrate(cfg, args[1:])
+		return
+	}
+
+	api.SetJWT(cfg.JWT.Secret, cfg.JWT.AccessTTL.Std(), cfg.JWT.RefreshTTL.Std())
+	service.SetUpstream(cfg.Upstream)
+	if err := service.SetProviderChain(cfg.Fetch.Providers...); err != nil {
+		log.Fatal("❌ fetch.providers 无效: ", err)
+	}
+	service.HTTPTimeout = cfg.Fetch.Timeout.Std()
+	service.FetchConcurrency = cfg.Fetch.Concurrency
+	service.QuoteTTLTrading = cfg.Fetch.CacheTTLTrading.Std()
+	service.QuoteTTLClosed = cfg.Fetch.CacheTTLClosed.Std()
+	rl := cfg.RateLimit
+	api.SetLoginLockout(rl.LockoutThreshold, rl.LockoutBase.Std(), rl.LockoutMax.Std())
+	api.SetAccountLockout(rl.AccountLockoutThreshold, rl.LockoutBase.Std(), rl.LockoutMax.Std())
+
+	db.InitDB(cfg.DB.Driver, cfg.DB.DSN)
+	go jobs.StartEstimateCapture()
+	go jobs.StartTokenCleanup()
+	store := repo.NewGormStore(db.DB)
+	nc := cfg.Notify
+	dispatcher := notify.NewDispatcher(store.NotifyChannels, notify.Options{
+		SMTP:           nc.SMTP,
+		WebhookTimeout: nc.WebhookTimeout.Std(),
+		AllowPrivate:   nc.AllowPrivateWebhooks,
+		Attempts:       nc.RetryAttempts,
+		RetryBase:      nc.RetryBase.Std(),
+		RetryMax:       nc.RetryMax.Std(),
+	})
+	api.SetNotifier(dispatcher)
+	jobs.SetNotifier(dispatcher)
+
+	jobs.DigestConcurrency = nc.DigestConcurrency
+	// 历史净值同步 -> 估值对比 -> 持仓快照 -> 每日简报，需在通知渠道设置之后启动
+	go jobs.StartNightlyJobs()
+	go jobs.StartAlertEvaluator(cfg.Alerts.Interval.Std())
+	go ws.StartQuotePoller(cfg.Fetch.PollInterval.Std())
+	h := api.NewHandler(store)
+	// 不用 gin.Default()：默认日志会记下 WebSocket 握手 URL 中的 ?token=
+	r := gin.New()
+	r.Use(api.RequestLogger(), gin.Recovery())
+	if len(rl.TrustedProxies) > 0 {
+		if err := r.SetTrustedProxies(rl.TrustedProxies); err != nil {
+			log.Fatal("❌ rate_limit.trusted_proxies 无效: ", err)
+		}
+	}
+	r.Use(Cors(cfg.CORS.AllowOrigins))
+
+	r.POST("/register", api.RateLimitByIP("register", limiter(rl.Register)), h.Register)
+	r.POST("/login", api.RateLimitByIP("login", limiter(rl.Login)), h.Login)
+	r.POST("/refresh", api.RateLimitByIP("refresh", limiter(rl.Refresh)), h.Refresh)
 	r.GET("/detail", func(c *gin.Context) {
 		code := c.Query("code")
 		detail, err := service.FetchFundDetail(code)
@@ -41,18 +127,75 @@ func main() {
 		c.JSON(200, detail)
 	})
 
+	// 会发出测试消息或确认邮件的接口共用一个额度
+	notifyTest := api.RateLimitByUser("notify_test", limiter(rl.NotifyTest))
 	auth := r.Group("/")
-	auth.Use(api.AuthMiddleware())
+	auth.Use(h.AuthMiddleware())
 	{
-		auth.GET("/my_data", api.GetMyData)
-		auth.POST("/add", api.AddFundDB)
-		auth.POST("/delete", api.DeleteFundDB)
-		auth.GET("/refresh_market", api.RefreshMarketDB)
-		auth.GET("/search", api.SearchFundDB)
+		auth.POST("/logout", h.Logout)
+		auth.POST("/logout_all", h.LogoutAll)
+		auth.GET("/account", h.GetAccount)
+		auth.POST("/account/profile", h.UpdateProfile)
+		auth.POST("/account/password", h.ChangePassword)
+		auth.GET("/account/export", h.ExportAccount)
+		auth.POST("/account/delete", h.DeleteAccount)
+		auth.GET("/my_data", h.GetMyData)
+		auth.GET("/portfolios", h.ListPortfolios)
+		auth.POST("/portfolios", h.CreatePortfolio)
+		auth.POST("/portfolios/update", h.UpdatePortfolio)
+		auth.POST("/portfolios/delete", h.DeletePortfolio)
+		auth.GET("/watchlist", h.GetWatchlist)
+		auth.POST("/watchlist/update", h.UpdateWatchlistItem)
+		auth.POST("/watchlist/reorder", h.ReorderWatchlist)
+		auth.POST("/watchlist/groups", h.CreateWatchlistGroup)
+		auth.POST("/watchlist/groups/update", h.UpdateWatchlistGroup)
+		auth.POST("/watchlist/groups/delete", h.DeleteWatchlistGroup)
+		auth.POST("/watchlist/groups/reorder", h.ReorderWatchlistGroups)
+		auth.GET("/alerts", h.ListAlerts)
+		auth.POST("/alerts", h.CreateAlert)
+		auth.POST("/alerts/update", h.UpdateAlert)
+		auth.POST("/alerts/delete", h.DeleteAlert)
+		auth.GET("/alerts/triggers", h.ListAlertTriggers)
+		auth.GET("/notify/channels", h.ListNotifyChannels)
+		auth.POST("/notify/channels", notifyTest, h.CreateNotifyChannel)
+		auth.POST("/notify/channels/update", h.UpdateNotifyChannel)
+		auth.POST("/notify/channels/delete", h.DeleteNotifyChannel)
+		auth.POST("/notify/channels/test", notifyTest, h.TestNotifyChannel)
+		auth.POST("/notify/channels/verify", notifyTest, h.VerifyNotifyChannel)
+		auth.POST("/notify/channels/verify/send", notifyTest, h.SendNotifyVerifyCode)
+		auth.POST("/add", h.AddFundDB)
+		auth.POST("/delete", h.DeleteFundDB)
+		auth.POST("/sell", h.SellFundDB)
+		auth.GET("/refresh_market", h.RefreshMarketDB)
+		auth.GET("/search", api.RateLimitByUser("search", limiter(rl.Search)), api.SearchFundDB)
+		auth.GET("/history", api.RateLimitByUser("history", limiter(rl.History)), h.GetHistory)
+		auth.GET("/equity", h.GetEquityCurve)
+		auth.GET("/digest", h.GetDigest)
+		auth.GET("/estimates", h.GetEstimates)
+		auth.GET("/estimates/accuracy", h.GetEstimateAccuracy)
+		auth.GET("/ws", ws.WsHandler)
+		auth.GET("/transactions", h.ListTransactions)
+		auth.POST("/transactions", h.CreateTransaction)
+		auth.POST("/transactions/update", h.UpdateTransaction)
+		auth.POST("/transactions/delete", h.DeleteTransaction)
 		// 🔥 已删除: auth.POST("/settle", api.SettleHoldingsDB)
 		// 因为现在逻辑是实时计算收益，不需要手动结算接口了
 	}
 
-	fmt.Println("🚀 服务端已启动: http://localhost:8080")
-	r.Run(":8080")
+	// 运行指标 (行情缓存命中率等) 只在单独的内部地址提供，不对外暴露
+	if cfg.DebugListen != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/debug/vars", expvar.Handler())
+			fmt.Println("📊 运行指标监听", cfg.DebugListen)
+			if err := http.ListenAndServe(cfg.DebugListen, mux); err != nil {
+				log.Fatal("❌ debug_listen 启动失败: ", err)
+			}
+		}()
+	}
+
+	fmt.Println("🚀 服务端已启动，监听", cfg.Listen)
+	if err := r.Run(cfg.Listen); err != nil {
+		log.Fatal("❌ 服务启动失败: ", err)
+	}
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/config"
+	"fund-tracker-server/internal/db"
+	"log"
+	"strconv"
+)
+
+const migrateUsage = `用法: api [-config path] migrate <命令>
+  status     列出所有迁移及执行状态
+  up         执行全部未执行的迁移
+  down       回滚最近一次迁移
+  to <版本>  升级或回滚到指定版本 (0 表示全部回滚)`
+
+// runMigrate 处理 migrate 子命令
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	db.Connect(cfg.DB.Driver, cfg.DB.DSN)
+
+	var err error
+	switch args[0] {
+	case "status":
+		var states []db.MigrationState
+		states, err = db.MigrationStatus(db.DB)
+		for _, s := range states {
+			applied := "未执行"
+			if s.Applied {
+				applied = "已执行 " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-36s %s\n", s.Version, s.Name, applied)
+		}
+	case "up":
+		err = db.MigrateUp(db.DB)
+	case "down":
+		err = db.MigrateDown(db.DB)
+	case "to":
+		if len(args) < 2 {
+			log.Fatal(migrateUsage)
+		}
+		var version int
+		version, err = strconv.Atoi(args[1])
+		if err == nil {
+			err = db.MigrateTo(db.DB, version)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	current, err := db.CurrentVersion(db.DB)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	fmt.Printf("当前版本: %d (最新 %d)\n", current, db.LatestVersion())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"fund-tracker-server/internal/fakenotify"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 独立运行的替身通知服务，用于本地联调 webhook 和邮件通知:
+//
+//	go run ./cmd/fakenotify -http :9091 -smtp 127.0.0.1:2525 -secret <webhook 密钥>
+//
+// 服务端需设置 notify.allow_private_webhooks=true 才能向本机发送 webhook
+func main() {
+	httpAddr := flag.String("http", ":9091", "webhook 接收地址")
+	smtpAddr := flag.String("smtp", "127.0.0.1:2525", "SMTP 监听地址")
+	secret := flag.String("secret", "", "用于校验签名的 webhook 密钥，留空不校验")
+	fail := flag.Int("fail", 0, "前 n 个 webhook 请求返回 503，用于验证重试")
+	flag.Parse()
+
+	smtpServer, err := fakenotify.StartSMTP(*smtpAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sink := &fakenotify.Sink{Secret: *secret}
+	for i := 0; i < *fail; i++ {
+		sink.FailNext(http.StatusServiceUnavailable)
+	}
+
+	// 定时打印新收到的消息
+	go func() {
+		var hooks, mails int
+		for range time.Tick(500 * time.Millisecond) {
+			for _, h := range sink.Hooks()[hooks:] {
+				fmt.Printf("🪝 webhook %s 状态 %d 签名正确 %v\n%s\n", h.Header.Get("X-Fund-Event"), h.Status, h.Verified, h.Body)
+				hooks++
+			}
+			for _, m := range smtpServer.Mails()[mails:] {
+				fmt.Printf("📧 邮件 %s -> %v\n%s\n", m.From, m.To, m.Data)
+				mails++
+			}
+		}
+	}()
+
+	fmt.Printf("🧪 替身通知服务已启动: webhook http://localhost%s  SMTP %s\n", *httpAddr, smtpServer.Addr)
+	log.Fatal(http.ListenAndServe(*httpAddr, sink))
+}
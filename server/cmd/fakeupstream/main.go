@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"fund-tracker-server/internal/fakeupstream"
+	"log"
+	"net/http"
+	"os"
+)
+
+// 独立运行的替身上游服务，用于本地联调:
+//
+//	go run ./cmd/fakeupstream -addr :9090 -fixtures ./my_fixtures
+func main() {
+	addr := flag.String("addr", ":9090", "监听地址")
+	dir := flag.String("fixtures", "", "fixtures 目录，留空使用内置数据")
+	flag.Parse()
+
+	fixtures := fakeupstream.Fixtures()
+	if *dir != "" {
+		fixtures = os.DirFS(*dir)
+	}
+
+	fmt.Println("🧪 替身上游已启动: http://localhost" + *addr)
+	log.Fatal(http.ListenAndServe(*addr, fakeupstream.Handler(fixtures)))
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 支持的本位币
+var baseCurrencies = map[string]bool{"CNY": true, "HKD": true, "USD": true, "EUR": true, "GBP": true, "JPY": true}
+
+// 昵称最大长度 (字符数)
+const maxDisplayName = 32
+
+// currentUser 读取当前登录用户，失败时写入错误响应并返回 nil
+func (h *Handler) currentUser(c *gin.Context) *models.User {
+	user, err := h.store.Users.FindByID(c.MustGet("user_id").(uint))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil
+	}
+	return user
+}
+
+// 账户资料
+func (h *Handler) GetAccount(c *gin.Context) {
+	user := h.currentUser(c)
+	if user == nil {
+		return
+	}
+	c.JSON(200, gin.H{
+		"username":      user.Username,
+		"display_name":  user.DisplayName,
+		"base_currency": user.BaseCurrency,
+		"created_at":    user.CreatedAt,
+	})
+}
+
+// 修改昵称和本位币，未传的字段保持不变
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	var input struct {
+		DisplayName  *string `json:"display_name"`
+		BaseCurrency *string `json:"base_currency"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	user := h.currentUser(c)
+	if user == nil {
+		return
+	}
+	fe := fieldErrors{}
+	if input.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*input.DisplayName)
+		fe.check(utf8.RuneCountInString(user.DisplayName) <= maxDisplayName,
+			"display_name", fmt.Sprintf("昵称最多 %d 个字符", maxDisplayName))
+	}
+	if input.BaseCurrency != nil {
+		user.BaseCurrency = strings.ToUpper(strings.TrimSpace(*input.BaseCurrency))
+		fe.check(baseCurrencies[user.BaseCurrency], "base_currency", "不支持的本位币: "+*input.BaseCurrency)
+	}
+	if respondInvalid(c, fe) {
+		return
+	}
+	if err := h.store.Users.UpdateProfile(user.ID, user.DisplayName, user.BaseCurrency); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"success":       true,
+		"display_name":  user.DisplayName,
+		"base_currency": user.BaseCurrency,
+	})
+}
+
+// 修改密码：校验旧密码，其他设备全部下线，当前设备返回新的令牌
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var input struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	user := h.currentUser(c)
+	if user == nil {
+		return
+	}
+	fe := fieldErrors{}
+	checkPassword(fe, "new_password", input.NewPassword, user.Username)
+	if respondInvalid(c, fe) {
+		return
+	}
+	if !verifyPassword(c, user, input.OldPassword, "旧密码错误") {
+		return
+	}
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.store.Atomic(func(s repo.Store) error {
+		if err := s.Users.UpdatePassword(user.ID, string(hashedPwd)); err != nil {
+			return err
+		}
+		if err := s.Users.BumpTokenVersion(user.ID); err != nil {
+			return err
+		}
+		return s.Tokens.RevokeAllRefresh(user.ID)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	user.TokenVersion++
+	h.respondTokens(c, user, randomID())
+}
+
+// 导出账户的全部数据 (JSON 附件)
+func (h *Handler) ExportAccount(c *gin.Context) {
+	user := h.currentUser(c)
+	if user == nil {
+		return
+	}
+	portfolios, err := h.store.Portfolios.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	holdings, err := h.store.Holdings.ListByUser(user.ID, 0)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	watchlist, err := h.store.Watchlist.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	groups, err := h.store.WatchlistGroups.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	txs, err := h.store.Transactions.List(user.ID, 0, "")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	snapshots, err := h.store.Snapshots.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	alerts, err := h.store.Alerts.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	triggers, err := h.store.Alerts.Triggers(user.ID, 0, 0)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	channels, err := h.store.NotifyChannels.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	filename := fmt.Sprintf("fund-tracker-%s-%s.json", user.Username, now.Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.JSON(200, gin.H{
+		"exported_at": now,
+		"account": gin.H{
+			"username":      user.Username,
+			"display_name":  user.DisplayName,
+			"base_currency": user.BaseCurrency,
+			"created_at":    user.CreatedAt,
+		},
+		"portfolios":       portfolios,
+		"holdings":         holdings,
+		"watchlist":        watchlist,
+		"watchlist_groups": groups,
+		"transactions":     txs,
+		"snapshots":        snapshots,
+		"alerts":           alerts,
+		"alert_triggers":   triggers,
+		"notify_channels":  channels,
+	})
+}
+
+// 注销账户：校验密码后删除用户及其组合、持仓、自选 (含分组)、流水、快照、提醒、通知渠道和令牌
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	var input struct {
+		Password string `json:"password"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	user := h.currentUser(c)
+	if user == nil {
+		return
+	}
+	if !verifyPassword(c, user, input.Password, "密码错误") {
+		return
+	}
+
+	err := h.store.Atomic(func(s repo.Store) error {
+		for _, del := range []func(uint) error{
+			s.Transactions.DeleteByUser,
+			s.Holdings.DeleteByUser,
+			s.Watchlist.DeleteByUser,
+			s.WatchlistGroups.DeleteByUser,
+			s.Snapshots.DeleteByUser,
+			s.Alerts.DeleteByUser,
+			s.NotifyChannels.DeleteByUser,
+			s.Portfolios.DeleteByUser,
+			s.Tokens.DeleteByUser,
+		} {
+			if err := del(user.ID); err != nil {
+				return err
+			}
+		}
+		return s.Users.Delete(user.ID)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// verifyPassword 校验已登录用户的当前密码，与登录共用失败锁定。
+// 按用户计数，令牌泄露时无法通过更换 IP 暴力猜测密码；校验失败时已写入响应并返回 false
+func verifyPassword(c *gin.Context, user *models.User, password, msg string) bool {
+	key := fmt.Sprintf("account:%d", user.ID)
+	if wait := loginLockout.Locked(key); wait > 0 {
+		rateStats.Add("locked_account", 1)
+		abortTooMany(c, wait, "密码错误次数过多")
+		return false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		if loginLockout.Fail(key) > 0 {
+			rateStats.Add("lockouts", 1)
+		}
+		c.JSON(401, gin.H{"error": msg})
+		return false
+	}
+	loginLockout.Reset(key)
+	return true
+}
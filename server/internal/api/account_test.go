@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccountPasswordLockout(t *testing.T) {
+	t.Cleanup(func() { SetLoginLockout(5, time.Minute, time.Hour) })
+	for _, route := range []struct {
+		path  string
+		wrong gin.H
+		right gin.H
+	}{
+		{"/account/password", gin.H{"old_password": "wrong", "new_password": "another-pass-43"}, gin.H{"old_password": testPassword, "new_password": "another-pass-43"}},
+		{"/account/delete", gin.H{"password": "wrong"}, gin.H{"password": testPassword}},
+	} {
+		t.Run(route.path, func(t *testing.T) {
+			// 每个子测试的内存库里用户 ID 相同，锁定状态不能共用
+			SetLoginLockout(3, time.Minute, time.Hour)
+			e := newTestEnv(t)
+			for i := 0; i < 3; i++ {
+				if status, _ := e.post(route.path, route.wrong); status != 401 {
+					t.Fatalf("attempt %d: %d, want 401", i+1, status)
+				}
+			}
+			// 锁定期间正确的密码也被拒绝
+			if status, _ := e.post(route.path, route.right); status != 429 {
+				t.Fatalf("after lockout: %d, want 429", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 提醒的数量和备注限制
+const (
+	maxAlerts         = 100
+	maxAlertNote      = 200
+	defaultTriggerNum = 50
+	maxTriggerNum     = 200
+)
+
+// 支持的提醒类型
+var alertKinds = map[string]bool{
+	models.AlertPriceAbove:   true,
+	models.AlertPriceBelow:   true,
+	models.AlertChange:       true,
+	models.AlertPremium:      true,
+	models.AlertReturnTarget: true,
+}
+
+// 提醒请求体，修改时未传的字段保持不变
+type alertInput struct {
+	ID          uint     `json:"id"`
+	FundCode    *string  `json:"fund_code"`
+	Kind        *string  `json:"kind"`
+	Threshold   *float64 `json:"threshold"`
+	PortfolioID *uint    `json:"portfolio_id"` // 只用于 return_target
+	Note        *string  `json:"note"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// conditionChanged 是否修改了触发条件，修改后需要重新判断是否已触发
+func (in alertInput) conditionChanged() bool {
+	return in.FundCode != nil || in.Kind != nil || in.Threshold != nil || in.PortfolioID != nil
+}
+
+// apply 把请求写入 a 并校验
+func (in alertInput) apply(a *models.Alert) fieldErrors {
+	fe := fieldErrors{}
+	if in.FundCode != nil {
+		a.FundCode = strings.TrimSpace(*in.FundCode)
+	}
+	if in.Kind != nil {
+		a.Kind = *in.Kind
+	}
+	if in.Threshold != nil {
+		a.Threshold = *in.Threshold
+	}
+	if in.PortfolioID != nil {
+		a.PortfolioID = *in.PortfolioID
+	}
+	if in.Note != nil {
+		a.Note = strings.TrimSpace(*in.Note)
+	}
+	if in.Enabled != nil {
+		a.Enabled = *in.Enabled
+	}
+	if a.Kind != models.AlertReturnTarget {
+		a.PortfolioID = 0
+	}
+
+	fe.check(fundCodePattern.MatchString(a.FundCode), "fund_code", "基金代码应为 6 位数字")
+	fe.check(alertKinds[a.Kind], "kind", "不支持的提醒类型: "+a.Kind)
+	// 收益率目标可以是任意值 (负数为止损)，其他类型的阈值必须为正
+	fe.check(a.Kind == models.AlertReturnTarget || a.Threshold > 0, "threshold", "阈值必须大于 0")
+	fe.check(utf8.RuneCountInString(a.Note) <= maxAlertNote, "note", fmt.Sprintf("备注最多 %d 个字符", maxAlertNote))
+	return fe
+}
+
+// findAlert 查询当前用户的提醒，不存在时写入 404 并返回 false
+func (h *Handler) findAlert(c *gin.Context, userID, id uint) (*models.Alert, bool) {
+	alert, err := h.store.Alerts.Find(userID, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "提醒不存在"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return alert, true
+}
+
+// checkAlert 校验请求并确认基金代码有效、组合属于当前用户
+func (h *Handler) checkAlert(c *gin.Context, userID uint, input alertInput, alert *models.Alert) bool {
+	if respondInvalid(c, input.apply(alert)) {
+		return false
+	}
+	if alert.PortfolioID != 0 {
+		if _, ok := h.findPortfolio(c, userID, alert.PortfolioID); !ok {
+			return false
+		}
+	}
+	if input.FundCode != nil {
+		if _, err := service.FetchQuote(alert.FundCode); err != nil {
+			c.JSON(400, gin.H{"error": "无效的基金代码"})
+			return false
+		}
+	}
+	return true
+}
+
+// 提醒列表
+func (h *Handler) ListAlerts(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	alerts, err := h.store.Alerts.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"data": alerts})
+}
+
+// 新建提醒
+func (h *Handler) CreateAlert(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input alertInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	alerts, err := h.store.Alerts.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(alerts) >= maxAlerts {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("最多设置 %d 个提醒", maxAlerts)})
+		return
+	}
+	alert := models.Alert{UserID: userID, Enabled: true}
+	if !h.checkAlert(c, userID, input, &alert) {
+		return
+	}
+	if err := h.store.Alerts.Create(&alert); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": alert})
+}
+
+// 修改提醒，修改触发条件后重新开始判断
+func (h *Handler) UpdateAlert(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input alertInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	alert, ok := h.findAlert(c, userID, input.ID)
+	if !ok || !h.checkAlert(c, userID, input, alert) {
+		return
+	}
+	if input.conditionChanged() {
+		alert.Active = false
+	}
+	if err := h.store.Alerts.Save(alert); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": alert})
+}
+
+// 删除提醒及其触发记录
+func (h *Handler) DeleteAlert(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	if _, ok := h.findAlert(c, userID, input.ID); !ok {
+		return
+	}
+	if err := h.store.Alerts.Delete(userID, input.ID); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// 触发记录 (?alert_id= 只看一个提醒，?limit= 默认 50，最多 200)，新的在前
+func (h *Handler) ListAlertTriggers(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var alertID uint
+	if raw := c.Query("alert_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "alert_id 不合法"})
+			return
+		}
+		if _, ok := h.findAlert(c, userID, uint(id)); !ok {
+			return
+		}
+		alertID = uint(id)
+	}
+	limit := defaultTriggerNum
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxTriggerNum {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("limit 应在 1-%d 之间", maxTriggerNum)})
+			return
+		}
+		limit = n
+	}
+	triggers, err := h.store.Alerts.Triggers(userID, alertID, limit)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"data": triggers})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateAlertResetsActiveOnConditionChange(t *testing.T) {
+	e := newTestEnv(t)
+	status, resp := e.post("/alerts", gin.H{"fund_code": "110022", "kind": "price_above", "threshold": 1.5})
+	if status != 200 {
+		t.Fatalf("create: %d %v", status, resp)
+	}
+	id := uint(resp["data"].(map[string]interface{})["id"].(float64))
+
+	active := func() bool {
+		t.Helper()
+		a, err := e.store.Alerts.Find(e.userID, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.Active
+	}
+
+	for _, tc := range []struct {
+		update gin.H
+		reset  bool
+	}{
+		{gin.H{"note": "只改备注"}, false},
+		{gin.H{"enabled": true}, false},
+		{gin.H{"threshold": 1.6}, true},
+		{gin.H{"kind": "price_below"}, true},
+		{gin.H{"fund_code": "161725"}, true},
+	} {
+		if err := e.store.Alerts.SetActive(id, true, nil); err != nil {
+			t.Fatal(err)
+		}
+		tc.update["id"] = id
+		if status, resp := e.post("/alerts/update", tc.update); status != 200 {
+			t.Fatalf("update %v: %d %v", tc.update, status, resp)
+		}
+		if got := active(); got == tc.reset {
+			t.Fatalf("update %v: active = %v, want %v", tc.update, got, !tc.reset)
+		}
+	}
+}
+
+func TestCreateDisabledAlert(t *testing.T) {
+	e := newTestEnv(t)
+	status, resp := e.post("/alerts", gin.H{"fund_code": "110022", "kind": "change", "threshold": 3, "enabled": false})
+	if status != 200 {
+		t.Fatalf("create: %d %v", status, resp)
+	}
+	id := uint(resp["data"].(map[string]interface{})["id"].(float64))
+	a, err := e.store.Alerts.Find(e.userID, id)
+	if err != nil || a.Enabled {
+		t.Fatalf("alert = %+v, %v, want disabled", a, err)
+	}
+}
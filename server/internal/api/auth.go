@@ -0,0 +1,402 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// JWT 密钥和有效期，启动时由 SetJWT 从配置设置
+var (
+	jwtSecret  []byte
+	accessTTL  = 15 * time.Minute
+	refreshTTL = 30 * 24 * time.Hour
+)
+
+// SetJWT 设置签发和校验令牌使用的密钥，以及访问令牌、刷新令牌的有效期
+func SetJWT(secret string, access, refresh time.Duration) {
+	jwtSecret = []byte(secret)
+	accessTTL = access
+	refreshTTL = refresh
+}
+
+// 注册
+func (h *Handler) Register(c *gin.Context) {
+	var input credentialsInput
+	if !bindJSON(c, &input) || respondInvalid(c, input.validateRegister()) {
+		return
+	}
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	user := models.User{Username: input.Username, Password: string(hashedPwd)}
+	err = h.store.Atomic(func(s repo.Store) error {
+		if err := s.Users.Create(&user); err != nil {
+			return err
+		}
+		_, err := defaultPortfolio(s, user.ID)
+		return err
+	})
+	if errors.Is(err, repo.ErrDuplicate) {
+		c.JSON(409, gin.H{"error": "用户名已被注册", "fields": fieldErrors{"username": "用户名已被注册"}})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "注册失败"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "注册成功"})
+}
+
+// 登录，返回访问令牌 (token) 和刷新令牌 (refresh_token)
+func (h *Handler) Login(c *gin.Context) {
+	var input credentialsInput
+	if !bindJSON(c, &input) || respondInvalid(c, input.validateLogin()) {
+		return
+	}
+	key, accountKey := lockoutKey(c, input.Username), accountLockoutKey(input.Username)
+	if wait := max(loginLockout.Locked(key), accountLockout.Locked(accountKey)); wait > 0 {
+		rateStats.Add("locked_login", 1)
+		abortTooMany(c, wait, "登录失败次数过多")
+		return
+	}
+	// 用户不存在和密码错误返回相同的提示，避免被用来枚举用户名
+	fail := func() {
+		if loginLockout.Fail(key) > 0 {
+			rateStats.Add("lockouts", 1)
+		}
+		if accountLockout.Fail(accountKey) > 0 {
+			rateStats.Add("account_lockouts", 1)
+		}
+		c.JSON(401, gin.H{"error": "用户名或密码错误"})
+	}
+
+	user, err := h.store.Users.FindByUsername(input.Username)
+	if errors.Is(err, repo.ErrNotFound) {
+		// 同样做一次哈希比较，响应时间不暴露用户名是否存在
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(input.Password))
+		fail()
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		fail()
+		return
+	}
+	loginLockout.Reset(key)
+	accountLockout.Reset(accountKey)
+	h.respondTokens(c, user, randomID())
+}
+
+// dummyPasswordHash 用户不存在时用于比较的哈希，与注册使用相同的 cost
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(randomID()), bcrypt.DefaultCost)
+	return hash
+})
+
+// refreshGrace 刷新令牌换发后的宽限期：客户端多个请求同时遇到 401 时会用同一个
+// 刷新令牌各自换发，宽限期内再次出示时返回已换发的那一对令牌，不当作泄露处理
+const refreshGrace = 10 * time.Second
+
+// rotation 一次换发的结果，done 关闭后 body 可读，换发失败时 body 为 nil
+type rotation struct {
+	done    chan struct{}
+	body    gin.H
+	expires time.Time
+}
+
+// rotations 按旧刷新令牌的哈希记录宽限期内的换发结果 (只在本进程内有效)
+var rotations = struct {
+	sync.Mutex
+	m map[string]*rotation
+}{m: make(map[string]*rotation)}
+
+// claimRotation 返回 hash 正在进行或宽限期内的换发，没有时登记一个新的并返回 leader=true
+func claimRotation(hash string, now time.Time) (r *rotation, leader bool) {
+	rotations.Lock()
+	defer rotations.Unlock()
+	for k, old := range rotations.m {
+		if old.body != nil && now.After(old.expires) {
+			delete(rotations.m, k)
+		}
+	}
+	if r, ok := rotations.m[hash]; ok {
+		return r, false
+	}
+	r = &rotation{done: make(chan struct{})}
+	rotations.m[hash] = r
+	return r, true
+}
+
+// finish 记录换发结果并唤醒等待的请求，失败时 (body 为 nil) 撤销登记
+func (r *rotation) finish(hash string, body gin.H) {
+	rotations.Lock()
+	if body == nil {
+		delete(rotations.m, hash)
+	} else {
+		r.body = body
+		r.expires = time.Now().Add(refreshGrace)
+	}
+	rotations.Unlock()
+	close(r.done)
+}
+
+// 用刷新令牌换发新的访问令牌和刷新令牌，旧刷新令牌随即失效。
+// 已失效的刷新令牌在宽限期之后被再次使用说明可能已泄露，吊销该次登录的所有令牌
+func (h *Handler) Refresh(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.RefreshToken == "" {
+		c.JSON(400, gin.H{"error": "缺少 refresh_token"})
+		return
+	}
+	hash := hashToken(input.RefreshToken)
+	r, leader := claimRotation(hash, time.Now())
+	if !leader {
+		select {
+		case <-r.done:
+		case <-c.Request.Context().Done():
+			return
+		}
+		rotations.Lock()
+		body, fresh := r.body, time.Now().Before(r.expires)
+		rotations.Unlock()
+		if body != nil && fresh {
+			c.JSON(200, body)
+			return
+		}
+		// 换发失败或已过宽限期，按正常流程处理 (过期的旧令牌会被识别为重用)
+		r, leader = claimRotation(hash, time.Now())
+		if !leader {
+			c.JSON(401, gin.H{"error": "刷新令牌已失效，请重新登录"})
+			return
+		}
+	}
+	var body gin.H
+	defer func() { r.finish(hash, body) }()
+
+	stored, err := h.store.Tokens.FindRefresh(hash)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(401, gin.H{"error": "刷新令牌无效"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		c.JSON(401, gin.H{"error": "刷新令牌已过期，请重新登录"})
+		return
+	}
+
+	var user *models.User
+	reused := false
+	err = h.store.Atomic(func(s repo.Store) error {
+		ok, err := s.Tokens.RevokeRefresh(stored.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			reused = true
+			return s.Tokens.RevokeFamily(stored.UserID, stored.FamilyID)
+		}
+		user, err = s.Users.FindByID(stored.UserID)
+		return err
+	})
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(401, gin.H{"error": "用户不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if reused {
+		c.JSON(401, gin.H{"error": "刷新令牌已失效，请重新登录"})
+		return
+	}
+	body, err = h.issueTokens(user, stored.FamilyID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, body)
+}
+
+// 退出登录：吊销当前访问令牌，以及请求体中 refresh_token 所属的登录
+func (h *Handler) Logout(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = c.ShouldBindJSON(&input) // 请求体可选
+
+	err := h.store.Atomic(func(s repo.Store) error {
+		if err := s.Tokens.RevokeAccess(c.GetString("jti"), c.MustGet("token_exp").(time.Time)); err != nil {
+			return err
+		}
+		if input.RefreshToken == "" {
+			return nil
+		}
+		stored, err := s.Tokens.FindRefresh(hashToken(input.RefreshToken))
+		if errors.Is(err, repo.ErrNotFound) || (err == nil && stored.UserID != userID) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.Tokens.RevokeFamily(userID, stored.FamilyID)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// 退出所有设备：令牌版本加一并吊销全部刷新令牌
+func (h *Handler) LogoutAll(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	err := h.store.Atomic(func(s repo.Store) error {
+		if err := s.Users.BumpTokenVersion(userID); err != nil {
+			return err
+		}
+		return s.Tokens.RevokeAllRefresh(userID)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// respondTokens 签发一对令牌并写入响应
+func (h *Handler) respondTokens(c *gin.Context, user *models.User, familyID string) {
+	body, err := h.issueTokens(user, familyID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, body)
+}
+
+// issueTokens 签发访问令牌和属于 familyID 的新刷新令牌，token 字段保持与旧版客户端兼容
+func (h *Handler) issueTokens(user *models.User, familyID string) (gin.H, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"ver":     user.TokenVersion,
+		"jti":     randomID(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(accessTTL).Unix(),
+	})
+	tokenString, err := token.SignedString(jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := randomToken()
+	err = h.store.Tokens.CreateRefresh(&models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: now.Add(refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":         tokenString,
+		"refresh_token": refresh,
+		"expires_in":    int(accessTTL.Seconds()),
+		"username":      user.Username,
+	}, nil
+}
+
+// randomID 16 字节随机数的十六进制，用作 jti 和 FamilyID
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// randomToken 刷新令牌明文，只返回给客户端一次
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 中间件：校验签名和有效期，并检查令牌是否已被吊销
+func (h *Handler) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.GetHeader("Authorization")
+		// 浏览器的 WebSocket 无法设置请求头，握手时允许通过 ?token= 传递
+		if tokenString == "" && strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			tokenString = c.Query("token")
+		}
+		if tokenString == "" {
+			c.AbortWithStatusJSON(401, gin.H{"error": "未登录"})
+			return
+		}
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			return jwtSecret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Token 无效"})
+			return
+		}
+		userID, ok1 := claims["user_id"].(float64)
+		ver, ok2 := claims["ver"].(float64)
+		jti, ok3 := claims["jti"].(string)
+		exp, err := claims.GetExpirationTime()
+		if !ok1 || !ok2 || !ok3 || err != nil {
+			// 旧版本签发的令牌没有 jti/ver，需要重新登录
+			c.AbortWithStatusJSON(401, gin.H{"error": "Token 无效"})
+			return
+		}
+
+		revoked, err := h.store.Tokens.IsAccessRevoked(jti)
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		user, err := h.store.Users.FindByID(uint(userID))
+		if err != nil && !errors.Is(err, repo.ErrNotFound) {
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if revoked || err != nil || user.TokenVersion != int(ver) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Token 已失效"})
+			return
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("jti", jti)
+		c.Set("token_exp", exp.Time)
+		c.Next()
+	}
+}
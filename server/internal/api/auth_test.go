@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefreshGraceWindow(t *testing.T) {
+	e := newTestEnv(t)
+	SetJWT("test-secret", time.Minute, time.Hour)
+
+	status, resp := e.post("/login", gin.H{"username": "alice", "password": testPassword})
+	if status != 200 {
+		t.Fatalf("login: %d %v", status, resp)
+	}
+	old := resp["refresh_token"].(string)
+
+	// 多个请求同时用同一个刷新令牌换发，都拿到同一对新令牌
+	var wg sync.WaitGroup
+	results := make([]map[string]interface{}, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, resp := e.post("/refresh", gin.H{"refresh_token": old})
+			if status != 200 {
+				t.Errorf("concurrent refresh %d: %d %v", i, status, resp)
+			}
+			results[i] = resp
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+	rotated := results[0]["refresh_token"]
+	for _, r := range results[1:] {
+		if r["refresh_token"] != rotated || r["token"] != results[0]["token"] {
+			t.Fatalf("refresh returned different pairs: %v vs %v", r, results[0])
+		}
+	}
+
+	// 宽限期过后再出示旧令牌视为泄露，整个登录被吊销
+	rotations.Lock()
+	rotations.m[hashToken(old)].expires = time.Now().Add(-time.Second)
+	rotations.Unlock()
+	if status, resp := e.post("/refresh", gin.H{"refresh_token": old}); status != 401 {
+		t.Fatalf("reuse after grace: %d %v", status, resp)
+	}
+	if status, resp := e.post("/refresh", gin.H{"refresh_token": rotated}); status != 401 {
+		t.Fatalf("rotated token should be revoked with its family: %d %v", status, resp)
+	}
+}
+
+func TestLoginLockoutAcrossIPs(t *testing.T) {
+	t.Cleanup(func() {
+		SetLoginLockout(5, time.Minute, time.Hour)
+		SetAccountLockout(20, time.Minute, time.Hour)
+	})
+	SetLoginLockout(3, time.Minute, time.Hour)
+	SetAccountLockout(5, time.Minute, time.Hour)
+	e := newTestEnv(t)
+
+	login := func(ip, username, password string) (int, map[string]interface{}) {
+		data, _ := json.Marshal(gin.H{"username": username, "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(data))
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		e.router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	// 用户不存在和密码错误的提示相同
+	_, unknown := login("198.51.100.1", "mallory", "whatever-1")
+	_, wrong := login("198.51.100.1", "alice", "whatever-1")
+	if unknown["error"] != wrong["error"] {
+		t.Fatalf("unknown user %q vs wrong password %q", unknown["error"], wrong["error"])
+	}
+
+	// 每个 IP 只试两次，不触发按 IP 的锁定，但累计到账户级阈值后锁定
+	for i := 2; i <= 5; i++ {
+		ip := fmt.Sprintf("203.0.113.%d", i)
+		if status, _ := login(ip, "alice", "wrong"); status != 401 {
+			t.Fatalf("attempt from %s: %d, want 401", ip, status)
+		}
+	}
+	if status, _ := login("203.0.113.99", "alice", testPassword); status != 429 {
+		t.Fatalf("after account lockout: %d, want 429", status)
+	}
+	// 其他用户名不受影响
+	if status, _ := login("203.0.113.99", "bob", "wrong"); status != 401 {
+		t.Fatalf("other account: %d, want 401", status)
+	}
+}
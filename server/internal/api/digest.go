@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fund-tracker-server/internal/digest"
+	"fund-tracker-server/internal/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每日简报 (?date= 默认今天，?format=json|text|html 默认 json)
+func (h *Handler) GetDigest(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	now := time.Now().In(service.ChinaTZ)
+	date := c.DefaultQuery("date", now.Format("2006-01-02"))
+	day, err := time.ParseInLocation("2006-01-02", date, now.Location())
+	if err != nil {
+		c.JSON(400, gin.H{"error": "日期格式应为 2006-01-02"})
+		return
+	}
+	if day.After(now) {
+		c.JSON(400, gin.H{"error": "不能查询未来的日期"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "text" && format != "html" {
+		c.JSON(400, gin.H{"error": "format 只能是 json、text 或 html"})
+		return
+	}
+
+	d, err := digest.Build(h.store, userID, date, now)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	switch format {
+	case "text":
+		c.String(200, d.Text())
+	case "html":
+		page, err := d.HTML()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(200, "text/html; charset=utf-8", []byte(page))
+	default:
+		c.JSON(200, d)
+	}
+}
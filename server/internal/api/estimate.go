@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 估值准确度统计的默认和最长回看天数
+const (
+	defaultAccuracyDays = 90
+	maxAccuracyDays     = 365
+)
+
+// 一只基金每天的收盘估值及其与确认净值的偏差 (?code=&from=&to=，按日期升序)
+func (h *Handler) GetEstimates(c *gin.Context) {
+	code := c.Query("code")
+	if !fundCodePattern.MatchString(code) {
+		c.JSON(400, gin.H{"error": "基金代码应为 6 位数字"})
+		return
+	}
+	from, to, ok := dateRange(c)
+	if !ok {
+		return
+	}
+	rows, err := h.store.NavEstimates.Range(code, from, to)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"data": rows})
+}
+
+// 盘中估值的误差统计 (?code= 只看一只基金，默认为当前用户的持仓和自选；?days= 回看天数，默认 90，最多 365)
+func (h *Handler) GetEstimateAccuracy(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	days := defaultAccuracyDays
+	if raw := c.Query("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxAccuracyDays {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("days 应在 1-%d 之间", maxAccuracyDays)})
+			return
+		}
+		days = n
+	}
+
+	holdings, err := h.store.Holdings.ListByUser(userID, 0)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	watchlist, err := h.store.Watchlist.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	names := make(map[string]string)
+	for _, w := range watchlist {
+		names[w.FundCode] = w.FundName
+	}
+	for _, hd := range holdings {
+		names[hd.FundCode] = hd.FundName
+	}
+
+	codes := uniqueCodes(holdingCodes(holdings), watchCodes(watchlist))
+	if code := c.Query("code"); code != "" {
+		if !fundCodePattern.MatchString(code) {
+			c.JSON(400, gin.H{"error": "基金代码应为 6 位数字"})
+			return
+		}
+		codes = []string{code}
+	}
+
+	from := time.Now().In(service.ChinaTZ).AddDate(0, 0, -days).Format("2006-01-02")
+	rows, err := h.store.NavEstimates.Confirmed(codes, from)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	byCode := make(map[string][]models.NavEstimate)
+	for _, r := range rows {
+		byCode[r.FundCode] = append(byCode[r.FundCode], r)
+	}
+	stats := make([]service.EstimateAccuracy, 0, len(codes))
+	for _, code := range codes {
+		a := service.Accuracy(code, byCode[code])
+		a.FundName = names[code]
+		stats = append(stats, a)
+	}
+	c.JSON(200, gin.H{"data": stats, "from": from, "days": days})
+}
@@ -1,147 +1,220 @@
 package api
 
 import (
-	"fund-tracker-server/internal/db"
+	"errors"
+	"fund-tracker-server/internal/jobs"
 	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
 	"fund-tracker-server/internal/service"
-	"sync" // 🔥 引入 sync 包用于并发控制
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte("my_super_secret_key_2026")
+// Handler 需要访问数据库的接口，数据访问全部经由注入的 Store
+type Handler struct {
+	store repo.Store
+	// syncHistory 在后台回填新跟踪基金的历史净值，测试中可替换
+	syncHistory func(code string)
+	// backfilling 正在回填的基金代码，同一只基金同时只回填一次
+	backfilling sync.Map
+}
 
-// 注册
-func Register(c *gin.Context) {
-	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+// NewHandler 创建 Handler
+func NewHandler(store repo.Store) *Handler {
+	return &Handler{store: store, syncHistory: func(code string) { jobs.SyncNavHistory(code) }}
+}
+
+// backfill 在后台回填 code 的历史净值，已在回填中时忽略
+func (h *Handler) backfill(code string) {
+	if _, running := h.backfilling.LoadOrStore(code, true); running {
+		return
 	}
-	if err := c.ShouldBindJSON(&input); err != nil {
+	go func() {
+		defer h.backfilling.Delete(code)
+		h.syncHistory(code)
+	}()
+}
+
+// 获取数据 (?cost_method=average|fifo 选择成本结转方式，默认平均成本；
+// ?portfolio_id= 只看一个组合，不传为全部组合合并)
+func (h *Handler) GetMyData(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	method, err := service.ParseCostMethod(c.Query("cost_method"))
+	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	hashedPwd, _ := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-	user := models.User{Username: input.Username, Password: string(hashedPwd)}
-	if result := db.DB.Create(&user); result.Error != nil {
-		c.JSON(500, gin.H{"error": "注册失败"})
+	portfolioID, ok := h.portfolioQuery(c, userID)
+	if !ok {
 		return
 	}
-	c.JSON(200, gin.H{"message": "注册成功"})
-}
-
-// 登录
-func Login(c *gin.Context) {
-	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+	holdings, err := h.store.Holdings.ListByUser(userID, portfolioID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	watchlist, err := h.store.Watchlist.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	var user models.User
-	if err := db.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
-		c.JSON(401, gin.H{"error": "用户不存在"})
+	groups, err := h.store.WatchlistGroups.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(401, gin.H{"error": "密码错误"})
+
+	// Holding 中存的是平均成本法的投影，其他方法从流水重新计算
+	if method != service.CostAverage {
+		if err := h.projectHoldings(userID, portfolioID, holdings, method); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 用最新行情计算每个持仓的市值和收益，取不到行情的使用缓存价格
+	quotes := service.FetchQuotes(uniqueCodes(holdingCodes(holdings), watchCodes(watchlist)))
+	for i := range holdings {
+		service.ValueHolding(&holdings[i], quotes[holdings[i].FundCode])
+	}
+	quoteWatchlist(watchlist, quotes)
+	portfolios, combined, ok := h.portfolioViews(c, userID, portfolioID, holdings)
+	if !ok {
 		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
+
+	c.JSON(200, gin.H{
+		"holdings":            holdings,
+		"watchlist":           watchlist,
+		"watchlist_groups":    groups,
+		"summary":             combined.Summary, // 只含 summary_currency 币种的组合
+		"summary_currency":    combined.Currency,
+		"summary_by_currency": combined.ByCurrency,
+		"portfolios":          portfolios,
+		"portfolio_id":        portfolioID,
+		"cost_method":         method,
 	})
-	tokenString, _ := token.SignedString(jwtSecret)
-	c.JSON(200, gin.H{"token": tokenString, "username": user.Username})
 }
 
-// 获取数据
-func GetMyData(c *gin.Context) {
-	userID := c.MustGet("user_id").(uint)
-	var holdings []models.Holding
-	var watchlist []models.Watchlist
-
-	db.DB.Where("user_id = ?", userID).Find(&holdings)
-	db.DB.Where("user_id = ?", userID).Find(&watchlist)
-
-	// 这里可以复用 FetchFundData 逻辑来计算实时收益，
-	// 但为了代码简洁，主要逻辑在 RefreshMarketDB 或由前端触发刷新。
-	// 如果需要 GetMyData 也实时，请参考之前的重构逻辑。
+// holdingCodes 持仓中的基金代码 (多个组合持有同一只基金时只出现一次)
+func holdingCodes(holdings []models.Holding) []string {
+	codes := make([]string, 0, len(holdings))
+	for _, h := range holdings {
+		codes = append(codes, h.FundCode)
+	}
+	return uniqueCodes(codes)
+}
 
-	c.JSON(200, gin.H{"holdings": holdings, "watchlist": watchlist})
+// uniqueCodes 合并多组基金代码并去重，保持首次出现的顺序
+func uniqueCodes(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, list := range lists {
+		for _, code := range list {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
 }
 
 // 添加/更新
-func AddFundDB(c *gin.Context) {
+func (h *Handler) AddFundDB(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	var input struct {
-		Code      string  `json:"code"`
-		Type      string  `json:"type"`
-		Shares    float64 `json:"shares"`
-		CostPrice float64 `json:"cost_price"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	var input addFundInput
+	if !bindJSON(c, &input) || respondInvalid(c, input.validate()) {
 		return
 	}
 
-	fundInfo, err := service.FetchFundData(input.Code)
+	fundInfo, err := service.FetchQuote(input.Code)
 	if err != nil {
 		c.JSON(400, gin.H{"error": "无效的基金代码"})
 		return
 	}
 
 	if input.Type == "holding" {
-		var holding models.Holding
-		if err := db.DB.Where("user_id = ? AND fund_code = ?", userID, input.Code).First(&holding).Error; err == nil {
-			// 加权平均逻辑
-			totalShares := holding.Shares + input.Shares
-			if totalShares > 0 {
-				totalCost := (holding.Shares * holding.CostPrice) + (input.Shares * input.CostPrice)
-				holding.CostPrice = totalCost / totalShares
-				holding.Shares = totalShares
-			}
-			holding.FundName = fundInfo.Name
-			db.DB.Save(&holding)
-		} else {
-			db.DB.Create(&models.Holding{
-				UserID:    userID,
-				FundCode:  input.Code,
-				FundName:  fundInfo.Name,
-				Shares:    input.Shares,
-				CostPrice: input.CostPrice,
-			})
+		portfolioID, ok := h.resolvePortfolio(c, userID, input.PortfolioID)
+		if !ok {
+			return
+		}
+		// 买入记为一条流水，持仓由流水重放得出
+		date, _ := parseTradeDate(input.Date) // validate 已校验
+		record := models.Transaction{
+			UserID:      userID,
+			PortfolioID: portfolioID,
+			FundCode:    input.Code,
+			Type:        models.TxBuy,
+			TradeDate:   date,
+			Shares:      input.Shares,
+			Price:       input.CostPrice,
+			Fee:         input.Fee,
+		}
+		err = h.applyLedgerChange(userID, portfolioID, input.Code, fundInfo.Name, func(s repo.Store) error {
+			return s.Transactions.Create(&record)
+		}, &record)
+		if respondLedgerError(c, err) {
+			return
 		}
 	} else {
-		var count int64
-		db.DB.Model(&models.Watchlist{}).Where("user_id = ? AND fund_code = ?", userID, input.Code).Count(&count)
-		if count == 0 {
-			db.DB.Create(&models.Watchlist{UserID: userID, FundCode: input.Code})
+		if !h.checkWatchGroup(c, userID, input.GroupID) {
+			return
+		}
+		entry := models.Watchlist{
+			UserID:   userID,
+			FundCode: input.Code,
+			FundName: fundInfo.Name,
+			GroupID:  input.GroupID,
+			Note:     input.Note,
+			Tags:     input.Tags,
+		}
+		// 已在自选中时保持原样
+		if err := h.store.Watchlist.Add(&entry); err != nil && !errors.Is(err, repo.ErrDuplicate) {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
 		}
 	}
+
+	// 新跟踪的基金在后台回填历史净值
+	h.backfill(input.Code)
+
 	c.JSON(200, gin.H{"success": true})
 }
 
 // 删除
-func DeleteFundDB(c *gin.Context) {
+func (h *Handler) DeleteFundDB(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 	var input struct {
-		Code string `json:"code"`
-		Type string `json:"type"`
+		PortfolioID uint   `json:"portfolio_id"` // 0 为默认组合
+		Code        string `json:"code"`
+		Type        string `json:"type"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 	if input.Type == "holding" {
-		db.DB.Unscoped().Where("user_id = ? AND fund_code = ?", userID, input.Code).Delete(&models.Holding{})
-	} else {
-		db.DB.Unscoped().Where("user_id = ? AND fund_code = ?", userID, input.Code).Delete(&models.Watchlist{})
+		portfolioID, ok := h.resolvePortfolio(c, userID, input.PortfolioID)
+		if !ok {
+			return
+		}
+		err := h.store.Atomic(func(s repo.Store) error {
+			if err := s.Holdings.Delete(userID, portfolioID, input.Code); err != nil {
+				return err
+			}
+			return s.Transactions.DeleteByFund(userID, portfolioID, input.Code)
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		notifyPortfolioChanged(userID, input.Code)
+	} else if err := h.store.Watchlist.Delete(userID, input.Code); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
 	c.JSON(200, gin.H{"success": true})
 }
@@ -161,83 +234,141 @@ func SearchFundDB(c *gin.Context) {
 	c.JSON(200, gin.H{"data": results})
 }
 
-// 🔥 优化：刷新行情 (并发控制 + 统一返回)
-func RefreshMarketDB(c *gin.Context) {
-	userID := c.MustGet("user_id").(uint)
-	var holdingCodes []string
-	var watchCodes []string
+// 历史净值 (?code=&from=&to=，日期格式 2006-01-02，按日期升序)
+func (h *Handler) GetHistory(c *gin.Context) {
+	code := c.Query("code")
+	if !fundCodePattern.MatchString(code) {
+		c.JSON(400, gin.H{"error": "基金代码应为 6 位数字"})
+		return
+	}
+	from, to, ok := dateRange(c)
+	if !ok {
+		return
+	}
+	rows, err := h.store.NavHistory.Range(code, from, to)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 
-	// 获取用户关注的所有代码
-	db.DB.Model(&models.Holding{}).Where("user_id = ?", userID).Pluck("fund_code", &holdingCodes)
-	db.DB.Model(&models.Watchlist{}).Where("user_id = ?", userID).Pluck("fund_code", &watchCodes)
-
-	// 去重
-	uniqueMap := make(map[string]bool)
-	for _, code := range holdingCodes {
-		uniqueMap[code] = true
-	}
-	for _, code := range watchCodes {
-		uniqueMap[code] = true
-	}
-
-	// 准备并发控制
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 5)  // 🔥 限制最大并发数为 5
-	var results []*models.FundInfo // 存储刷新后的数据
-	var mu sync.Mutex              // 保护 results 切片的并发写入
-
-	for code := range uniqueMap {
-		wg.Add(1)
-		sem <- struct{}{} // 获取信号量 (如果满5个则阻塞)
-
-		go func(targetCode string) {
-			defer wg.Done()
-			defer func() { <-sem }() // 释放信号量
-
-			// 获取最新数据
-			data, err := service.FetchFundData(targetCode)
-			if err == nil && data != nil {
-				// 1. 收集结果
-				mu.Lock()
-				results = append(results, data)
-				mu.Unlock()
-
-				// 2. 更新数据库缓存 (LastPrice 等)
-				// 注意：这里仅更新 Holding 表的缓存字段，不影响 shares/cost
-				db.DB.Model(&models.Holding{}).
-					Where("user_id = ? AND fund_code = ?", userID, targetCode).
-					Updates(map[string]interface{}{
-						"fund_name":  data.Name,
-						"last_price": data.GSZ,
-						"change":     data.GSZZL,
-					})
+	// 被跟踪的基金还没有存量数据时在后台回填，客户端稍后重试即可；
+	// 没有人持有或自选的基金不回填，避免任意代码触发上游抓取
+	syncing := false
+	if len(rows) == 0 {
+		count, err := h.store.NavHistory.Count(code)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		tracked := false
+		if count == 0 {
+			if tracked, err = h.store.NavHistory.Tracked(code); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
 			}
-		}(code)
+		}
+		if tracked {
+			h.backfill(code)
+			syncing = true
+		}
 	}
+	c.JSON(200, gin.H{"data": rows, "syncing": syncing})
+}
 
-	// 等待所有任务完成
-	wg.Wait()
+// 资产曲线 (?from=&to=)，按日汇总持仓快照
+func (h *Handler) GetEquityCurve(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	from, to, ok := dateRange(c)
+	if !ok {
+		return
+	}
+	points, err := h.store.Snapshots.EquityCurve(userID, from, to)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"data": points})
+}
 
-	// 🔥 直接返回最新数据列表，前端无需再次调用 GetMyData
-	c.JSON(200, gin.H{"data": results})
+// dateRange 读取 ?from=&to= (2006-01-02)，参数不合法时写入 400 并返回 false
+func dateRange(c *gin.Context) (from, to string, ok bool) {
+	from, to = c.Query("from"), c.Query("to")
+	for _, v := range []string{from, to} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			c.JSON(400, gin.H{"error": "日期格式应为 2006-01-02"})
+			return "", "", false
+		}
+	}
+	return from, to, true
 }
 
-// 中间件
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.AbortWithStatusJSON(401, gin.H{"error": "未登录"})
-			return
+// 🔥 优化：刷新行情 (并发控制 + 统一返回)
+// (?portfolio_id= 同 GetMyData；?quote_format=typed 时 data 为结构化的 Quote 列表，默认为旧版 FundInfo)
+func (h *Handler) RefreshMarketDB(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	portfolioID, ok := h.portfolioQuery(c, userID)
+	if !ok {
+		return
+	}
+	// 获取用户关注的所有代码
+	holdings, err := h.store.Holdings.ListByUser(userID, portfolioID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	watchlist, err := h.store.Watchlist.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	codes := uniqueCodes(holdingCodes(holdings), watchCodes(watchlist))
+
+	// 并发获取最新数据 (最大并发 5)
+	quotes := service.FetchQuotes(codes)
+	typed := make([]*models.Quote, 0, len(quotes))
+	legacy := make([]*models.FundInfo, 0, len(quotes))
+	for _, code := range codes {
+		if q, ok := quotes[code]; ok {
+			typed = append(typed, q)
+			legacy = append(legacy, q.FundInfo())
 		}
-		token, _ := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("user_id", uint(claims["user_id"].(float64)))
-			c.Next()
-		} else {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Token 无效"})
+	}
+	var results interface{} = legacy
+	if c.Query("quote_format") == "typed" {
+		results = typed
+	}
+
+	for i := range holdings {
+		holding := &holdings[i]
+		data, ok := quotes[holding.FundCode]
+		service.ValueHolding(holding, data)
+		if !ok {
+			continue
+		}
+		// 更新数据库缓存 (LastPrice 等)，不影响 shares/cost
+		if err := h.store.Holdings.UpdateQuote(holding.ID, data.Name, data.Price.String(), data.ChangeRate.String()); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
 		}
 	}
+
+	quoteWatchlist(watchlist, quotes)
+	portfolios, combined, ok := h.portfolioViews(c, userID, portfolioID, holdings)
+	if !ok {
+		return
+	}
+
+	// 🔥 直接返回最新数据列表和计算好的持仓收益，前端无需再次调用 GetMyData
+	c.JSON(200, gin.H{
+		"data":                results,
+		"holdings":            holdings,
+		"watchlist":           watchlist,
+		"summary":             combined.Summary, // 只含 summary_currency 币种的组合
+		"summary_currency":    combined.Currency,
+		"summary_by_currency": combined.ByCurrency,
+		"portfolios":          portfolios,
+	})
 }
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fund-tracker-server/internal/fakeupstream"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword 测试用户的密码
+const testPassword = "correct-horse-42"
+
+// testEnv 内存库上的 Handler 和一个已登录用户，行情来自 fakeupstream
+type testEnv struct {
+	t       *testing.T
+	store   repo.Store
+	router  *gin.Engine
+	userID  uint
+	handler *Handler
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store, err := repo.NewMemoryStore(strings.ReplaceAll(t.Name(), "/", "_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "alice", Password: string(hash), BaseCurrency: "CNY"}
+	if err := store.Users.Create(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(fakeupstream.Handler(fakeupstream.Fixtures()))
+	prev := service.CurrentUpstream()
+	service.SetUpstream(fakeupstream.UpstreamFor(srv.URL))
+	t.Cleanup(func() {
+		service.SetUpstream(prev)
+		srv.Close()
+	})
+
+	h := NewHandler(store)
+	h.syncHistory = func(string) {}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", user.ID) })
+	r.POST("/login", h.Login)
+	r.POST("/refresh", h.Refresh)
+	r.POST("/add", h.AddFundDB)
+	r.POST("/sell", h.SellFundDB)
+	r.POST("/transactions", h.CreateTransaction)
+	r.POST("/delete", h.DeleteFundDB)
+	r.GET("/my_data", h.GetMyData)
+	r.GET("/history", h.GetHistory)
+	r.POST("/watchlist/reorder", h.ReorderWatchlist)
+	r.POST("/watchlist/groups", h.CreateWatchlistGroup)
+	r.POST("/watchlist/groups/update", h.UpdateWatchlistGroup)
+	r.POST("/watchlist/groups/delete", h.DeleteWatchlistGroup)
+	r.POST("/watchlist/groups/reorder", h.ReorderWatchlistGroups)
+	r.POST("/alerts", h.CreateAlert)
+	r.POST("/alerts/update", h.UpdateAlert)
+	r.POST("/notify/channels", h.CreateNotifyChannel)
+	r.POST("/notify/channels/update", h.UpdateNotifyChannel)
+	r.POST("/notify/channels/test", h.TestNotifyChannel)
+	r.POST("/notify/channels/verify", h.VerifyNotifyChannel)
+	r.POST("/notify/channels/verify/send", h.SendNotifyVerifyCode)
+	r.POST("/account/password", h.ChangePassword)
+	r.POST("/account/delete", h.DeleteAccount)
+	return &testEnv{t: t, store: store, router: r, userID: user.ID, handler: h}
+}
+
+// get 发送 GET 请求，返回状态码和解析后的响应
+func (e *testEnv) get(path string) (int, map[string]interface{}) {
+	e.t.Helper()
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		e.t.Fatalf("%s: invalid response %q", path, w.Body.String())
+	}
+	return w.Code, resp
+}
+
+// post 发送 JSON 请求，返回状态码和解析后的响应
+func (e *testEnv) post(path string, body interface{}) (int, map[string]interface{}) {
+	e.t.Helper()
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data)))
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		e.t.Fatalf("%s: invalid response %q", path, w.Body.String())
+	}
+	return w.Code, resp
+}
+
+// holding 默认组合中的持仓，不存在时返回 nil
+func (e *testEnv) holding(code string) *models.Holding {
+	e.t.Helper()
+	portfolio, err := e.store.Portfolios.Default(e.userID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	h, err := e.store.Holdings.Find(e.userID, portfolio.ID, code)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return h
+}
+
+func TestAddFundHolding(t *testing.T) {
+	e := newTestEnv(t)
+
+	status, resp := e.post("/add", gin.H{"code": "110022", "type": "holding", "shares": 1000, "cost_price": 3.5, "date": "2026-10-15"})
+	if status != 200 {
+		t.Fatalf("add: %d %v", status, resp)
+	}
+	status, _ = e.post("/add", gin.H{"code": "110022", "type": "holding", "shares": 1000, "cost_price": 3.7, "date": "2026-10-16"})
+	if status != 200 {
+		t.Fatalf("second add: %d", status)
+	}
+	h := e.holding("110022")
+	if h == nil || h.Shares != 2000 || h.CostPrice != 3.6 || h.FundName != "易方达消费行业股票" {
+		t.Fatalf("holding = %+v, want 2000 shares at 3.6", h)
+	}
+	txs, err := e.store.Transactions.List(e.userID, 0, "110022")
+	if err != nil || len(txs) != 2 {
+		t.Fatalf("transactions = %d, %v", len(txs), err)
+	}
+
+	// 输入不合法或上游查不到的代码不写入
+	for _, body := range []gin.H{
+		{"code": "11002", "type": "holding", "shares": 1, "cost_price": 1},
+		{"code": "110022", "type": "holding", "shares": 0, "cost_price": 1},
+		{"code": "999999", "type": "holding", "shares": 1, "cost_price": 1},
+	} {
+		if status, resp := e.post("/add", body); status != 400 {
+			t.Errorf("add %v: %d %v, want 400", body, status, resp)
+		}
+	}
+	if e.holding("999999") != nil {
+		t.Fatal("invalid add should not create a holding")
+	}
+}
+
+func TestSellFund(t *testing.T) {
+	e := newTestEnv(t)
+	if status, resp := e.post("/add", gin.H{"code": "110022", "type": "holding", "shares": 1000, "cost_price": 3.5, "date": "2026-10-15"}); status != 200 {
+		t.Fatalf("add: %d %v", status, resp)
+	}
+
+	status, resp := e.post("/sell", gin.H{"code": "110022", "shares": 400, "price": 4, "fee": 2, "date": "2026-10-16"})
+	if status != 200 {
+		t.Fatalf("sell: %d %v", status, resp)
+	}
+	// (4 - 3.5) * 400 - 2
+	if resp["realized"] != 198.0 {
+		t.Fatalf("realized = %v, want 198", resp["realized"])
+	}
+	if h := e.holding("110022"); h == nil || h.Shares != 600 || h.CostPrice != 3.5 {
+		t.Fatalf("holding after sell = %+v", h)
+	}
+
+	// 卖出超过持有份额时整体回滚
+	status, _ = e.post("/sell", gin.H{"code": "110022", "shares": 601, "price": 4, "date": "2026-10-16"})
+	if status != 400 {
+		t.Fatalf("oversell: %d, want 400", status)
+	}
+	if h := e.holding("110022"); h.Shares != 600 {
+		t.Fatalf("oversell changed holding: %+v", h)
+	}
+
+	if status, _ = e.post("/sell", gin.H{"code": "161725", "shares": 1, "price": 1}); status != 404 {
+		t.Fatalf("sell unheld: %d, want 404", status)
+	}
+}
+
+func TestDeleteFund(t *testing.T) {
+	e := newTestEnv(t)
+	for _, body := range []gin.H{
+		{"code": "110022", "type": "holding", "shares": 1000, "cost_price": 3.5, "date": "2026-10-15"},
+		{"code": "161725", "type": "holding", "shares": 500, "cost_price": 1.2, "date": "2026-10-15"},
+		{"code": "510300", "type": "watchlist"},
+	} {
+		if status, resp := e.post("/add", body); status != 200 {
+			t.Fatalf("add %v: %d %v", body, status, resp)
+		}
+	}
+
+	if status, _ := e.post("/delete", gin.H{"code": "110022", "type": "holding"}); status != 200 {
+		t.Fatalf("delete holding: %d", status)
+	}
+	if e.holding("110022") != nil {
+		t.Fatal("holding should be deleted")
+	}
+	if txs, _ := e.store.Transactions.List(e.userID, 0, "110022"); len(txs) != 0 {
+		t.Fatalf("transactions of the deleted holding remain: %d", len(txs))
+	}
+	if e.holding("161725") == nil {
+		t.Fatal("other holdings should be kept")
+	}
+
+	if status, _ := e.post("/delete", gin.H{"code": "510300", "type": "watchlist"}); status != 200 {
+		t.Fatalf("delete watchlist: %d", status)
+	}
+	list, err := e.store.Watchlist.ListByUser(e.userID)
+	if err != nil || len(list) != 0 {
+		t.Fatalf("watchlist after delete = %+v, %v", list, err)
+	}
+}
+
+func TestHistoryBackfillsOnlyTrackedCodes(t *testing.T) {
+	e := newTestEnv(t)
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	e.handler.syncHistory = func(code string) {
+		started <- code
+		<-release
+	}
+
+	// 没有人跟踪的代码不触发回填
+	if status, resp := e.get("/history?code=000001"); status != 200 || resp["syncing"] != false {
+		t.Fatalf("untracked: %d %v", status, resp)
+	}
+
+	if err := e.store.Watchlist.Add(&models.Watchlist{UserID: e.userID, FundCode: "110022"}); err != nil {
+		t.Fatal(err)
+	}
+	// 回填完成前的重复请求不再发起新的回填
+	for i := 0; i < 3; i++ {
+		if status, resp := e.get("/history?code=110022"); status != 200 || resp["syncing"] != true {
+			t.Fatalf("tracked: %d %v", status, resp)
+		}
+	}
+	select {
+	case code := <-started:
+		if code != "110022" {
+			t.Fatalf("backfilled %s", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("backfill did not start")
+	}
+	close(release)
+	if len(started) != 0 {
+		t.Fatalf("%d extra backfills started", len(started))
+	}
+}
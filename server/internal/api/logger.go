@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedParams 访问日志中隐藏值的查询参数 (WebSocket 握手的 ?token=)
+var redactedParams = map[string]bool{"token": true}
+
+// RequestLogger 与 gin 默认格式相同的访问日志，但不记录令牌等敏感查询参数
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if p.IsOutputColor() {
+			statusColor, methodColor, resetColor = p.StatusCodeColor(), p.MethodColor(), p.ResetColor()
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, p.StatusCode, resetColor,
+			p.Latency, p.ClientIP,
+			methodColor, p.Method, resetColor,
+			redactQuery(p.Path), p.ErrorMessage,
+		)
+	})
+}
+
+// redactQuery 把 path?query 中敏感参数的值替换掉，其余原样保留
+func redactQuery(path string) string {
+	base, query, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	parts := strings.Split(query, "&")
+	for i, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if redactedParams[strings.ToLower(key)] {
+			parts[i] = key + "=REDACTED"
+		}
+	}
+	return base + "?" + strings.Join(parts, "&")
+}
@@ -0,0 +1,17 @@
+package api
+
+import "testing"
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/ws?token=eyJhbGci.abc.def&quote_format=typed", "/ws?token=REDACTED&quote_format=typed"},
+		{"/ws?quote_format=typed&Token=abc", "/ws?quote_format=typed&Token=REDACTED"},
+		{"/history?code=110022", "/history?code=110022"},
+		{"/login", "/login"},
+	}
+	for _, tt := range tests {
+		if got := redactQuery(tt.in); got != tt.want {
+			t.Errorf("redactQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
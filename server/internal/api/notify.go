@@ -0,0 +1,315 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/notify"
+	"fund-tracker-server/internal/repo"
+	"math/big"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每个用户最多的通知渠道数、测试消息的超时和邮箱确认码的有效期
+const (
+	maxNotifyChannels = 10
+	notifyTestTimeout = 20 * time.Second
+	verifyCodeTTL     = 24 * time.Hour
+)
+
+// 站外通知，启动时由 SetNotifier 设置
+var dispatcher *notify.Dispatcher
+
+// SetNotifier 设置投递站外通知使用的 Dispatcher
+func SetNotifier(d *notify.Dispatcher) {
+	dispatcher = d
+}
+
+// 通知渠道请求体，修改时未传的字段保持不变
+type notifyChannelInput struct {
+	ID           uint      `json:"id"`
+	Kind         string    `json:"kind"` // 只在创建时使用
+	Target       *string   `json:"target"`
+	Events       *[]string `json:"events"`
+	Enabled      *bool     `json:"enabled"`
+	RotateSecret bool      `json:"rotate_secret"` // 重新生成 webhook 签名密钥
+}
+
+// apply 把请求写入 ch 并校验。邮箱地址变化后需要重新确认，未确认的邮箱不能启用
+func (in notifyChannelInput) apply(ch *models.NotifyChannel) fieldErrors {
+	fe := fieldErrors{}
+	target := ch.Target
+	if in.Target != nil {
+		ch.Target = strings.TrimSpace(*in.Target)
+	}
+	if in.Events != nil {
+		ch.Events = nil
+		for _, e := range *in.Events {
+			fe.check(notify.Kinds[e], "events", "不支持的消息类型: "+e)
+			ch.Events = append(ch.Events, e)
+		}
+	}
+	if in.Enabled != nil {
+		ch.Enabled = *in.Enabled
+	}
+
+	switch ch.Kind {
+	case models.ChannelWebhook:
+		u, err := url.Parse(ch.Target)
+		fe.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"target", "webhook 地址应为 http(s) URL")
+	case models.ChannelEmail:
+		addr, err := mail.ParseAddress(ch.Target)
+		fe.check(err == nil, "target", "邮箱地址不合法")
+		if err == nil {
+			ch.Target = addr.Address
+		}
+		if ch.Target != target {
+			ch.Verified, ch.VerifyCode, ch.VerifyExpires = false, "", nil
+			if in.Enabled == nil {
+				ch.Enabled = false
+			}
+		}
+		fe.check(ch.Verified || !ch.Enabled, "enabled", "邮箱尚未确认，确认后才能启用")
+	default:
+		fe.check(false, "kind", "kind 只能是 webhook 或 email")
+	}
+	return fe
+}
+
+// findNotifyChannel 查询当前用户的通知渠道，不存在时写入 404 并返回 false
+func (h *Handler) findNotifyChannel(c *gin.Context, userID, id uint) (*models.NotifyChannel, bool) {
+	ch, err := h.store.NotifyChannels.Find(userID, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "通知渠道不存在"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return ch, true
+}
+
+// 通知渠道列表，同时返回可订阅的消息类型和服务器是否支持邮件
+func (h *Handler) ListNotifyChannels(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	channels, err := h.store.NotifyChannels.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	events := make([]string, 0, len(notify.Kinds))
+	for kind := range notify.Kinds {
+		events = append(events, kind)
+	}
+	sort.Strings(events)
+	c.JSON(200, gin.H{"data": channels, "events": events, "email_enabled": dispatcher.EmailEnabled()})
+}
+
+// 新建通知渠道，webhook 的签名密钥由服务器生成并在响应中返回
+func (h *Handler) CreateNotifyChannel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input notifyChannelInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	// 邮箱渠道在收件人确认后才启用
+	ch := models.NotifyChannel{UserID: userID, Kind: input.Kind, Enabled: input.Kind != models.ChannelEmail}
+	if respondInvalid(c, input.apply(&ch)) {
+		return
+	}
+	if ch.Kind == models.ChannelEmail && !dispatcher.EmailEnabled() {
+		c.JSON(400, gin.H{"error": "服务器未配置邮件发送"})
+		return
+	}
+	channels, err := h.store.NotifyChannels.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(channels) >= maxNotifyChannels {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("最多添加 %d 个通知渠道", maxNotifyChannels)})
+		return
+	}
+	if ch.Kind == models.ChannelWebhook {
+		ch.Secret = randomToken()
+		ch.Verified = true
+	}
+	if err := h.store.NotifyChannels.Create(&ch); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{"success": true, "data": ch}
+	if ch.Kind == models.ChannelEmail {
+		// 确认邮件发送失败时渠道仍然保留，可以稍后重新发送
+		if err := h.sendVerifyCode(c, &ch); err != nil {
+			resp["verify_error"] = err.Error()
+		}
+	}
+	c.JSON(200, resp)
+}
+
+// 修改通知渠道 (类型不能修改)
+func (h *Handler) UpdateNotifyChannel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input notifyChannelInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	ch, ok := h.findNotifyChannel(c, userID, input.ID)
+	if !ok || respondInvalid(c, input.apply(ch)) {
+		return
+	}
+	if input.RotateSecret && ch.Kind == models.ChannelWebhook {
+		ch.Secret = randomToken()
+	}
+	if err := h.store.NotifyChannels.Save(ch); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": ch})
+}
+
+// 删除通知渠道
+func (h *Handler) DeleteNotifyChannel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	if _, ok := h.findNotifyChannel(c, userID, input.ID); !ok {
+		return
+	}
+	if err := h.store.NotifyChannels.Delete(userID, input.ID); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// 发送一条测试消息 (不重试)，失败时返回原因
+func (h *Handler) TestNotifyChannel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	ch, ok := h.findNotifyChannel(c, userID, input.ID)
+	if !ok {
+		return
+	}
+	if ch.Kind == models.ChannelEmail && !ch.Verified {
+		c.JSON(403, gin.H{"error": "邮箱尚未确认，请先输入确认邮件中的确认码"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), notifyTestTimeout)
+	defer cancel()
+	msg := notify.Message{
+		UserID: userID,
+		Kind:   notify.KindTest,
+		Title:  "测试通知",
+		Body:   "这是一条测试消息，收到说明通知渠道配置正确。",
+	}
+	if err := dispatcher.Test(ctx, ch, msg); err != nil {
+		c.JSON(502, gin.H{"error": "发送失败: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// 重新发送邮箱确认码 (之前的确认码随即失效)
+func (h *Handler) SendNotifyVerifyCode(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	ch, ok := h.findNotifyChannel(c, userID, input.ID)
+	if !ok {
+		return
+	}
+	if ch.Kind != models.ChannelEmail || ch.Verified {
+		c.JSON(400, gin.H{"error": "该渠道不需要确认"})
+		return
+	}
+	if err := h.sendVerifyCode(c, ch); err != nil {
+		c.JSON(502, gin.H{"error": "发送失败: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// 用确认邮件中的确认码确认邮箱，确认后渠道自动启用
+func (h *Handler) VerifyNotifyChannel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID   uint   `json:"id"`
+		Code string `json:"code"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	ch, ok := h.findNotifyChannel(c, userID, input.ID)
+	if !ok {
+		return
+	}
+	if ch.Kind != models.ChannelEmail || ch.Verified {
+		c.JSON(400, gin.H{"error": "该渠道不需要确认"})
+		return
+	}
+	if ch.VerifyCode == "" || ch.VerifyExpires == nil || time.Now().After(*ch.VerifyExpires) {
+		c.JSON(400, gin.H{"error": "确认码已过期，请重新发送"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(strings.TrimSpace(input.Code))), []byte(ch.VerifyCode)) != 1 {
+		c.JSON(400, gin.H{"error": "确认码错误"})
+		return
+	}
+	ch.Verified, ch.Enabled, ch.VerifyCode, ch.VerifyExpires = true, true, "", nil
+	if err := h.store.NotifyChannels.Save(ch); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": ch})
+}
+
+// sendVerifyCode 生成新的确认码 (只保存哈希) 并发到渠道的邮箱
+func (h *Handler) sendVerifyCode(c *gin.Context, ch *models.NotifyChannel) error {
+	code := verifyCode()
+	expires := time.Now().Add(verifyCodeTTL)
+	ch.VerifyCode, ch.VerifyExpires = hashToken(code), &expires
+	if err := h.store.NotifyChannels.Save(ch); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), notifyTestTimeout)
+	defer cancel()
+	return dispatcher.Test(ctx, ch, notify.Message{
+		UserID: ch.UserID,
+		Kind:   notify.KindTest,
+		Title:  "确认通知邮箱",
+		Body: fmt.Sprintf("你的确认码是 %s，%d 小时内有效。\n在通知设置中输入确认码后，这个邮箱才会收到提醒和简报。\n如果不是你本人操作，请忽略这封邮件。",
+			code, int(verifyCodeTTL.Hours())),
+	})
+}
+
+// verifyCode 8 位数字确认码
+func verifyCode() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(100000000))
+	return fmt.Sprintf("%08d", n)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/base64"
+	"fund-tracker-server/internal/fakenotify"
+	"fund-tracker-server/internal/notify"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withFakeSMTP 让通知经由 fakenotify 的 SMTP 服务发送
+func withFakeSMTP(t *testing.T, e *testEnv) *fakenotify.SMTPServer {
+	t.Helper()
+	srv, err := fakenotify.StartSMTP("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := dispatcher
+	SetNotifier(notify.NewDispatcher(e.store.NotifyChannels, notify.Options{
+		SMTP:           notify.SMTPConfig{Host: srv.Host(), Port: srv.Port(), From: "alerts@fund.test"},
+		WebhookTimeout: time.Second,
+		Attempts:       1,
+	}))
+	t.Cleanup(func() {
+		SetNotifier(prev)
+		srv.Close()
+	})
+	return srv
+}
+
+var codePattern = regexp.MustCompile(`确认码是 ([0-9]{8})`)
+
+// lastCode 最近一封邮件中的确认码
+func lastCode(t *testing.T, srv *fakenotify.SMTPServer) string {
+	t.Helper()
+	mails := srv.Mails()
+	if len(mails) == 0 {
+		t.Fatal("no mail sent")
+	}
+	parsed, err := mails[len(mails)-1].Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, parsed.Body))
+	m := codePattern.FindStringSubmatch(string(body))
+	if m == nil {
+		t.Fatalf("no code in %q", body)
+	}
+	return m[1]
+}
+
+func TestEmailChannelRequiresVerification(t *testing.T) {
+	e := newTestEnv(t)
+	srv := withFakeSMTP(t, e)
+
+	status, resp := e.post("/notify/channels", gin.H{"kind": "email", "target": "Alice <alice@example.com>"})
+	if status != 200 {
+		t.Fatalf("create: %d %v", status, resp)
+	}
+	data := resp["data"].(map[string]interface{})
+	id := data["id"]
+	if data["enabled"] != false || data["verified"] != false || data["target"] != "alice@example.com" {
+		t.Fatalf("new email channel = %v, want disabled and unverified", data)
+	}
+	if mails := srv.Mails(); len(mails) != 1 || mails[0].To[0] != "alice@example.com" {
+		t.Fatalf("confirmation mails = %+v", mails)
+	}
+	code := lastCode(t, srv)
+
+	// 确认前不能测试或启用
+	if status, _ := e.post("/notify/channels/test", gin.H{"id": id}); status != 403 {
+		t.Fatalf("test before verify: %d, want 403", status)
+	}
+	if status, _ := e.post("/notify/channels/update", gin.H{"id": id, "enabled": true}); status != 400 {
+		t.Fatalf("enable before verify: %d, want 400", status)
+	}
+	if status, _ := e.post("/notify/channels/verify", gin.H{"id": id, "code": "00000000"}); status != 400 {
+		t.Fatalf("wrong code: %d, want 400", status)
+	}
+
+	// 重新发送后旧确认码失效
+	if status, _ := e.post("/notify/channels/verify/send", gin.H{"id": id}); status != 200 {
+		t.Fatalf("resend: %d", status)
+	}
+	newCode := lastCode(t, srv)
+	if code != newCode {
+		if status, _ := e.post("/notify/channels/verify", gin.H{"id": id, "code": code}); status != 400 {
+			t.Fatalf("old code: %d, want 400", status)
+		}
+	}
+	status, resp = e.post("/notify/channels/verify", gin.H{"id": id, "code": newCode})
+	if status != 200 {
+		t.Fatalf("verify: %d %v", status, resp)
+	}
+	if data := resp["data"].(map[string]interface{}); data["enabled"] != true || data["verified"] != true {
+		t.Fatalf("verified channel = %v", data)
+	}
+	if status, resp := e.post("/notify/channels/test", gin.H{"id": id}); status != 200 {
+		t.Fatalf("test after verify: %d %v", status, resp)
+	}
+	sent := len(srv.Mails())
+
+	// 换邮箱后需要重新确认
+	status, resp = e.post("/notify/channels/update", gin.H{"id": id, "target": "bob@example.com"})
+	if status != 200 {
+		t.Fatalf("change target: %d %v", status, resp)
+	}
+	if data := resp["data"].(map[string]interface{}); data["enabled"] != false || data["verified"] != false {
+		t.Fatalf("channel after target change = %v", data)
+	}
+	if status, _ := e.post("/notify/channels/test", gin.H{"id": id}); status != 403 {
+		t.Fatalf("test after target change: %d, want 403", status)
+	}
+	if len(srv.Mails()) != sent {
+		t.Fatal("no mail should go to an unverified address")
+	}
+}
+
+func TestUnverifiedEmailIsNotNotified(t *testing.T) {
+	e := newTestEnv(t)
+	srv := withFakeSMTP(t, e)
+	if status, resp := e.post("/notify/channels", gin.H{"kind": "email", "target": "alice@example.com"}); status != 200 {
+		t.Fatalf("create: %d %v", status, resp)
+	}
+	channels, err := e.store.NotifyChannels.ListByUser(e.userID)
+	if err != nil || len(channels) != 1 {
+		t.Fatalf("channels = %+v, %v", channels, err)
+	}
+	// 即使被直接标记为启用，未确认的邮箱也不会收到通知
+	channels[0].Enabled = true
+	if err := e.store.NotifyChannels.Save(&channels[0]); err != nil {
+		t.Fatal(err)
+	}
+	before := len(srv.Mails())
+	if err := dispatcher.Notify(t.Context(), notify.Message{UserID: e.userID, Kind: notify.KindAlert, Title: "提醒"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.Mails()) != before {
+		t.Fatal("unverified email received a notification")
+	}
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 组合字段长度限制 (字符数)
+const (
+	maxPortfolioName   = 32
+	maxPortfolioBroker = 64
+	maxPortfolioNotes  = 500
+)
+
+// portfolioView 组合及其汇总
+type portfolioView struct {
+	models.Portfolio
+	Summary service.PortfolioSummary `json:"summary"`
+}
+
+// findPortfolio 查询当前用户的组合，不存在时写入 404 并返回 false
+func (h *Handler) findPortfolio(c *gin.Context, userID, id uint) (*models.Portfolio, bool) {
+	portfolio, err := h.store.Portfolios.Find(userID, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "组合不存在"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return portfolio, true
+}
+
+// portfolioQuery 读取 ?portfolio_id=，未传时为 0 表示全部组合
+func (h *Handler) portfolioQuery(c *gin.Context, userID uint) (uint, bool) {
+	raw := c.Query("portfolio_id")
+	if raw == "" || raw == "0" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "portfolio_id 不合法"})
+		return 0, false
+	}
+	if _, ok := h.findPortfolio(c, userID, uint(id)); !ok {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// resolvePortfolio 写入持仓/流水时确定所属组合：id 为 0 时使用默认组合 (没有则创建)
+func (h *Handler) resolvePortfolio(c *gin.Context, userID, id uint) (uint, bool) {
+	if id != 0 {
+		portfolio, ok := h.findPortfolio(c, userID, id)
+		if !ok {
+			return 0, false
+		}
+		return portfolio.ID, true
+	}
+	portfolio, err := defaultPortfolio(h.store, userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return portfolio.ID, true
+}
+
+// defaultPortfolio 用户的默认组合，没有时按用户的本位币创建
+func defaultPortfolio(s repo.Store, userID uint) (*models.Portfolio, error) {
+	portfolio, err := s.Portfolios.Default(userID)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return portfolio, err
+	}
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	portfolio = &models.Portfolio{UserID: userID, Name: models.DefaultPortfolioName, Currency: user.BaseCurrency}
+	if err := s.Portfolios.Create(portfolio); err != nil {
+		return nil, err
+	}
+	return portfolio, nil
+}
+
+// combinedSummary 多个组合的合并汇总。不同币种的金额不能直接相加，所以按币种分别汇总；
+// Summary 只合并与本位币相同的组合 (只看一个组合时为该组合，币种为组合的币种)
+type combinedSummary struct {
+	Currency   string
+	Summary    service.PortfolioSummary
+	ByCurrency map[string]service.PortfolioSummary
+}
+
+// portfolioViews 按组合汇总已估值的持仓；portfolioID 为 0 时列出全部组合
+func (h *Handler) portfolioViews(c *gin.Context, userID, portfolioID uint, holdings []models.Holding) ([]portfolioView, combinedSummary, bool) {
+	var portfolios []models.Portfolio
+	var currency string
+	if portfolioID != 0 {
+		portfolio, ok := h.findPortfolio(c, userID, portfolioID)
+		if !ok {
+			return nil, combinedSummary{}, false
+		}
+		portfolios = []models.Portfolio{*portfolio}
+		currency = portfolio.Currency
+	} else {
+		var err error
+		if portfolios, err = h.store.Portfolios.ListByUser(userID); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return nil, combinedSummary{}, false
+		}
+		user, err := h.store.Users.FindByID(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return nil, combinedSummary{}, false
+		}
+		currency = user.BaseCurrency
+	}
+
+	byPortfolio := make(map[uint][]models.Holding)
+	for _, holding := range holdings {
+		byPortfolio[holding.PortfolioID] = append(byPortfolio[holding.PortfolioID], holding)
+	}
+	byCurrency := make(map[string][]models.Holding)
+	views := make([]portfolioView, 0, len(portfolios))
+	for _, p := range portfolios {
+		views = append(views, portfolioView{Portfolio: p, Summary: service.Summarize(byPortfolio[p.ID])})
+		byCurrency[p.Currency] = append(byCurrency[p.Currency], byPortfolio[p.ID]...)
+	}
+	combined := combinedSummary{
+		Currency:   currency,
+		Summary:    service.Summarize(byCurrency[currency]),
+		ByCurrency: make(map[string]service.PortfolioSummary, len(byCurrency)),
+	}
+	for cur, list := range byCurrency {
+		combined.ByCurrency[cur] = service.Summarize(list)
+	}
+	return views, combined, true
+}
+
+// 组合请求体，修改时未传的字段保持不变
+type portfolioInput struct {
+	ID       uint    `json:"id"`
+	Name     *string `json:"name"`
+	Currency *string `json:"currency"`
+	Broker   *string `json:"broker"`
+	Notes    *string `json:"notes"`
+}
+
+// apply 把请求写入 p 并校验
+func (in portfolioInput) apply(p *models.Portfolio) fieldErrors {
+	fe := fieldErrors{}
+	if in.Name != nil {
+		p.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Currency != nil {
+		p.Currency = strings.ToUpper(strings.TrimSpace(*in.Currency))
+	}
+	if in.Broker != nil {
+		p.Broker = strings.TrimSpace(*in.Broker)
+	}
+	if in.Notes != nil {
+		p.Notes = strings.TrimSpace(*in.Notes)
+	}
+	n := utf8.RuneCountInString(p.Name)
+	fe.check(n > 0 && n <= maxPortfolioName, "name", fmt.Sprintf("组合名称应为 1-%d 个字符", maxPortfolioName))
+	fe.check(baseCurrencies[p.Currency], "currency", "不支持的币种: "+p.Currency)
+	fe.check(utf8.RuneCountInString(p.Broker) <= maxPortfolioBroker, "broker", fmt.Sprintf("开户平台最多 %d 个字符", maxPortfolioBroker))
+	fe.check(utf8.RuneCountInString(p.Notes) <= maxPortfolioNotes, "notes", fmt.Sprintf("备注最多 %d 个字符", maxPortfolioNotes))
+	return fe
+}
+
+// respondPortfolioSaved 保存组合后的响应，重名返回 409
+func respondPortfolioSaved(c *gin.Context, p *models.Portfolio, err error) {
+	if errors.Is(err, repo.ErrDuplicate) {
+		c.JSON(409, gin.H{"error": "已有同名组合", "fields": fieldErrors{"name": "已有同名组合"}})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": p})
+}
+
+// 组合列表，第一个为默认组合
+func (h *Handler) ListPortfolios(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	if _, err := defaultPortfolio(h.store, userID); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	portfolios, err := h.store.Portfolios.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"data": portfolios})
+}
+
+// 新建组合，币种默认为用户的本位币
+func (h *Handler) CreatePortfolio(c *gin.Context) {
+	var input portfolioInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	user := h.currentUser(c)
+	if user == nil {
+		return
+	}
+	portfolio := models.Portfolio{UserID: user.ID, Currency: user.BaseCurrency}
+	if respondInvalid(c, input.apply(&portfolio)) {
+		return
+	}
+	respondPortfolioSaved(c, &portfolio, h.store.Portfolios.Create(&portfolio))
+}
+
+// 修改组合
+func (h *Handler) UpdatePortfolio(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input portfolioInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	portfolio, ok := h.findPortfolio(c, userID, input.ID)
+	if !ok {
+		return
+	}
+	if respondInvalid(c, input.apply(portfolio)) {
+		return
+	}
+	respondPortfolioSaved(c, portfolio, h.store.Portfolios.Save(portfolio))
+}
+
+// 删除组合及其中的持仓和流水，用户的最后一个组合不能删除
+func (h *Handler) DeletePortfolio(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	if _, ok := h.findPortfolio(c, userID, input.ID); !ok {
+		return
+	}
+	portfolios, err := h.store.Portfolios.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(portfolios) <= 1 {
+		c.JSON(400, gin.H{"error": "至少需要保留一个组合"})
+		return
+	}
+
+	err = h.store.Atomic(func(s repo.Store) error {
+		if err := s.Transactions.DeleteByPortfolio(userID, input.ID); err != nil {
+			return err
+		}
+		if err := s.Holdings.DeleteByPortfolio(userID, input.ID); err != nil {
+			return err
+		}
+		return s.Portfolios.Delete(userID, input.ID)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	notifyPortfolioChanged(userID, "")
+	c.JSON(200, gin.H{"success": true})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMyDataSummaryByCurrency(t *testing.T) {
+	e := newTestEnv(t)
+	usd := models.Portfolio{UserID: e.userID, Name: "美元账户", Currency: "USD"}
+	if err := e.store.Portfolios.Create(&models.Portfolio{UserID: e.userID, Name: models.DefaultPortfolioName, Currency: "CNY"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.store.Portfolios.Create(&usd); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []gin.H{
+		{"code": "110022", "type": "holding", "shares": 1000, "cost_price": 3.5, "date": "2026-10-15"},
+		{"code": "161725", "type": "holding", "shares": 500, "cost_price": 1.2, "date": "2026-10-15", "portfolio_id": usd.ID},
+	} {
+		if status, resp := e.post("/add", body); status != 200 {
+			t.Fatalf("add %v: %d %v", body, status, resp)
+		}
+	}
+
+	cost := func(summary interface{}) float64 {
+		return summary.(map[string]interface{})["total_cost"].(float64)
+	}
+	status, resp := e.get("/my_data")
+	if status != 200 {
+		t.Fatalf("my_data: %d %v", status, resp)
+	}
+	// 合并汇总只含本位币 (CNY) 的组合，其他币种单独汇总
+	if resp["summary_currency"] != "CNY" || cost(resp["summary"]) != 3500 {
+		t.Fatalf("summary = %v %v, want CNY with cost 3500", resp["summary_currency"], resp["summary"])
+	}
+	byCurrency := resp["summary_by_currency"].(map[string]interface{})
+	if len(byCurrency) != 2 || cost(byCurrency["CNY"]) != 3500 || cost(byCurrency["USD"]) != 600 {
+		t.Fatalf("summary_by_currency = %v", byCurrency)
+	}
+
+	// 只看一个组合时按该组合的币种汇总
+	status, resp = e.get(fmt.Sprintf("/my_data?portfolio_id=%d", usd.ID))
+	if status != 200 || resp["summary_currency"] != "USD" || cost(resp["summary"]) != 600 {
+		t.Fatalf("single portfolio: %d %v %v", status, resp["summary_currency"], resp["summary"])
+	}
+}
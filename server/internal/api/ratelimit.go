@@ -0,0 +1,83 @@
+package api
+
+import (
+	"expvar"
+	"fmt"
+	"fund-tracker-server/internal/ratelimit"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 限流统计，通过 /debug/vars 的 rate_limit 查看
+var rateStats = expvar.NewMap("rate_limit")
+
+// 登录失败锁定，启动时由 SetLoginLockout 从配置设置
+var loginLockout = ratelimit.NewLockout(5, time.Minute, time.Hour)
+
+// 登录失败的账户级锁定，不区分来源 IP，阈值比按 IP 的高，启动时由 SetAccountLockout 从配置设置
+var accountLockout = ratelimit.NewLockout(20, time.Minute, time.Hour)
+
+// SetLoginLockout 连续失败 threshold 次后锁定 base，之后每次失败翻倍，最长 max
+func SetLoginLockout(threshold int, base, max time.Duration) {
+	loginLockout = ratelimit.NewLockout(threshold, base, max)
+}
+
+// SetAccountLockout 同一用户名 (不论来源 IP) 连续失败 threshold 次后锁定，时长规则同 SetLoginLockout
+func SetAccountLockout(threshold int, base, max time.Duration) {
+	accountLockout = ratelimit.NewLockout(threshold, base, max)
+}
+
+// RateLimitByIP 未登录接口按客户端 IP 限流
+func RateLimitByIP(route string, l *ratelimit.Limiter) gin.HandlerFunc {
+	return rateLimit(route, l, func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+// RateLimitByUser 登录后的接口按用户限流，需放在 AuthMiddleware 之后
+func RateLimitByUser(route string, l *ratelimit.Limiter) gin.HandlerFunc {
+	return rateLimit(route, l, func(c *gin.Context) string {
+		if userID, ok := c.Get("user_id"); ok {
+			return fmt.Sprintf("user:%d", userID)
+		}
+		return "ip:" + c.ClientIP()
+	})
+}
+
+func rateLimit(route string, l *ratelimit.Limiter, key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, wait := l.Allow(key(c)); !ok {
+			rateStats.Add("blocked_"+route, 1)
+			abortTooMany(c, wait, "请求过于频繁")
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortTooMany 返回 429 和 Retry-After (秒，向上取整)
+func abortTooMany(c *gin.Context, wait time.Duration, reason string) {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.AbortWithStatusJSON(429, gin.H{
+		"error":       fmt.Sprintf("%s，请 %d 秒后重试", reason, secs),
+		"retry_after": secs,
+	})
+}
+
+// lockoutKey 按 用户名+IP 计数，避免他人用错误密码把账户锁死
+func lockoutKey(c *gin.Context, username string) string {
+	return strings.ToLower(username) + "|" + c.ClientIP()
+}
+
+// accountLockoutKey 按用户名计数，更换 IP 也无法绕过
+func accountLockoutKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"errors"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"fund-tracker-server/internal/ws"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ledgerError 流水不合法 (返回 400)，其余错误视为数据库错误 (返回 500)
+type ledgerError struct{ error }
+
+// 交易流水请求体
+type transactionInput struct {
+	PortfolioID uint    `json:"portfolio_id"` // 0 为默认组合，修改流水时忽略
+	Code        string  `json:"code"`
+	Type        string  `json:"type"`
+	TradeDate   string  `json:"trade_date"` // 2006-01-02，留空为今天
+	Shares      float64 `json:"shares"`
+	Price       float64 `json:"price"`
+	Fee         float64 `json:"fee"`
+	Amount      float64 `json:"amount"`
+	Note        string  `json:"note"`
+}
+
+func (in transactionInput) toModel(userID uint) (models.Transaction, error) {
+	date, err := parseTradeDate(in.TradeDate)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return models.Transaction{
+		UserID:    userID,
+		FundCode:  in.Code,
+		Type:      in.Type,
+		TradeDate: date,
+		Shares:    in.Shares,
+		Price:     in.Price,
+		Fee:       in.Fee,
+		Amount:    in.Amount,
+		Note:      in.Note,
+	}, nil
+}
+
+// parseTradeDate 交易日按北京时间解释，与快照、简报等后台任务的日期边界一致
+func parseTradeDate(s string) (time.Time, error) {
+	if s == "" {
+		now := time.Now().In(service.ChinaTZ)
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, service.ChinaTZ), nil
+	}
+	return time.ParseInLocation("2006-01-02", s, service.ChinaTZ)
+}
+
+// 查询交易流水
+func (h *Handler) ListTransactions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	portfolioID, ok := h.portfolioQuery(c, userID)
+	if !ok {
+		return
+	}
+	txs, err := h.store.Transactions.List(userID, portfolioID, c.Query("code"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"data": txs})
+}
+
+// 新增交易流水
+func (h *Handler) CreateTransaction(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input transactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !fundCodePattern.MatchString(input.Code) {
+		c.JSON(400, gin.H{"error": "基金代码应为 6 位数字"})
+		return
+	}
+	record, err := input.toModel(userID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "日期格式应为 2006-01-02"})
+		return
+	}
+	portfolioID, ok := h.resolvePortfolio(c, userID, input.PortfolioID)
+	if !ok {
+		return
+	}
+	record.PortfolioID = portfolioID
+	fundInfo, err := service.FetchQuote(input.Code)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的基金代码"})
+		return
+	}
+
+	err = h.applyLedgerChange(userID, record.PortfolioID, input.Code, fundInfo.Name, func(s repo.Store) error {
+		return s.Transactions.Create(&record)
+	}, &record)
+	if respondLedgerError(c, err) {
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": record})
+}
+
+// 修改交易流水
+func (h *Handler) UpdateTransaction(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+		transactionInput
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	record, ok := h.findTransaction(c, userID, input.ID)
+	if !ok {
+		return
+	}
+	updated, err := input.toModel(userID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "日期格式应为 2006-01-02"})
+		return
+	}
+	// 不允许跨基金或跨组合修改，录错了应删除后重新录入
+	updated.Model = record.Model
+	updated.FundCode = record.FundCode
+	updated.PortfolioID = record.PortfolioID
+
+	err = h.applyLedgerChange(userID, record.PortfolioID, record.FundCode, "", func(s repo.Store) error {
+		return s.Transactions.Save(&updated)
+	}, &updated)
+	if respondLedgerError(c, err) {
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": updated})
+}
+
+// 删除交易流水
+func (h *Handler) DeleteTransaction(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	record, ok := h.findTransaction(c, userID, input.ID)
+	if !ok {
+		return
+	}
+
+	err := h.applyLedgerChange(userID, record.PortfolioID, record.FundCode, "", func(s repo.Store) error {
+		return s.Transactions.Delete(record)
+	}, nil)
+	if respondLedgerError(c, err) {
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// 卖出/赎回
+func (h *Handler) SellFundDB(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		PortfolioID uint    `json:"portfolio_id"` // 0 为默认组合
+		Code        string  `json:"code"`
+		Shares      float64 `json:"shares"`
+		Price       float64 `json:"price"`
+		Fee         float64 `json:"fee"`
+		Date        string  `json:"date"` // 交易日期 2006-01-02，留空为今天
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	date, err := parseTradeDate(input.Date)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "日期格式应为 2006-01-02"})
+		return
+	}
+	portfolioID, ok := h.resolvePortfolio(c, userID, input.PortfolioID)
+	if !ok {
+		return
+	}
+	holding, err := h.store.Holdings.Find(userID, portfolioID, input.Code)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "未持有该基金"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	realizedBefore := holding.RealizedReturn
+
+	record := models.Transaction{
+		UserID:      userID,
+		PortfolioID: portfolioID,
+		FundCode:    input.Code,
+		Type:        models.TxSell,
+		TradeDate:   date,
+		Shares:      input.Shares,
+		Price:       input.Price,
+		Fee:         input.Fee,
+	}
+	err = h.applyLedgerChange(userID, portfolioID, input.Code, "", func(s repo.Store) error {
+		return s.Transactions.Create(&record)
+	}, &record)
+	if respondLedgerError(c, err) {
+		return
+	}
+
+	holding, err = h.store.Holdings.Find(userID, portfolioID, input.Code)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"success":  true,
+		"holding":  holding,
+		"realized": holding.RealizedReturn - realizedBefore, // 本次卖出的已实现收益 (平均成本法)
+	})
+}
+
+// findTransaction 查询当前用户的流水，不存在时写入 404 并返回 false
+func (h *Handler) findTransaction(c *gin.Context, userID, id uint) (*models.Transaction, bool) {
+	record, err := h.store.Transactions.Find(userID, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "流水不存在"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return record, true
+}
+
+// applyLedgerChange 在同一个数据库事务里修改流水并重建 Holding，流水不合法时整体回滚
+func (h *Handler) applyLedgerChange(userID, portfolioID uint, code, fundName string, change func(s repo.Store) error, record *models.Transaction) error {
+	if record != nil {
+		if err := service.ValidateTransaction(record); err != nil {
+			return ledgerError{err}
+		}
+	}
+	err := h.store.Atomic(func(s repo.Store) error {
+		if err := change(s); err != nil {
+			return err
+		}
+		return rebuildHolding(s, userID, portfolioID, code, fundName)
+	})
+	if err == nil {
+		notifyPortfolioChanged(userID, code)
+	}
+	return err
+}
+
+// notifyPortfolioChanged 通知该用户的所有在线设备持仓有变动，客户端据此重新拉取
+func notifyPortfolioChanged(userID uint, code string) {
+	ws.Manager.SendJSON(userID, gin.H{"type": "portfolio", "event": "changed", "fund_code": code})
+}
+
+// positionKey 持仓按 (组合, 基金) 区分
+type positionKey struct {
+	portfolioID uint
+	code        string
+}
+
+// projectHoldings 用指定成本方法重新计算持仓的份额、成本和已实现收益
+func (h *Handler) projectHoldings(userID, portfolioID uint, holdings []models.Holding, method service.CostMethod) error {
+	txs, err := h.store.Transactions.List(userID, portfolioID, "")
+	if err != nil {
+		return err
+	}
+	byKey := make(map[positionKey][]models.Transaction)
+	for _, tx := range txs {
+		key := positionKey{tx.PortfolioID, tx.FundCode}
+		byKey[key] = append(byKey[key], tx)
+	}
+	for i := range holdings {
+		list, ok := byKey[positionKey{holdings[i].PortfolioID, holdings[i].FundCode}]
+		if !ok {
+			continue
+		}
+		pos, err := service.ProjectPosition(list, method)
+		if err != nil {
+			return err
+		}
+		holdings[i].Shares = pos.Shares
+		holdings[i].CostPrice = pos.CostPrice()
+		holdings[i].RealizedReturn = pos.Realized
+	}
+	return nil
+}
+
+// rebuildHolding 用流水重放结果 (平均成本法) 覆盖 Holding 投影，流水清空时删除 Holding；
+// 全部卖出后保留份额为 0 的 Holding 以展示已实现收益
+func rebuildHolding(s repo.Store, userID, portfolioID uint, code, fundName string) error {
+	txs, err := s.Transactions.List(userID, portfolioID, code)
+	if err != nil {
+		return err
+	}
+	holding, err := s.Holdings.Find(userID, portfolioID, code)
+	if errors.Is(err, repo.ErrNotFound) {
+		holding, err = &models.Holding{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(txs) == 0 {
+		if holding.ID != 0 {
+			return s.Holdings.Delete(userID, portfolioID, code)
+		}
+		return nil
+	}
+
+	pos, err := service.ProjectPosition(txs, service.CostAverage)
+	if err != nil {
+		return ledgerError{err}
+	}
+	holding.UserID = userID
+	holding.PortfolioID = portfolioID
+	holding.FundCode = code
+	if fundName != "" {
+		holding.FundName = fundName
+	}
+	holding.Shares = pos.Shares
+	holding.CostPrice = pos.CostPrice()
+	holding.RealizedReturn = pos.Realized
+	return s.Holdings.Save(holding)
+}
+
+// respondLedgerError 写入错误响应，返回是否已处理
+func respondLedgerError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	var le ledgerError
+	if errors.As(err, &le) {
+		c.JSON(400, gin.H{"error": le.Error()})
+	} else {
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fund-tracker-server/internal/service"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseTradeDateUsesChinaTime(t *testing.T) {
+	prev := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = prev })
+
+	got, err := parseTradeDate("2026-10-16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 16, 0, 0, 0, 0, service.ChinaTZ); !got.Equal(want) {
+		t.Fatalf("parseTradeDate = %v, want %v", got, want)
+	}
+
+	today, err := parseTradeDate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Now().In(service.ChinaTZ).Format("2006-01-02"); today.In(service.ChinaTZ).Format("2006-01-02") != want {
+		t.Fatalf("default trade date = %v, want %s", today, want)
+	}
+}
+
+func TestCreateTransactionRejectsBadCode(t *testing.T) {
+	e := newTestEnv(t)
+	for _, code := range []string{"", "11002", "1100222", "abcdef", "../110"} {
+		status, resp := e.post("/transactions", gin.H{"code": code, "type": "buy", "shares": 100, "price": 1})
+		if status != 400 {
+			t.Fatalf("code %q: %d %v", code, status, resp)
+		}
+	}
+	txs, err := e.store.Transactions.List(e.userID, 0, "")
+	if err != nil || len(txs) != 0 {
+		t.Fatalf("transactions = %v, %v", txs, err)
+	}
+
+	if status, resp := e.post("/transactions", gin.H{"code": "110022", "type": "buy", "shares": 100, "price": 3.5, "trade_date": "2026-10-16"}); status != 200 {
+		t.Fatalf("valid code: %d %v", status, resp)
+	}
+}
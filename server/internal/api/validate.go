@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 用户名、密码和基金代码规则
+var (
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,32}$`)
+	fundCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+)
+
+const (
+	minPasswordLen = 8
+	maxPasswordLen = 72 // bcrypt 只使用前 72 字节
+)
+
+// fieldErrors 字段名 -> 错误说明
+type fieldErrors map[string]string
+
+// check 条件不满足时记录错误，同一字段只保留第一条
+func (fe fieldErrors) check(ok bool, field, msg string) {
+	if ok {
+		return
+	}
+	if _, exists := fe[field]; !exists {
+		fe[field] = msg
+	}
+}
+
+// respondInvalid 有错误时写入 400 并返回 true。error 为所有错误的拼接，兼容只读 error 的客户端
+func respondInvalid(c *gin.Context, fe fieldErrors) bool {
+	if len(fe) == 0 {
+		return false
+	}
+	fields := make([]string, 0, len(fe))
+	for field := range fe {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	msgs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		msgs = append(msgs, fe[field])
+	}
+	c.JSON(400, gin.H{"error": strings.Join(msgs, "；"), "fields": fe})
+	return true
+}
+
+// bindJSON 解析请求体，失败时写入 400 并返回 false
+func bindJSON(c *gin.Context, dst interface{}) bool {
+	if err := c.ShouldBindJSON(dst); err != nil {
+		c.JSON(400, gin.H{"error": "请求体格式错误: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// checkPassword 密码策略: 8-72 位，同时包含字母和数字，不能与用户名相同
+func checkPassword(fe fieldErrors, field, password, username string) {
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	fe.check(len(password) >= minPasswordLen && len(password) <= maxPasswordLen, field, "密码长度应为 8-72 位")
+	fe.check(letter && digit, field, "密码需同时包含字母和数字")
+	fe.check(!strings.EqualFold(password, username), field, "密码不能与用户名相同")
+}
+
+// 注册/登录请求体
+type credentialsInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// validateRegister 注册时校验用户名格式和密码强度
+func (in *credentialsInput) validateRegister() fieldErrors {
+	in.Username = strings.TrimSpace(in.Username)
+	fe := fieldErrors{}
+	fe.check(usernamePattern.MatchString(in.Username), "username", "用户名应为 3-32 位字母、数字或下划线")
+	checkPassword(fe, "password", in.Password, in.Username)
+	return fe
+}
+
+// validateLogin 登录只检查非空，老账户的密码可能不满足新的策略
+func (in *credentialsInput) validateLogin() fieldErrors {
+	in.Username = strings.TrimSpace(in.Username)
+	fe := fieldErrors{}
+	fe.check(in.Username != "", "username", "用户名不能为空")
+	fe.check(in.Password != "", "password", "密码不能为空")
+	return fe
+}
+
+// 添加持仓/自选请求体
+type addFundInput struct {
+	PortfolioID uint    `json:"portfolio_id"` // 持仓所属组合，0 为默认组合
+	Code        string  `json:"code"`
+	Type        string  `json:"type"` // holding 或 watchlist
+	Shares      float64 `json:"shares"`
+	CostPrice   float64 `json:"cost_price"`
+	Fee         float64 `json:"fee"`
+	Date        string  `json:"date"` // 交易日期 2006-01-02，留空为今天
+
+	// 自选
+	GroupID uint     `json:"group_id"` // 0 为未分组
+	Note    string   `json:"note"`
+	Tags    []string `json:"tags"`
+}
+
+func (in *addFundInput) validate() fieldErrors {
+	in.Code = strings.TrimSpace(in.Code)
+	fe := fieldErrors{}
+	fe.check(fundCodePattern.MatchString(in.Code), "code", "基金代码应为 6 位数字")
+	fe.check(in.Type == "holding" || in.Type == "watchlist", "type", "type 只能是 holding 或 watchlist")
+	if in.Type == "holding" {
+		fe.check(in.Shares > 0, "shares", "份额必须大于 0")
+		fe.check(in.CostPrice > 0, "cost_price", "成本价必须大于 0")
+		fe.check(in.Fee >= 0, "fee", "手续费不能为负")
+		_, err := parseTradeDate(in.Date)
+		fe.check(err == nil, "date", "日期格式应为 2006-01-02")
+	} else {
+		in.Note = strings.TrimSpace(in.Note)
+		fe.check(utf8.RuneCountInString(in.Note) <= maxWatchNote, "note", fmt.Sprintf("备注最多 %d 个字符", maxWatchNote))
+		in.Tags = normalizeTags(fe, in.Tags)
+	}
+	return fe
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 自选分组、备注和标签的限制 (字符数)
+const (
+	maxWatchGroupName = 32
+	maxWatchNote      = 200
+	maxWatchTags      = 10
+	maxWatchTagLen    = 16
+)
+
+// normalizeTags 去掉空白和重复的标签并校验数量和长度
+func normalizeTags(fe fieldErrors, tags []string) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		fe.check(utf8.RuneCountInString(tag) <= maxWatchTagLen, "tags", fmt.Sprintf("每个标签最多 %d 个字符", maxWatchTagLen))
+		out = append(out, tag)
+	}
+	fe.check(len(out) <= maxWatchTags, "tags", fmt.Sprintf("最多 %d 个标签", maxWatchTags))
+	return out
+}
+
+// checkWatchGroup groupID 不为 0 时确认分组属于当前用户，不存在时写入 404 并返回 false
+func (h *Handler) checkWatchGroup(c *gin.Context, userID, groupID uint) bool {
+	if groupID == 0 {
+		return true
+	}
+	_, err := h.store.WatchlistGroups.Find(userID, groupID)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "分组不存在"})
+		return false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// quoteWatchlist 用 quotes 填充自选的最新行情
+func quoteWatchlist(watchlist []models.Watchlist, quotes map[string]*models.Quote) {
+	for i := range watchlist {
+		service.QuoteWatch(&watchlist[i], quotes[watchlist[i].FundCode])
+	}
+}
+
+// watchCodes 自选中的基金代码
+func watchCodes(watchlist []models.Watchlist) []string {
+	codes := make([]string, 0, len(watchlist))
+	for _, w := range watchlist {
+		codes = append(codes, w.FundCode)
+	}
+	return codes
+}
+
+// 自选列表 (带最新行情) 和分组，分组和组内均已按顺序排列
+func (h *Handler) GetWatchlist(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	groups, err := h.store.WatchlistGroups.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	watchlist, err := h.store.Watchlist.ListByUser(userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	quoteWatchlist(watchlist, service.FetchQuotes(watchCodes(watchlist)))
+	c.JSON(200, gin.H{"data": watchlist, "groups": groups})
+}
+
+// 修改自选的备注和标签，未传的字段保持不变 (移动分组使用 /watchlist/reorder)
+func (h *Handler) UpdateWatchlistItem(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		Code string    `json:"code"`
+		Note *string   `json:"note"`
+		Tags *[]string `json:"tags"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	entry, err := h.store.Watchlist.Find(userID, strings.TrimSpace(input.Code))
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "该基金不在自选中"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	fe := fieldErrors{}
+	if input.Note != nil {
+		entry.Note = strings.TrimSpace(*input.Note)
+		fe.check(utf8.RuneCountInString(entry.Note) <= maxWatchNote, "note", fmt.Sprintf("备注最多 %d 个字符", maxWatchNote))
+	}
+	if input.Tags != nil {
+		entry.Tags = normalizeTags(fe, *input.Tags)
+	}
+	if respondInvalid(c, fe) {
+		return
+	}
+	if err := h.store.Watchlist.Save(entry); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": entry})
+}
+
+// 拖动排序：codes 为拖动后 group_id 分组内的顺序，从其他分组拖入的基金一并移入该分组
+func (h *Handler) ReorderWatchlist(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		GroupID uint     `json:"group_id"` // 0 为未分组
+		Codes   []string `json:"codes"`
+	}
+	if !bindJSON(c, &input) || !h.checkWatchGroup(c, userID, input.GroupID) {
+		return
+	}
+	err := h.store.Atomic(func(s repo.Store) error {
+		return s.Watchlist.Reorder(userID, input.GroupID, input.Codes)
+	})
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(400, gin.H{"error": "codes 中有不在自选中的基金"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// watchGroupName 校验分组名称
+func watchGroupName(name string) (string, fieldErrors) {
+	name = strings.TrimSpace(name)
+	fe := fieldErrors{}
+	n := utf8.RuneCountInString(name)
+	fe.check(n > 0 && n <= maxWatchGroupName, "name", fmt.Sprintf("分组名称应为 1-%d 个字符", maxWatchGroupName))
+	return name, fe
+}
+
+// respondWatchGroupSaved 保存分组后的响应，重名返回 409
+func respondWatchGroupSaved(c *gin.Context, group *models.WatchlistGroup, err error) {
+	if errors.Is(err, repo.ErrDuplicate) {
+		c.JSON(409, gin.H{"error": "已有同名分组", "fields": fieldErrors{"name": "已有同名分组"}})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": group})
+}
+
+// 新建自选分组，排在最后
+func (h *Handler) CreateWatchlistGroup(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		Name string `json:"name"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	name, fe := watchGroupName(input.Name)
+	if respondInvalid(c, fe) {
+		return
+	}
+	group := models.WatchlistGroup{UserID: userID, Name: name}
+	respondWatchGroupSaved(c, &group, h.store.WatchlistGroups.Create(&group))
+}
+
+// 重命名自选分组
+func (h *Handler) UpdateWatchlistGroup(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	name, fe := watchGroupName(input.Name)
+	if respondInvalid(c, fe) {
+		return
+	}
+	group, err := h.store.WatchlistGroups.Find(userID, input.ID)
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "分组不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	group.Name = name
+	respondWatchGroupSaved(c, group, h.store.WatchlistGroups.Save(group))
+}
+
+// 删除自选分组，组内的基金移到未分组
+func (h *Handler) DeleteWatchlistGroup(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		ID uint `json:"id"`
+	}
+	if !bindJSON(c, &input) || !h.checkWatchGroup(c, userID, input.ID) {
+		return
+	}
+	err := h.store.Atomic(func(s repo.Store) error {
+		if err := s.Watchlist.Ungroup(userID, input.ID); err != nil {
+			return err
+		}
+		return s.WatchlistGroups.Delete(userID, input.ID)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// 分组拖动排序，ids 为拖动后的顺序
+func (h *Handler) ReorderWatchlistGroups(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	var input struct {
+		IDs []uint `json:"ids"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	err := h.store.Atomic(func(s repo.Store) error {
+		return s.WatchlistGroups.Reorder(userID, input.IDs)
+	})
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "分组不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// watchOrder 各分组内自选的代码 (按排序)
+func (e *testEnv) watchOrder() map[uint][]string {
+	e.t.Helper()
+	list, err := e.store.Watchlist.ListByUser(e.userID)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	order := make(map[uint][]string)
+	for _, w := range list {
+		order[w.GroupID] = append(order[w.GroupID], w.FundCode)
+	}
+	return order
+}
+
+// createWatchGroup 新建分组并返回 ID (分组使用 gorm.Model，JSON 中为 "ID")
+func (e *testEnv) createWatchGroup(name string) uint {
+	e.t.Helper()
+	status, resp := e.post("/watchlist/groups", gin.H{"name": name})
+	if status != 200 {
+		e.t.Fatalf("create group %s: %d %v", name, status, resp)
+	}
+	return uint(resp["data"].(map[string]interface{})["ID"].(float64))
+}
+
+func TestWatchlistReorderAcrossGroups(t *testing.T) {
+	e := newTestEnv(t)
+	for _, code := range []string{"110022", "161725", "510300", "513100"} {
+		if status, resp := e.post("/add", gin.H{"code": code, "type": "watchlist"}); status != 200 {
+			t.Fatalf("add %s: %d %v", code, status, resp)
+		}
+	}
+	consumer := e.createWatchGroup("消费")
+	index := e.createWatchGroup("指数")
+
+	reorder := func(group uint, codes ...string) {
+		t.Helper()
+		if status, resp := e.post("/watchlist/reorder", gin.H{"group_id": group, "codes": codes}); status != 200 {
+			t.Fatalf("reorder %v into %d: %d %v", codes, group, status, resp)
+		}
+	}
+	expect := func(want map[uint][]string) {
+		t.Helper()
+		if got := e.watchOrder(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("watchlist = %v, want %v", got, want)
+		}
+	}
+
+	// 从未分组拖入分组，未分组中剩下的保持原顺序
+	reorder(consumer, "161725", "110022")
+	reorder(index, "513100")
+	expect(map[uint][]string{0: {"510300"}, consumer: {"161725", "110022"}, index: {"513100"}})
+
+	// 拖入的排在最前，分组内其余的依次排在后面
+	reorder(consumer, "510300")
+	expect(map[uint][]string{consumer: {"510300", "161725", "110022"}, index: {"513100"}})
+	reorder(consumer, "110022", "510300")
+	expect(map[uint][]string{consumer: {"110022", "510300", "161725"}, index: {"513100"}})
+
+	// 有不在自选中的代码时整体不生效
+	status, _ := e.post("/watchlist/reorder", gin.H{"group_id": index, "codes": []string{"110022", "000001"}})
+	if status != 400 {
+		t.Fatalf("unknown code: %d, want 400", status)
+	}
+	expect(map[uint][]string{consumer: {"110022", "510300", "161725"}, index: {"513100"}})
+
+	// 删除分组后组内的基金依次排到未分组的最后
+	if status, resp := e.post("/watchlist/groups/delete", gin.H{"id": index}); status != 200 {
+		t.Fatalf("delete group: %d %v", status, resp)
+	}
+	if status, resp := e.post("/watchlist/groups/delete", gin.H{"id": consumer}); status != 200 {
+		t.Fatalf("delete group: %d %v", status, resp)
+	}
+	expect(map[uint][]string{0: {"513100", "110022", "510300", "161725"}})
+}
+
+func TestWatchlistGroups(t *testing.T) {
+	e := newTestEnv(t)
+	first := e.createWatchGroup("消费")
+	second := e.createWatchGroup("指数")
+
+	// 重名 (新建或改名) 返回 409
+	if status, _ := e.post("/watchlist/groups", gin.H{"name": " 消费 "}); status != 409 {
+		t.Fatalf("duplicate create: %d, want 409", status)
+	}
+	if status, _ := e.post("/watchlist/groups/update", gin.H{"id": second, "name": "消费"}); status != 409 {
+		t.Fatalf("duplicate rename: %d, want 409", status)
+	}
+
+	if status, resp := e.post("/watchlist/groups/reorder", gin.H{"ids": []uint{second, first}}); status != 200 {
+		t.Fatalf("reorder groups: %d %v", status, resp)
+	}
+	groups, err := e.store.WatchlistGroups.ListByUser(e.userID)
+	if err != nil || len(groups) != 2 || groups[0].ID != second || groups[1].ID != first {
+		t.Fatalf("groups = %+v, %v", groups, err)
+	}
+	if status, _ := e.post("/watchlist/groups/reorder", gin.H{"ids": []uint{second, first + second + 1}}); status != 404 {
+		t.Fatalf("unknown group: %d, want 404", status)
+	}
+}
+
+func TestAddWatchIsIdempotent(t *testing.T) {
+	e := newTestEnv(t)
+	group := e.createWatchGroup("消费")
+	if status, resp := e.post("/add", gin.H{"code": "110022", "type": "watchlist", "group_id": group, "note": "第一次"}); status != 200 {
+		t.Fatalf("add: %d %v", status, resp)
+	}
+	// 重复添加成功返回，不产生第二条，也不改动已有的分组和备注
+	if status, resp := e.post("/add", gin.H{"code": "110022", "type": "watchlist", "note": "第二次"}); status != 200 {
+		t.Fatalf("second add: %d %v", status, resp)
+	}
+	list, err := e.store.Watchlist.ListByUser(e.userID)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("watchlist = %+v, %v", list, err)
+	}
+	if list[0].GroupID != group || list[0].Note != "第一次" {
+		t.Fatalf("entry = %+v, want the first add kept", list[0])
+	}
+}
@@ -0,0 +1,374 @@
+// Package config 服务端配置：默认值 -> 配置文件 (JSON) -> 环境变量，启动时统一校验
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fund-tracker-server/internal/notify"
+	"fund-tracker-server/internal/service"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration 支持 "15s"、"10m" 这样的写法
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("时长应写成字符串，如 \"15s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Std 转为 time.Duration
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+// Config 全部配置项
+type Config struct {
+	Listen      string           `json:"listen"`       // 监听地址，如 ":8080"
+	DebugListen string           `json:"debug_listen"` // 运行指标 /debug/vars 的内部监听地址，如 "127.0.0.1:6060"，为空时不开启
+	DB          DBConfig         `json:"db"`
+	JWT         JWTConfig        `json:"jwt"`
+	CORS        CORSConfig       `json:"cors"`
+	Upstream    service.Upstream `json:"upstream"`
+	Fetch       FetchConfig      `json:"fetch"`
+	RateLimit   RateLimitConfig  `json:"rate_limit"`
+	Alerts      AlertsConfig     `json:"alerts"`
+	Notify      NotifyConfig     `json:"notify"`
+}
+
+// DBConfig 数据库
+type DBConfig struct {
+	Driver string `json:"driver"` // postgres (默认) 或 sqlite
+	DSN    string `json:"dsn"`    // sqlite 为文件路径，":memory:" 为内存库
+}
+
+// JWTConfig 登录令牌
+type JWTConfig struct {
+	Secret     string   `json:"secret"`
+	AccessTTL  Duration `json:"access_ttl"`  // 访问令牌有效期，过期后用刷新令牌换发
+	RefreshTTL Duration `json:"refresh_ttl"` // 刷新令牌有效期，即免登录时长
+
+	// 已废弃：旧版的单一令牌有效期 (jwt.ttl / FUND_JWT_TTL)。
+	// 没有显式设置 access_ttl 时作为访问令牌有效期，不调用 /refresh 的旧客户端登录时长保持不变
+	LegacyTTL Duration `json:"ttl"`
+}
+
+// CORSConfig 跨域
+type CORSConfig struct {
+	AllowOrigins []string `json:"allow_origins"` // ["*"] 表示允许任意来源
+}
+
+// FetchConfig 上游抓取
+type FetchConfig struct {
+	Timeout         Duration `json:"timeout"`           // 单次 HTTP 请求超时
+	Concurrency     int      `json:"concurrency"`       // 批量抓取的最大并发
+	CacheTTLTrading Duration `json:"cache_ttl_trading"` // 交易时段行情缓存
+	CacheTTLClosed  Duration `json:"cache_ttl_closed"`  // 非交易时段行情缓存
+	PollInterval    Duration `json:"poll_interval"`     // WebSocket 行情推送轮询间隔
+	Providers       []string `json:"providers"`         // 行情数据源优先级，同类型的按顺序尝试
+}
+
+// Budget 限流额度：per 时间内最多 requests 次
+type Budget struct {
+	Requests int      `json:"requests"`
+	Per      Duration `json:"per"`
+}
+
+// RateLimitConfig 限流和登录失败锁定
+type RateLimitConfig struct {
+	Login    Budget `json:"login"`    // 按 IP
+	Register Budget `json:"register"` // 按 IP
+	Refresh  Budget `json:"refresh"`  // 按 IP
+	Search   Budget `json:"search"`   // 按用户
+	History  Budget `json:"history"`  // 按用户，没有存量数据时会触发上游回填
+
+	// 按用户：测试通知渠道、发送和校验邮箱确认码共用，避免被用来向任意地址发信
+	NotifyTest Budget `json:"notify_test"`
+
+	LockoutThreshold int `json:"lockout_threshold"` // 同一用户名+IP 连续登录失败多少次后锁定
+	// 同一用户名不论来源 IP 连续失败多少次后锁定，防止轮换 IP 猜密码；应高于 lockout_threshold
+	AccountLockoutThreshold int      `json:"account_lockout_threshold"`
+	LockoutBase             Duration `json:"lockout_base"` // 首次锁定时长，之后每次失败翻倍
+	LockoutMax              Duration `json:"lockout_max"`  // 最长锁定时长
+
+	// 可信的反向代理地址，按 IP 限流时只信任它们转发的 X-Forwarded-For；
+	// 为空时沿用 gin 的默认行为 (信任所有来源)
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// AlertsConfig 行情提醒
+type AlertsConfig struct {
+	Interval Duration `json:"interval"` // 后台检查提醒的间隔
+}
+
+// NotifyConfig 站外通知 (webhook、邮件)
+type NotifyConfig struct {
+	SMTP                 notify.SMTPConfig `json:"smtp"`
+	WebhookTimeout       Duration          `json:"webhook_timeout"`        // 单次 webhook 请求超时
+	AllowPrivateWebhooks bool              `json:"allow_private_webhooks"` // 允许 webhook 指向内网地址，仅用于本地调试
+	RetryAttempts        int               `json:"retry_attempts"`         // 每个渠道最多尝试的次数
+	RetryBase            Duration          `json:"retry_base"`             // 首次重试前的等待，之后每次翻倍
+	RetryMax             Duration          `json:"retry_max"`              // 最长等待
+	DigestConcurrency    int               `json:"digest_concurrency"`     // 每日简报同时投递的用户数
+}
+
+// Default 默认配置，数据库 DSN 和 JWT 密钥没有默认值，必须显式提供
+func Default() Config {
+	return Config{
+		Listen:   ":8080",
+		DB:       DBConfig{Driver: "postgres"},
+		JWT:      JWTConfig{AccessTTL: Duration(15 * time.Minute), RefreshTTL: Duration(30 * 24 * time.Hour)},
+		CORS:     CORSConfig{AllowOrigins: []string{"*"}},
+		Upstream: service.DefaultUpstream(),
+		Fetch: FetchConfig{
+			Timeout:         Duration(5 * time.Second),
+			Concurrency:     5,
+			CacheTTLTrading: Duration(15 * time.Second),
+			CacheTTLClosed:  Duration(10 * time.Minute),
+			PollInterval:    Duration(10 * time.Second),
+			Providers:       service.ProviderChain(),
+		},
+		RateLimit: RateLimitConfig{
+			Login:                   Budget{Requests: 10, Per: Duration(time.Minute)},
+			Register:                Budget{Requests: 5, Per: Duration(time.Hour)},
+			Refresh:                 Budget{Requests: 30, Per: Duration(time.Minute)},
+			Search:                  Budget{Requests: 30, Per: Duration(time.Minute)},
+			History:                 Budget{Requests: 60, Per: Duration(time.Minute)},
+			NotifyTest:              Budget{Requests: 5, Per: Duration(10 * time.Minute)},
+			LockoutThreshold:        5,
+			AccountLockoutThreshold: 20,
+			LockoutBase:             Duration(time.Minute),
+			LockoutMax:              Duration(time.Hour),
+		},
+		Alerts: AlertsConfig{Interval: Duration(time.Minute)},
+		Notify: NotifyConfig{
+			SMTP:           notify.SMTPConfig{Port: 587},
+			WebhookTimeout: Duration(10 * time.Second),
+			RetryAttempts:  4,
+			RetryBase:      Duration(5 * time.Second),
+			RetryMax:       Duration(2 * time.Minute),
+
+			DigestConcurrency: 8,
+		},
+	}
+}
+
+// Load 读取配置。path 为空时尝试当前目录的 config.json (不存在则跳过)，
+// 随后应用 FUND_* 环境变量并校验
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = "config.json"
+	}
+	// 记录 access_ttl 是否显式配置，决定旧的 jwt.ttl 是否生效
+	var explicitTTL struct {
+		JWT struct {
+			AccessTTL *Duration `json:"access_ttl"`
+		} `json:"jwt"`
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+		json.Unmarshal(data, &explicitTTL)
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	_, accessEnv := os.LookupEnv("FUND_JWT_ACCESS_TTL")
+	if warning := cfg.JWT.applyLegacyTTL(explicitTTL.JWT.AccessTTL != nil || accessEnv); warning != "" {
+		fmt.Println("⚠️", warning)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyLegacyTTL 把已废弃的 ttl 映射为访问令牌有效期 (accessSet 为 true 时以 access_ttl 为准)，返回需要提示的警告
+func (j *JWTConfig) applyLegacyTTL(accessSet bool) string {
+	if j.LegacyTTL <= 0 {
+		return ""
+	}
+	if accessSet {
+		return "jwt.ttl (FUND_JWT_TTL) 已废弃且被 jwt.access_ttl 覆盖，请删除该配置"
+	}
+	j.AccessTTL = j.LegacyTTL
+	return fmt.Sprintf("jwt.ttl (FUND_JWT_TTL) 已废弃，暂作为 jwt.access_ttl 使用 (%s)，请改为配置 jwt.access_ttl 和 jwt.refresh_ttl", j.LegacyTTL.Std())
+}
+
+// applyEnv 环境变量覆盖配置文件
+func applyEnv(cfg *Config) error {
+	strs := map[string]*string{
+		"FUND_LISTEN":           &cfg.Listen,
+		"FUND_DEBUG_LISTEN":     &cfg.DebugListen,
+		"FUND_DB_DRIVER":        &cfg.DB.Driver,
+		"FUND_DB_DSN":           &cfg.DB.DSN,
+		"FUND_JWT_SECRET":       &cfg.JWT.Secret,
+		"FUND_UPSTREAM_PUSH2":   &cfg.Upstream.Push2,
+		"FUND_UPSTREAM_FUNDGZ":  &cfg.Upstream.FundGZ,
+		"FUND_UPSTREAM_F10":     &cfg.Upstream.F10,
+		"FUND_UPSTREAM_SEARCH":  &cfg.Upstream.Search,
+		"FUND_UPSTREAM_FUNDMOB": &cfg.Upstream.FundMob,
+		"FUND_SMTP_HOST":        &cfg.Notify.SMTP.Host,
+		"FUND_SMTP_USERNAME":    &cfg.Notify.SMTP.Username,
+		"FUND_SMTP_PASSWORD":    &cfg.Notify.SMTP.Password,
+		"FUND_SMTP_FROM":        &cfg.Notify.SMTP.From,
+	}
+	for key, dst := range strs {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+
+	durations := map[string]*Duration{
+		"FUND_JWT_ACCESS_TTL":          &cfg.JWT.AccessTTL,
+		"FUND_JWT_REFRESH_TTL":         &cfg.JWT.RefreshTTL,
+		"FUND_JWT_TTL":                 &cfg.JWT.LegacyTTL, // 已废弃，见 JWTConfig.LegacyTTL
+		"FUND_FETCH_TIMEOUT":           &cfg.Fetch.Timeout,
+		"FUND_FETCH_CACHE_TTL_TRADING": &cfg.Fetch.CacheTTLTrading,
+		"FUND_FETCH_CACHE_TTL_CLOSED":  &cfg.Fetch.CacheTTLClosed,
+		"FUND_FETCH_POLL_INTERVAL":     &cfg.Fetch.PollInterval,
+		"FUND_ALERTS_INTERVAL":         &cfg.Alerts.Interval,
+	}
+	for key, dst := range durations {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s: %w", key, err)
+			}
+			*dst = Duration(d)
+		}
+	}
+
+	ints := map[string]*int{
+		"FUND_FETCH_CONCURRENCY":         &cfg.Fetch.Concurrency,
+		"FUND_SMTP_PORT":                 &cfg.Notify.SMTP.Port,
+		"FUND_NOTIFY_DIGEST_CONCURRENCY": &cfg.Notify.DigestConcurrency,
+	}
+	for key, dst := range ints {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s: %w", key, err)
+			}
+			*dst = n
+		}
+	}
+	if v, ok := os.LookupEnv("FUND_NOTIFY_ALLOW_PRIVATE"); ok {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 FUND_NOTIFY_ALLOW_PRIVATE: %w", err)
+		}
+		cfg.Notify.AllowPrivateWebhooks = allow
+	}
+	if v, ok := os.LookupEnv("FUND_FETCH_PROVIDERS"); ok {
+		cfg.Fetch.Providers = splitList(v)
+	}
+	if v, ok := os.LookupEnv("FUND_CORS_ORIGINS"); ok {
+		cfg.CORS.AllowOrigins = splitList(v)
+	}
+	return nil
+}
+
+// splitList 解析逗号分隔的环境变量，忽略空项
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Validate 一次性列出所有不合法的配置项
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Listen != "", "listen 不能为空")
+	check(cfg.DebugListen == "" || cfg.DebugListen != cfg.Listen, "debug_listen 不能与 listen 相同，运行指标只在内部地址提供")
+	check(cfg.DB.Driver == "postgres" || cfg.DB.Driver == "sqlite", "db.driver 只能是 postgres 或 sqlite: %q", cfg.DB.Driver)
+	check(cfg.DB.DSN != "", "db.dsn 不能为空 (或设置 FUND_DB_DSN)")
+	check(len(cfg.JWT.Secret) >= 16, "jwt.secret 至少 16 个字符 (或设置 FUND_JWT_SECRET)")
+	check(cfg.JWT.AccessTTL > 0 && cfg.JWT.RefreshTTL > 0, "jwt.access_ttl / jwt.refresh_ttl 必须大于 0")
+	check(cfg.JWT.RefreshTTL > cfg.JWT.AccessTTL, "jwt.refresh_ttl 应大于 jwt.access_ttl")
+	check(len(cfg.CORS.AllowOrigins) > 0, "cors.allow_origins 不能为空")
+	for _, up := range []struct{ name, raw string }{
+		{"push2", cfg.Upstream.Push2},
+		{"fundgz", cfg.Upstream.FundGZ},
+		{"f10", cfg.Upstream.F10},
+		{"search", cfg.Upstream.Search},
+		{"fund_mob", cfg.Upstream.FundMob},
+	} {
+		u, err := url.Parse(up.raw)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"upstream.%s 不是合法的 http(s) 地址: %q", up.name, up.raw)
+	}
+	check(cfg.Fetch.Timeout > 0, "fetch.timeout 必须大于 0")
+	check(cfg.Fetch.Concurrency >= 1 && cfg.Fetch.Concurrency <= 50, "fetch.concurrency 应在 1-50 之间")
+	check(cfg.Fetch.CacheTTLTrading > 0 && cfg.Fetch.CacheTTLClosed > 0, "fetch.cache_ttl_* 必须大于 0")
+	check(cfg.Fetch.PollInterval >= Duration(time.Second), "fetch.poll_interval 至少 1s")
+	if err := service.CheckProviderChain(cfg.Fetch.Providers); err != nil {
+		errs = append(errs, fmt.Errorf("fetch.providers: %w", err))
+	}
+	for _, b := range []struct {
+		name   string
+		budget Budget
+	}{
+		{"login", cfg.RateLimit.Login},
+		{"register", cfg.RateLimit.Register},
+		{"refresh", cfg.RateLimit.Refresh},
+		{"search", cfg.RateLimit.Search},
+		{"history", cfg.RateLimit.History},
+		{"notify_test", cfg.RateLimit.NotifyTest},
+	} {
+		check(b.budget.Requests > 0 && b.budget.Per > 0, "rate_limit.%s 的 requests 和 per 必须大于 0", b.name)
+	}
+	check(cfg.RateLimit.LockoutThreshold > 0, "rate_limit.lockout_threshold 必须大于 0")
+	check(cfg.RateLimit.AccountLockoutThreshold >= cfg.RateLimit.LockoutThreshold,
+		"rate_limit.account_lockout_threshold 不能小于 lockout_threshold")
+	check(cfg.RateLimit.LockoutBase > 0 && cfg.RateLimit.LockoutMax >= cfg.RateLimit.LockoutBase,
+		"rate_limit.lockout_base 必须大于 0 且不超过 lockout_max")
+	check(cfg.Alerts.Interval >= Duration(time.Second), "alerts.interval 至少 1s")
+	if smtp := cfg.Notify.SMTP; smtp.Enabled() {
+		check(smtp.Port > 0 && smtp.Port <= 65535, "notify.smtp.port 不合法: %d", smtp.Port)
+		_, err := mail.ParseAddress(smtp.From)
+		check(err == nil, "notify.smtp.from 不是合法的邮箱地址: %q", smtp.From)
+	}
+	check(cfg.Notify.WebhookTimeout > 0, "notify.webhook_timeout 必须大于 0")
+	check(cfg.Notify.RetryAttempts >= 1 && cfg.Notify.RetryAttempts <= 10, "notify.retry_attempts 应在 1-10 之间")
+	check(cfg.Notify.DigestConcurrency >= 1, "notify.digest_concurrency 必须大于 0")
+	check(cfg.Notify.RetryBase > 0 && cfg.Notify.RetryMax >= cfg.Notify.RetryBase,
+		"notify.retry_base 必须大于 0 且不超过 retry_max")
+
+	return errors.Join(errs...)
+}
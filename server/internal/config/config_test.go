@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfig 写入临时配置文件，数据库和密钥之外的项由 jwt 决定
+func writeConfig(t *testing.T, jwt string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"db": {"driver": "sqlite", "dsn": ":memory:"}, "jwt": {"secret": "0123456789abcdef"` + jwt + `}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLegacyJWTTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		jwt     string
+		env     map[string]string
+		access  time.Duration
+		refresh time.Duration
+	}{
+		{"defaults", ``, nil, 15 * time.Minute, 720 * time.Hour},
+		{"legacy ttl keeps the old lifetime", `, "ttl": "168h"`, nil, 168 * time.Hour, 720 * time.Hour},
+		{"legacy env", ``, map[string]string{"FUND_JWT_TTL": "48h"}, 48 * time.Hour, 720 * time.Hour},
+		{"access_ttl wins", `, "ttl": "168h", "access_ttl": "30m"`, nil, 30 * time.Minute, 720 * time.Hour},
+		{"access env wins", `, "ttl": "168h"`, map[string]string{"FUND_JWT_ACCESS_TTL": "1h"}, time.Hour, 720 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load(writeConfig(t, tt.jwt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.JWT.AccessTTL.Std(); got != tt.access {
+				t.Errorf("access_ttl = %s, want %s", got, tt.access)
+			}
+			if got := cfg.JWT.RefreshTTL.Std(); got != tt.refresh {
+				t.Errorf("refresh_ttl = %s, want %s", got, tt.refresh)
+			}
+		})
+	}
+}
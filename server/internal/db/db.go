@@ -2,34 +2,92 @@ package db
 
 import (
 	"fmt"
-	"fund-tracker-server/internal/models"
 	"log"
+	"net/url"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func InitDB() {
-	// ✅ 这里已经填好了你提供的密码和数据库名
-	// user=postgres (默认用户名)
-	// password=05090510Aa (你的密码)
-	// dbname=Money_pg (你的数据库名)
-	dsn := "host=localhost user=postgres password=05090510Aa dbname=Money_pg port=5432 sslmode=disable TimeZone=Asia/Shanghai"
+// 支持的存储驱动
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite" // DSN 为文件路径，":memory:" 为内存库
+)
 
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Open 按驱动打开数据库 (不做迁移)
+func Open(driver, dsn string) (*gorm.DB, error) {
+	switch driver {
+	case DriverPostgres:
+		return gorm.Open(postgres.Open(dsn), gormConfig())
+	case DriverSQLite:
+		if dsn == ":memory:" {
+			// 所有连接共享同一个内存库
+			return openSQLite("file::memory:?cache=shared", true)
+		}
+		return openSQLite(dsn, false)
+	}
+	return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+}
+
+// OpenMemory 打开名为 name 的独立 sqlite 内存库并执行迁移，同名的库在进程内共享。
+// 供测试使用，不同 name 之间互不影响
+func OpenMemory(name string) (*gorm.DB, error) {
+	conn, err := openSQLite("file:"+url.PathEscape(name)+"?mode=memory&cache=shared", true)
 	if err != nil {
-		log.Fatal("❌ 数据库连接失败: ", err)
+		return nil, err
+	}
+	return conn, MigrateUp(conn)
+}
+
+func openSQLite(dsn string, memory bool) (*gorm.DB, error) {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	dsn += sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if !memory {
+		dsn += "&_pragma=journal_mode(WAL)"
 	}
+	conn, err := gorm.Open(sqlite.Open(dsn), gormConfig())
+	if err != nil {
+		return nil, err
+	}
+	if memory {
+		// 内存库只有一个连接，避免共享缓存的表锁冲突
+		sqlDB, err := conn.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+	return conn, nil
+}
 
-	fmt.Println("✅ 数据库连接成功！")
+// gormConfig 唯一约束等错误统一翻译为 gorm.ErrDuplicatedKey 等，与驱动无关
+func gormConfig() *gorm.Config {
+	return &gorm.Config{TranslateError: true}
+}
 
-	// 自动迁移：自动在数据库里创建 User, Holding, Watchlist 表
-	err = DB.AutoMigrate(&models.User{}, &models.Holding{}, &models.Watchlist{})
+// Connect 连接数据库并设置全局 DB，失败直接退出
+func Connect(driver, dsn string) {
+	var err error
+	DB, err = Open(driver, dsn)
 	if err != nil {
+		log.Fatal("❌ 数据库连接失败: ", err)
+	}
+	fmt.Println("✅ 数据库连接成功！", driver)
+}
+
+// InitDB 连接数据库并执行未执行的迁移，失败直接退出
+func InitDB(driver, dsn string) {
+	Connect(driver, dsn)
+	if err := MigrateUp(DB); err != nil {
 		log.Fatal("❌ 数据库迁移失败: ", err)
 	}
-	fmt.Println("✅ 数据库表结构同步完成")
+	fmt.Println("✅ 数据库表结构已是最新版本", LatestVersion())
 }
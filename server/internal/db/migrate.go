@@ -0,0 +1,200 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一个编号的数据库变更，Up/Down 在同一个事务里执行
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaVersion 已执行的迁移记录
+type SchemaVersion struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaVersion) TableName() string { return "schema_version" }
+
+// MigrationState 迁移及其执行状态
+type MigrationState struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// sortedMigrations 按版本号排序并检查编号唯一
+func sortedMigrations() []Migration {
+	list := append([]Migration(nil), migrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			panic(fmt.Sprintf("迁移版本号重复: %d", list[i].Version))
+		}
+	}
+	return list
+}
+
+// LatestVersion 代码中最新的迁移版本
+func LatestVersion() int {
+	list := sortedMigrations()
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+func appliedVersions(conn *gorm.DB) (map[int]SchemaVersion, error) {
+	if err := conn.AutoMigrate(&SchemaVersion{}); err != nil {
+		return nil, err
+	}
+	var rows []SchemaVersion
+	if err := conn.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaVersion)
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// MigrationStatus 列出所有迁移及是否已执行
+func MigrationStatus(conn *gorm.DB) ([]MigrationState, error) {
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+	var states []MigrationState
+	for _, m := range sortedMigrations() {
+		row, ok := applied[m.Version]
+		states = append(states, MigrationState{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: row.AppliedAt})
+	}
+	return states, nil
+}
+
+// CurrentVersion 已执行的最大版本号，未执行任何迁移时为 0
+func CurrentVersion(conn *gorm.DB) (int, error) {
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for v := range applied {
+		if v > current {
+			current = v
+		}
+	}
+	return current, nil
+}
+
+// MigrateUp 执行所有未执行的迁移
+func MigrateUp(conn *gorm.DB) error {
+	return MigrateTo(conn, LatestVersion())
+}
+
+// MigrateDown 回滚最近执行的一个迁移
+func MigrateDown(conn *gorm.DB) error {
+	current, err := CurrentVersion(conn)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+	target := 0
+	for _, m := range sortedMigrations() {
+		if m.Version < current {
+			target = m.Version
+		}
+	}
+	return MigrateTo(conn, target)
+}
+
+// MigrateTo 升级或回滚到指定版本 (0 表示回滚全部)
+func MigrateTo(conn *gorm.DB, target int) error {
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return err
+	}
+	list := sortedMigrations()
+	if target != 0 && !containsVersion(list, target) {
+		return fmt.Errorf("不存在的迁移版本: %d", target)
+	}
+
+	// 升级: 按顺序执行 <= target 且未执行的
+	for _, m := range list {
+		if m.Version > target || hasVersion(applied, m.Version) {
+			continue
+		}
+		if err := runMigration(conn, m, true); err != nil {
+			return err
+		}
+	}
+	// 回滚: 倒序撤销 > target 且已执行的
+	for i := len(list) - 1; i >= 0; i-- {
+		m := list[i]
+		if m.Version <= target || !hasVersion(applied, m.Version) {
+			continue
+		}
+		if err := runMigration(conn, m, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runMigration(conn *gorm.DB, m Migration, up bool) error {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if up {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaVersion{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}
+		if m.Down == nil {
+			return fmt.Errorf("迁移不可回滚")
+		}
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaVersion{}, m.Version).Error
+	})
+	if err != nil {
+		direction := "升级"
+		if !up {
+			direction = "回滚"
+		}
+		return fmt.Errorf("%s %04d_%s 失败: %w", direction, m.Version, m.Name, err)
+	}
+	if up {
+		fmt.Printf("⬆️  %04d_%s\n", m.Version, m.Name)
+	} else {
+		fmt.Printf("⬇️  %04d_%s\n", m.Version, m.Name)
+	}
+	return nil
+}
+
+func containsVersion(list []Migration, v int) bool {
+	for _, m := range list {
+		if m.Version == v {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVersion(applied map[int]SchemaVersion, v int) bool {
+	_, ok := applied[v]
+	return ok
+}
@@ -0,0 +1,547 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations 全部数据库变更，只能追加，不能修改已发布的迁移。
+// 表结构使用迁移内冻结的结构体，之后 models 的改动不会影响已有迁移
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		// 兼容之前由 AutoMigrate 建好的库: 已存在的表和列会被跳过
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0001User{}, &m0001Holding{}, &m0001Watchlist{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0001Watchlist{}, &m0001Holding{}, &m0001User{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_transactions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0002Transaction{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0002Transaction{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "holdings_realized_return",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&m0003Holding{}, "RealizedReturn") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&m0003Holding{}, "RealizedReturn")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&m0003Holding{}, "RealizedReturn")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create_nav_histories",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0004NavHistory{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0004NavHistory{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_portfolio_snapshots",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0005PortfolioSnapshot{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0005PortfolioSnapshot{})
+		},
+	},
+	{
+		Version: 6,
+		Name:    "holdings_backfill_null_amounts",
+		// 早期版本的 shares / cost_price 没有默认值，旧行可能为 NULL
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("UPDATE holdings SET shares = 0 WHERE shares IS NULL").Error; err != nil {
+				return err
+			}
+			return tx.Exec("UPDATE holdings SET cost_price = 0 WHERE cost_price IS NULL").Error
+		},
+		Down: func(tx *gorm.DB) error { return nil },
+	},
+	{
+		Version: 7,
+		Name:    "backfill_opening_transactions",
+		// 流水上线前的持仓没有交易记录，补一条期初转入使其可以重放
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`INSERT INTO transactions (created_at, updated_at, user_id, fund_code, type, trade_date, shares, price, fee, amount, note)
+				SELECT h.created_at, h.created_at, h.user_id, h.fund_code, 'transfer', h.created_at, h.shares, h.cost_price, 0, 0, ?
+				FROM holdings h
+				WHERE h.deleted_at IS NULL AND h.shares > 0
+				AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.user_id = h.user_id AND t.fund_code = h.fund_code)`,
+				openingNote).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DELETE FROM transactions WHERE type = 'transfer' AND note = ?", openingNote).Error
+		},
+	},
+	{
+		Version: 8,
+		Name:    "auth_tokens",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&m0008User{}, "TokenVersion") {
+				if err := tx.Migrator().AddColumn(&m0008User{}, "TokenVersion"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&m0008RefreshToken{}, &m0008RevokedToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&m0008RevokedToken{}, &m0008RefreshToken{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&m0008User{}, "TokenVersion")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "users_profile",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"DisplayName", "BaseCurrency"} {
+				if tx.Migrator().HasColumn(&m0009User{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&m0009User{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&m0009User{}, "BaseCurrency"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&m0009User{}, "DisplayName")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "portfolios",
+		// 每个用户建一个默认组合，已有的持仓和流水归入其中
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&m0010Portfolio{}); err != nil {
+				return err
+			}
+			for _, model := range []interface{}{&m0010Holding{}, &m0010Transaction{}} {
+				if tx.Migrator().HasColumn(model, "PortfolioID") {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(model, "PortfolioID"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(model, "PortfolioID"); err != nil {
+					return err
+				}
+			}
+			now := time.Now()
+			err := tx.Exec(`INSERT INTO portfolios (created_at, updated_at, user_id, name, currency, broker, notes)
+				SELECT ?, ?, u.id, ?, u.base_currency, '', '' FROM users u
+				WHERE u.deleted_at IS NULL AND NOT EXISTS (SELECT 1 FROM portfolios p WHERE p.user_id = u.id)`,
+				now, now, m0010DefaultName).Error
+			if err != nil {
+				return err
+			}
+			for _, table := range []string{"holdings", "transactions"} {
+				err := tx.Exec(`UPDATE ` + table + ` SET portfolio_id = (SELECT MIN(p.id) FROM portfolios p WHERE p.user_id = ` + table + `.user_id)
+					WHERE portfolio_id = 0`).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&m0010Transaction{}, &m0010Holding{}} {
+				if err := tx.Migrator().DropIndex(model, "PortfolioID"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(model, "PortfolioID"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&m0010Portfolio{})
+		},
+	},
+	{
+		Version: 11,
+		Name:    "watchlist_groups",
+		// 自选支持分组、排序、备注和标签；去掉重复的自选后加 (user_id, fund_code) 唯一索引。
+		// 去重不可回滚，Down 只撤销表结构
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&m0011WatchlistGroup{}); err != nil {
+				return err
+			}
+			for _, field := range []string{"FundName", "GroupID", "SortOrder", "Note", "Tags"} {
+				if tx.Migrator().HasColumn(&m0011Watchlist{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&m0011Watchlist{}, field); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&m0011Watchlist{}, "GroupID") {
+				if err := tx.Migrator().CreateIndex(&m0011Watchlist{}, "GroupID"); err != nil {
+					return err
+				}
+			}
+
+			// 旧版本的删除是软删除，残留的记录会占用唯一索引
+			for _, sql := range []string{
+				`DELETE FROM watchlists WHERE deleted_at IS NOT NULL`,
+				`DELETE FROM watchlists WHERE id NOT IN (SELECT MIN(id) FROM watchlists GROUP BY user_id, fund_code)`,
+				`UPDATE watchlists SET sort_order = id WHERE sort_order = 0`,
+			} {
+				if err := tx.Exec(sql).Error; err != nil {
+					return err
+				}
+			}
+			if tx.Migrator().HasIndex(&m0011Watchlist{}, "idx_watchlist_user_code") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&m0011Watchlist{}, "idx_watchlist_user_code")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&m0011Watchlist{}, "idx_watchlist_user_code"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&m0011Watchlist{}, "GroupID"); err != nil {
+				return err
+			}
+			for _, field := range []string{"Tags", "Note", "SortOrder", "GroupID", "FundName"} {
+				if err := tx.Migrator().DropColumn(&m0011Watchlist{}, field); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&m0011WatchlistGroup{})
+		},
+	},
+	{
+		Version: 12,
+		Name:    "alerts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0012Alert{}, &m0012AlertTrigger{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0012AlertTrigger{}, &m0012Alert{})
+		},
+	},
+	{
+		Version: 13,
+		Name:    "notify_channels",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0013NotifyChannel{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0013NotifyChannel{})
+		},
+	},
+	{
+		Version: 14,
+		Name:    "nav_estimates",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0014NavEstimate{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0014NavEstimate{})
+		},
+	},
+	{
+		Version: 15,
+		Name:    "nav_sync_states",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m0015NavSyncState{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m0015NavSyncState{})
+		},
+	},
+	{
+		// 已有的邮箱渠道没有经过确认，停用并等待用户重新确认
+		Version: 16,
+		Name:    "notify_channels_verification",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"Verified", "VerifyCode", "VerifyExpires"} {
+				if tx.Migrator().HasColumn(&m0016NotifyChannel{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&m0016NotifyChannel{}, field); err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("UPDATE notify_channels SET verified = ? WHERE kind = ?", true, "webhook").Error; err != nil {
+				return err
+			}
+			return tx.Exec("UPDATE notify_channels SET enabled = ? WHERE kind = ? AND verified = ?", false, "email", false).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range []string{"VerifyExpires", "VerifyCode", "Verified"} {
+				if err := tx.Migrator().DropColumn(&m0016NotifyChannel{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// 迁移 0007 补录的期初流水备注
+const openingNote = "期初持仓"
+
+// ---------------- 冻结的表结构 ----------------
+
+type m0001User struct {
+	gorm.Model
+	Username string `gorm:"uniqueIndex;not null"`
+	Password string `gorm:"not null"`
+}
+
+func (m0001User) TableName() string { return "users" }
+
+type m0001Holding struct {
+	gorm.Model
+	UserID    uint   `gorm:"index;not null"`
+	FundCode  string `gorm:"not null"`
+	FundName  string
+	Shares    float64 `gorm:"not null;default:0"`
+	CostPrice float64 `gorm:"not null;default:0"`
+	LastPrice string
+	Change    string
+}
+
+func (m0001Holding) TableName() string { return "holdings" }
+
+type m0001Watchlist struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null"`
+	FundCode string `gorm:"not null"`
+}
+
+func (m0001Watchlist) TableName() string { return "watchlists" }
+
+type m0002Transaction struct {
+	gorm.Model
+	UserID    uint      `gorm:"index;not null"`
+	FundCode  string    `gorm:"index;not null"`
+	Type      string    `gorm:"not null"`
+	TradeDate time.Time `gorm:"not null"`
+	Shares    float64   `gorm:"not null;default:0"`
+	Price     float64   `gorm:"not null;default:0"`
+	Fee       float64   `gorm:"not null;default:0"`
+	Amount    float64   `gorm:"not null;default:0"`
+	Note      string
+}
+
+func (m0002Transaction) TableName() string { return "transactions" }
+
+type m0003Holding struct {
+	RealizedReturn float64 `gorm:"not null;default:0"`
+}
+
+func (m0003Holding) TableName() string { return "holdings" }
+
+type m0004NavHistory struct {
+	ID         uint   `gorm:"primarykey"`
+	FundCode   string `gorm:"uniqueIndex:idx_nav_code_date;not null"`
+	Date       string `gorm:"uniqueIndex:idx_nav_code_date;size:10;not null"`
+	NAV        float64
+	AccNAV     float64
+	ChangeRate float64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (m0004NavHistory) TableName() string { return "nav_histories" }
+
+type m0005PortfolioSnapshot struct {
+	ID             uint   `gorm:"primarykey"`
+	UserID         uint   `gorm:"uniqueIndex:idx_snapshot_user_date_code;not null"`
+	Date           string `gorm:"uniqueIndex:idx_snapshot_user_date_code;size:10;not null"`
+	FundCode       string `gorm:"uniqueIndex:idx_snapshot_user_date_code;not null"`
+	Shares         float64
+	NAV            float64
+	NavStatus      string
+	Value          float64
+	Cost           float64
+	RealizedReturn float64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (m0005PortfolioSnapshot) TableName() string { return "portfolio_snapshots" }
+
+type m0008User struct {
+	TokenVersion int `gorm:"not null;default:0"`
+}
+
+func (m0008User) TableName() string { return "users" }
+
+type m0008RefreshToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"index;not null"`
+	FamilyID  string    `gorm:"index;size:32;not null"`
+	TokenHash string    `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (m0008RefreshToken) TableName() string { return "refresh_tokens" }
+
+type m0008RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:32"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+func (m0008RevokedToken) TableName() string { return "revoked_tokens" }
+
+type m0009User struct {
+	DisplayName  string
+	BaseCurrency string `gorm:"size:3;not null;default:CNY"`
+}
+
+func (m0009User) TableName() string { return "users" }
+
+// 迁移 0010 创建的默认组合名称
+const m0010DefaultName = "默认组合"
+
+type m0010Portfolio struct {
+	gorm.Model
+	UserID   uint   `gorm:"uniqueIndex:idx_portfolio_user_name;not null"`
+	Name     string `gorm:"uniqueIndex:idx_portfolio_user_name;size:64;not null"`
+	Currency string `gorm:"size:3;not null;default:CNY"`
+	Broker   string
+	Notes    string
+}
+
+func (m0010Portfolio) TableName() string { return "portfolios" }
+
+type m0010Holding struct {
+	PortfolioID uint `gorm:"index;not null;default:0"`
+}
+
+func (m0010Holding) TableName() string { return "holdings" }
+
+type m0010Transaction struct {
+	PortfolioID uint `gorm:"index;not null;default:0"`
+}
+
+func (m0010Transaction) TableName() string { return "transactions" }
+
+type m0011Watchlist struct {
+	UserID    uint   `gorm:"uniqueIndex:idx_watchlist_user_code;not null"`
+	FundCode  string `gorm:"uniqueIndex:idx_watchlist_user_code;not null"`
+	FundName  string
+	GroupID   uint `gorm:"index;not null;default:0"`
+	SortOrder int  `gorm:"not null;default:0"`
+	Note      string
+	Tags      []string `gorm:"serializer:json"`
+}
+
+func (m0011Watchlist) TableName() string { return "watchlists" }
+
+type m0011WatchlistGroup struct {
+	gorm.Model
+	UserID    uint   `gorm:"uniqueIndex:idx_watchgroup_user_name;not null"`
+	Name      string `gorm:"uniqueIndex:idx_watchgroup_user_name;size:32;not null"`
+	SortOrder int    `gorm:"not null;default:0"`
+}
+
+func (m0011WatchlistGroup) TableName() string { return "watchlist_groups" }
+
+type m0012Alert struct {
+	ID              uint    `gorm:"primarykey"`
+	UserID          uint    `gorm:"index;not null"`
+	FundCode        string  `gorm:"index;not null"`
+	Kind            string  `gorm:"not null"`
+	Threshold       float64 `gorm:"not null"`
+	PortfolioID     uint    `gorm:"not null;default:0"`
+	Note            string
+	Enabled         bool `gorm:"not null;default:true"`
+	Active          bool `gorm:"not null;default:false"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	LastTriggeredAt *time.Time
+}
+
+func (m0012Alert) TableName() string { return "alerts" }
+
+type m0012AlertTrigger struct {
+	ID        uint   `gorm:"primarykey"`
+	AlertID   uint   `gorm:"index;not null"`
+	UserID    uint   `gorm:"index;not null"`
+	FundCode  string `gorm:"not null"`
+	Kind      string `gorm:"not null"`
+	Threshold float64
+	Value     float64
+	QuoteTime string
+	Message   string
+	CreatedAt time.Time
+}
+
+func (m0012AlertTrigger) TableName() string { return "alert_triggers" }
+
+type m0013NotifyChannel struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"index;not null"`
+	Kind       string `gorm:"not null"`
+	Target     string `gorm:"not null"`
+	Secret     string
+	Events     []string `gorm:"serializer:json"`
+	Enabled    bool     `gorm:"not null;default:true"`
+	LastSentAt *time.Time
+	LastError  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (m0013NotifyChannel) TableName() string { return "notify_channels" }
+
+type m0014NavEstimate struct {
+	ID           uint   `gorm:"primarykey"`
+	FundCode     string `gorm:"uniqueIndex:idx_estimate_code_date;not null"`
+	Date         string `gorm:"uniqueIndex:idx_estimate_code_date;size:10;not null"`
+	Estimate     float64
+	EstimateRate float64
+	EstimateTime string
+	Confirmed    bool `gorm:"index;not null;default:false"`
+	NAV          float64
+	ChangeRate   float64
+	Error        float64
+	ErrorRate    float64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (m0014NavEstimate) TableName() string { return "nav_estimates" }
+
+type m0015NavSyncState struct {
+	FundCode     string `gorm:"primarykey"`
+	BackfillDone bool   `gorm:"not null;default:false"`
+	UpdatedAt    time.Time
+}
+
+func (m0015NavSyncState) TableName() string { return "nav_sync_states" }
+
+type m0016NotifyChannel struct {
+	Verified      bool `gorm:"not null;default:false"`
+	VerifyCode    string
+	VerifyExpires *time.Time
+}
+
+func (m0016NotifyChannel) TableName() string { return "notify_channels" }
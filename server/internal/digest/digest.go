@@ -0,0 +1,419 @@
+// Package digest 每日持仓简报：当日收益、涨跌幅靠前的基金、估值与确认净值的偏差和需要关注的事项
+package digest
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// TopMovers 涨跌幅榜的条数
+	TopMovers = 5
+	// 提醒的观测值与阈值的相对距离在此范围内时列为 "接近触发"
+	nearThreshold = 0.05
+)
+
+// 关注事项类型
+const (
+	EventPendingNav = "pending_nav" // 持仓当天的净值尚未公布，收益按估值计算
+	EventAlertNear  = "alert_near"  // 提醒接近触发
+)
+
+// Position 一只基金在所有组合中的合计持仓及当天表现
+type Position struct {
+	FundCode   string  `json:"fund_code"`
+	FundName   string  `json:"fund_name"`
+	Shares     float64 `json:"shares"`
+	NAV        float64 `json:"nav"`
+	ChangeRate float64 `json:"change_rate"` // 日涨跌幅 (%)
+	Value      float64 `json:"value"`
+	DayReturn  float64 `json:"day_return"`
+	NavStatus  string  `json:"nav_status"`
+}
+
+// Mover 持仓或自选中涨跌幅靠前的基金
+type Mover struct {
+	FundCode   string  `json:"fund_code"`
+	FundName   string  `json:"fund_name"`
+	NAV        float64 `json:"nav"`
+	ChangeRate float64 `json:"change_rate"`
+	NavStatus  string  `json:"nav_status"`
+	Holding    bool    `json:"holding"`
+	Watching   bool    `json:"watching"`
+}
+
+// Delta 盘中最后一次估值与确认净值的对比
+type Delta struct {
+	FundCode      string  `json:"fund_code"`
+	FundName      string  `json:"fund_name"`
+	Estimate      float64 `json:"estimate"`
+	EstimateRate  float64 `json:"estimate_rate"`
+	EstimateTime  string  `json:"estimate_time"`
+	Confirmed     float64 `json:"confirmed"`
+	ConfirmedRate float64 `json:"confirmed_rate"`
+	Error         float64 `json:"error"`      // 确认净值 - 估值
+	ErrorRate     float64 `json:"error_rate"` // 相对估值的偏差 (%)
+}
+
+// Event 需要关注的事项
+type Event struct {
+	Kind     string `json:"kind"`
+	FundCode string `json:"fund_code"`
+	Message  string `json:"message"`
+}
+
+// Digest 一个用户某一天的简报
+type Digest struct {
+	UserID      uint                     `json:"user_id"`
+	Date        string                   `json:"date"` // 2006-01-02
+	GeneratedAt time.Time                `json:"generated_at"`
+	Summary     service.PortfolioSummary `json:"summary"`
+	Positions   []Position               `json:"positions"`
+	Movers      []Mover                  `json:"movers"`
+	Deltas      []Delta                  `json:"deltas"`
+	Events      []Event                  `json:"events"`
+}
+
+// Empty 既没有持仓也没有取到自选行情，不值得发送
+func (d *Digest) Empty() bool {
+	return len(d.Positions) == 0 && len(d.Movers) == 0
+}
+
+// Build 生成 userID 在 date (2006-01-02) 的简报。
+// date 为今天时使用当前持仓，优先用已同步的确认净值，没有时用最新行情；
+// 更早的日期使用当天的持仓快照和历史净值，没有快照时持仓部分为空
+func Build(store repo.Store, userID uint, date string, now time.Time) (*Digest, error) {
+	today := date == now.Format("2006-01-02")
+	d := &Digest{
+		UserID:      userID,
+		Date:        date,
+		GeneratedAt: now,
+		Positions:   []Position{},
+		Movers:      []Mover{},
+		Deltas:      []Delta{},
+		Events:      []Event{},
+	}
+
+	current, err := store.Holdings.ListByUser(userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	watchlist, err := store.Watchlist.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := store.Alerts.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	for _, h := range current {
+		names[h.FundCode] = h.FundName
+	}
+	for _, w := range watchlist {
+		if names[w.FundCode] == "" {
+			names[w.FundCode] = w.FundName
+		}
+	}
+
+	var holdings []models.Holding
+	snapshotNAV := make(map[string]float64)
+	if today {
+		holdings = merge(current)
+	} else {
+		snapshots, err := store.Snapshots.OnDate(userID, date)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range snapshots {
+			// 已清仓的基金只有已实现收益，与今天的持仓一样不列出
+			if s.Shares <= 0 {
+				continue
+			}
+			h := models.Holding{FundCode: s.FundCode, Shares: s.Shares, CostPrice: s.Cost / s.Shares, RealizedReturn: s.RealizedReturn}
+			holdings = append(holdings, h)
+			snapshotNAV[s.FundCode] = s.NAV
+		}
+	}
+
+	var codes []string
+	seen := make(map[string]bool)
+	add := func(code string) {
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	for _, h := range holdings {
+		add(h.FundCode)
+	}
+	for _, w := range watchlist {
+		add(w.FundCode)
+	}
+	if today {
+		for _, a := range alerts {
+			if a.Enabled && !a.Active {
+				add(a.FundCode)
+			}
+		}
+	}
+
+	navs, err := store.NavHistory.OnDate(date, codes)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make(map[string]*models.Quote)
+	confirmed := make(map[string]models.NavHistory)
+	for _, n := range navs {
+		confirmed[n.FundCode] = n
+		quotes[n.FundCode] = service.HistoryQuote(n, names[n.FundCode])
+	}
+	var missing []string
+	for _, code := range codes {
+		if quotes[code] == nil {
+			missing = append(missing, code)
+		}
+	}
+	if today {
+		for code, q := range service.FetchQuotes(missing) {
+			quotes[code] = q
+		}
+	} else {
+		// 历史日期没有确认净值时只有快照里的估值，涨跌幅未知
+		day, _ := time.ParseInLocation("2006-01-02", date, now.Location())
+		for _, code := range missing {
+			if nav, ok := snapshotNAV[code]; ok && nav > 0 {
+				quotes[code] = &models.Quote{
+					FundCode: code,
+					Name:     names[code],
+					Price:    models.NewDecimal(nav, 4),
+					Time:     day,
+					Status:   models.PriceEstimated,
+				}
+			}
+		}
+	}
+
+	held := make(map[string]bool)
+	for i := range holdings {
+		h := &holdings[i]
+		h.FundName = names[h.FundCode]
+		service.ValueHolding(h, quotes[h.FundCode])
+		held[h.FundCode] = true
+		price, _ := strconv.ParseFloat(h.LastPrice, 64)
+		rate, _ := strconv.ParseFloat(h.Change, 64)
+		d.Positions = append(d.Positions, Position{
+			FundCode:   h.FundCode,
+			FundName:   h.FundName,
+			Shares:     h.Shares,
+			NAV:        price,
+			ChangeRate: rate,
+			Value:      h.TotalValue,
+			DayReturn:  h.DayReturn,
+			NavStatus:  h.NavStatus,
+		})
+		if h.NavStatus != service.NavConfirmed && h.NavStatus != service.NavRealtime {
+			d.Events = append(d.Events, Event{
+				Kind:     EventPendingNav,
+				FundCode: h.FundCode,
+				Message:  fmt.Sprintf("%s %s 的净值尚未公布，当日收益按估值计算", label(h.FundCode, h.FundName), date),
+			})
+		}
+	}
+	d.Summary = service.Summarize(holdings)
+	sort.SliceStable(d.Positions, func(i, j int) bool {
+		return math.Abs(d.Positions[i].DayReturn) > math.Abs(d.Positions[j].DayReturn)
+	})
+
+	d.Movers = movers(d.Positions, watchlist, quotes, held)
+	estimates, err := store.NavEstimates.OnDate(date, append(holdingCodes(holdings), watchCodes(watchlist)...))
+	if err != nil {
+		return nil, err
+	}
+	d.Deltas = deltas(estimates, confirmed, names)
+	// 提醒只对最新行情有意义
+	if today {
+		d.Events = append(d.Events, nearAlerts(alerts, quotes, current)...)
+	}
+	return d, nil
+}
+
+// merge 把同一只基金在多个组合中的持仓合并为一条，成本按份额加权
+func merge(holdings []models.Holding) []models.Holding {
+	index := make(map[string]int)
+	var merged []models.Holding
+	for _, h := range holdings {
+		if h.Shares <= 0 {
+			continue
+		}
+		i, ok := index[h.FundCode]
+		if !ok {
+			index[h.FundCode] = len(merged)
+			merged = append(merged, models.Holding{
+				FundCode:  h.FundCode,
+				FundName:  h.FundName,
+				LastPrice: h.LastPrice,
+				Change:    h.Change,
+			})
+			i = len(merged) - 1
+		}
+		m := &merged[i]
+		cost := m.CostPrice*m.Shares + h.CostPrice*h.Shares
+		m.Shares += h.Shares
+		m.CostPrice = cost / m.Shares
+		m.RealizedReturn += h.RealizedReturn
+	}
+	return merged
+}
+
+// movers 持仓和自选中按涨跌幅绝对值排序的前 TopMovers 只
+func movers(positions []Position, watchlist []models.Watchlist, quotes map[string]*models.Quote, held map[string]bool) []Mover {
+	watching := make(map[string]bool)
+	for _, w := range watchlist {
+		watching[w.FundCode] = true
+	}
+	list := []Mover{}
+	for _, p := range positions {
+		if quotes[p.FundCode] == nil {
+			continue
+		}
+		list = append(list, Mover{
+			FundCode: p.FundCode, FundName: p.FundName, NAV: p.NAV, ChangeRate: p.ChangeRate,
+			NavStatus: p.NavStatus, Holding: true, Watching: watching[p.FundCode],
+		})
+	}
+	for _, w := range watchlist {
+		info := quotes[w.FundCode]
+		if held[w.FundCode] || info == nil {
+			continue
+		}
+		service.QuoteWatch(&w, info)
+		price, _ := strconv.ParseFloat(w.LastPrice, 64)
+		rate, _ := strconv.ParseFloat(w.Change, 64)
+		list = append(list, Mover{
+			FundCode: w.FundCode, FundName: w.FundName, NAV: price, ChangeRate: rate,
+			NavStatus: w.NavStatus, Watching: true,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return math.Abs(list[i].ChangeRate) > math.Abs(list[j].ChangeRate)
+	})
+	if len(list) > TopMovers {
+		list = list[:TopMovers]
+	}
+	return list
+}
+
+// deltas 收盘估值与同一天确认净值的偏差 (估值由后台任务在收盘后记录)，按偏差绝对值降序
+func deltas(estimates []models.NavEstimate, confirmed map[string]models.NavHistory, names map[string]string) []Delta {
+	list := []Delta{}
+	for _, e := range estimates {
+		nav, ok := confirmed[e.FundCode]
+		if !ok {
+			continue
+		}
+		service.ConfirmEstimate(&e, nav)
+		list = append(list, Delta{
+			FundCode:      e.FundCode,
+			FundName:      names[e.FundCode],
+			Estimate:      e.Estimate,
+			EstimateRate:  e.EstimateRate,
+			EstimateTime:  e.EstimateTime,
+			Confirmed:     e.NAV,
+			ConfirmedRate: e.ChangeRate,
+			Error:         e.Error,
+			ErrorRate:     e.ErrorRate,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return math.Abs(list[i].ErrorRate) > math.Abs(list[j].ErrorRate)
+	})
+	return list
+}
+
+// nearAlerts 尚未触发但观测值已接近阈值的提醒，holdings 为当前持仓 (用于收益率目标)
+func nearAlerts(alerts []models.Alert, quotes map[string]*models.Quote, holdings []models.Holding) []Event {
+	var events []Event
+	for i := range alerts {
+		a := &alerts[i]
+		info := quotes[a.FundCode]
+		if !a.Enabled || a.Active || info == nil || a.Threshold == 0 {
+			continue
+		}
+		var matched []models.Holding
+		if a.Kind == models.AlertReturnTarget {
+			for _, h := range holdings {
+				if h.FundCode == a.FundCode && h.Shares > 0 && (a.PortfolioID == 0 || h.PortfolioID == a.PortfolioID) {
+					matched = append(matched, h)
+				}
+			}
+		}
+		value, hit, ok := service.EvaluateAlert(a, info, matched)
+		if !ok || hit {
+			continue
+		}
+		// 涨跌幅和溢价率按绝对值比较
+		observed := value
+		if a.Kind == models.AlertChange || a.Kind == models.AlertPremium {
+			observed = math.Abs(value)
+		}
+		if math.Abs(observed-a.Threshold) > math.Abs(a.Threshold)*nearThreshold {
+			continue
+		}
+		events = append(events, Event{
+			Kind:     EventAlertNear,
+			FundCode: a.FundCode,
+			Message: fmt.Sprintf("%s 的%s提醒接近触发: 当前 %s，阈值 %s",
+				label(a.FundCode, info.Name), alertLabels[a.Kind], alertValue(a.Kind, value), alertValue(a.Kind, a.Threshold)),
+		})
+	}
+	return events
+}
+
+// 提醒类型的中文名称
+var alertLabels = map[string]string{
+	models.AlertPriceAbove:   "价格上限",
+	models.AlertPriceBelow:   "价格下限",
+	models.AlertChange:       "涨跌幅",
+	models.AlertPremium:      "溢价率",
+	models.AlertReturnTarget: "收益率目标",
+}
+
+// alertValue 价格显示 4 位小数，其余为百分比
+func alertValue(kind string, v float64) string {
+	if kind == models.AlertPriceAbove || kind == models.AlertPriceBelow {
+		return fmt.Sprintf("%.4f", v)
+	}
+	return fmt.Sprintf("%+.2f%%", v)
+}
+
+func holdingCodes(holdings []models.Holding) []string {
+	codes := make([]string, 0, len(holdings))
+	for _, h := range holdings {
+		codes = append(codes, h.FundCode)
+	}
+	return codes
+}
+
+func watchCodes(watchlist []models.Watchlist) []string {
+	codes := make([]string, 0, len(watchlist))
+	for _, w := range watchlist {
+		codes = append(codes, w.FundCode)
+	}
+	return codes
+}
+
+// label 基金名称 (代码)，没有名称时只显示代码
+func label(code, name string) string {
+	if name == "" {
+		return code
+	}
+	return fmt.Sprintf("%s (%s)", name, code)
+}
@@ -0,0 +1,270 @@
+package digest
+
+import (
+	"errors"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// estimateProvider 按代码返回固定的盘中估值 (价格, 涨跌幅)，代替 fundgz
+type estimateProvider map[string][2]string
+
+func (p estimateProvider) Name() string              { return "test_digest_estimate" }
+func (p estimateProvider) Kind() service.QuoteKind   { return service.KindEstimate }
+func (p estimateProvider) Supports(code string) bool { return true }
+
+func (p estimateProvider) Fetch(code string) (*models.Quote, error) {
+	v, ok := p[code]
+	if !ok {
+		return nil, errors.New("no estimate")
+	}
+	price, _ := models.ParseDecimal(v[0])
+	rate, _ := models.ParseDecimal(v[1])
+	return &models.Quote{FundCode: code, Price: price, ChangeRate: rate, Time: time.Now()}, nil
+}
+
+func useEstimates(t *testing.T, p estimateProvider) {
+	t.Helper()
+	prev := service.ProviderChain()
+	service.RegisterProvider(p)
+	if err := service.SetProviderChain(p.Name()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.SetProviderChain(prev...) })
+}
+
+const userID = 1
+
+// seed 内存库：两个组合持有 110022，另持有 QDII 513100 和一只已清仓的基金，自选若干只，
+// 以及 date 当天的确认净值 (110022、161725) 和收盘估值
+func seed(t *testing.T, date string) (repo.Store, *gorm.DB) {
+	t.Helper()
+	conn, err := db.OpenMemory(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(v interface{}) {
+		t.Helper()
+		if err := conn.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	create(&[]models.Holding{
+		{UserID: userID, PortfolioID: 1, FundCode: "110022", FundName: "易方达<消费>", Shares: 1000, CostPrice: 3.5},
+		{UserID: userID, PortfolioID: 2, FundCode: "110022", FundName: "易方达<消费>", Shares: 1000, CostPrice: 3.7},
+		{UserID: userID, PortfolioID: 1, FundCode: "513100", FundName: "纳指ETF", Shares: 1000, CostPrice: 1.5},
+		{UserID: userID, PortfolioID: 1, FundCode: "000009", FundName: "已清仓", Shares: 0, RealizedReturn: 50},
+	})
+	for i, code := range []string{"110022", "161725", "510300", "000001", "000002", "000003"} {
+		create(&models.Watchlist{UserID: userID, FundCode: code, FundName: "自选" + code, SortOrder: i + 1})
+	}
+	create(&[]models.NavHistory{
+		{FundCode: "110022", Date: date, NAV: 3.8, ChangeRate: 2},
+		{FundCode: "161725", Date: date, NAV: 0.9, ChangeRate: -3.5},
+	})
+	create(&[]models.NavEstimate{
+		{FundCode: "110022", Date: date, Estimate: 3.7, EstimateRate: 0.5, EstimateTime: date + " 15:00"},
+		{FundCode: "161725", Date: date, Estimate: 0.91, EstimateRate: -2.5, EstimateTime: date + " 15:00"},
+		// 确认净值还没公布，不列入偏差
+		{FundCode: "513100", Date: date, Estimate: 1.55, EstimateRate: -1, EstimateTime: date + " 15:00"},
+	})
+	return repo.NewGormStore(conn), conn
+}
+
+func near(got, want float64) bool { return math.Abs(got-want) < 1e-6 }
+
+func codesOf[T any](list []T, code func(T) string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		out = append(out, code(v))
+	}
+	return out
+}
+
+func TestBuildToday(t *testing.T) {
+	const date = "2026-10-16"
+	now := time.Date(2026, 10, 16, 23, 10, 0, 0, service.ChinaTZ)
+	store, conn := seed(t, date)
+	useEstimates(t, estimateProvider{
+		"513100": {"1.55", "-1.00"},
+		"510300": {"4.00", "0.50"},
+		"000001": {"1.00", "1.00"},
+		"000002": {"1.00", "0.20"},
+		"000003": {"1.00", "-0.10"},
+	})
+	if err := conn.Create(&[]models.Alert{
+		{UserID: userID, FundCode: "110022", Kind: models.AlertPriceAbove, Threshold: 3.9, Enabled: true}, // 3.8 距 3.9 不到 5%
+		{UserID: userID, FundCode: "161725", Kind: models.AlertPriceAbove, Threshold: 2, Enabled: true},   // 差得远
+		{UserID: userID, FundCode: "510300", Kind: models.AlertChange, Threshold: 0.52, Enabled: true},    // |0.5| 接近 0.52
+		{UserID: userID, FundCode: "000001", Kind: models.AlertChange, Threshold: 1.02, Enabled: true, Active: true},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Build(store, userID, date, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 持仓：多个组合合并，清仓的不列出，按当日收益绝对值排序
+	if got := codesOf(d.Positions, func(p Position) string { return p.FundCode }); !reflect.DeepEqual(got, []string{"110022", "513100"}) {
+		t.Fatalf("positions = %v", got)
+	}
+	consumer, qdii := d.Positions[0], d.Positions[1]
+	if consumer.Shares != 2000 || consumer.NAV != 3.8 || consumer.NavStatus != service.NavConfirmed || !near(consumer.Value, 7600) {
+		t.Fatalf("110022 = %+v", consumer)
+	}
+	if !near(consumer.DayReturn, 7600-7600/1.02) {
+		t.Fatalf("110022 day return = %v", consumer.DayReturn)
+	}
+	if qdii.NavStatus != service.NavEstimated || !near(qdii.DayReturn, 1550-1550/0.99) {
+		t.Fatalf("513100 = %+v", qdii)
+	}
+	if s := d.Summary; !near(s.DayReturn, consumer.DayReturn+qdii.DayReturn) || !near(s.TotalValue, 9150) || !s.HasEstimate {
+		t.Fatalf("summary = %+v", s)
+	}
+
+	// 涨跌幅榜：持仓和自选按涨跌幅绝对值取前 5，同时持有和自选的只出现一次
+	want := []string{"161725", "110022", "513100", "000001", "510300"}
+	if got := codesOf(d.Movers, func(m Mover) string { return m.FundCode }); !reflect.DeepEqual(got, want) {
+		t.Fatalf("movers = %v, want %v", got, want)
+	}
+	if m := d.Movers[1]; !m.Holding || !m.Watching {
+		t.Fatalf("110022 mover = %+v, want holding and watching", m)
+	}
+	if m := d.Movers[0]; m.Holding || !m.Watching {
+		t.Fatalf("161725 mover = %+v, want watch only", m)
+	}
+
+	// 估值偏差：只有已公布确认净值的，按偏差绝对值排序
+	if got := codesOf(d.Deltas, func(x Delta) string { return x.FundCode }); !reflect.DeepEqual(got, []string{"110022", "161725"}) {
+		t.Fatalf("deltas = %v", got)
+	}
+	if x := d.Deltas[0]; !near(x.Error, 0.1) || !near(x.ErrorRate, 0.1/3.7*100) || x.Confirmed != 3.8 {
+		t.Fatalf("110022 delta = %+v", x)
+	}
+	if x := d.Deltas[1]; !near(x.ErrorRate, -0.01/0.91*100) {
+		t.Fatalf("161725 delta = %+v", x)
+	}
+
+	// 关注事项：QDII 净值未公布，两个未触发的提醒接近阈值
+	var events []string
+	for _, e := range d.Events {
+		events = append(events, e.Kind+":"+e.FundCode)
+	}
+	if want := []string{"pending_nav:513100", "alert_near:110022", "alert_near:510300"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestBuildPastDateFromSnapshots(t *testing.T) {
+	const date = "2026-10-15"
+	now := time.Date(2026, 10, 16, 23, 10, 0, 0, service.ChinaTZ)
+	store, conn := seed(t, date)
+	// 历史日期不访问行情，数据源取不到任何估值
+	useEstimates(t, estimateProvider{})
+	if err := conn.Create(&[]models.PortfolioSnapshot{
+		{UserID: userID, Date: date, FundCode: "110022", Shares: 1500, NAV: 3.8, NavStatus: service.NavConfirmed, Value: 5700, Cost: 5400},
+		{UserID: userID, Date: date, FundCode: "513100", Shares: 1000, NAV: 1.52, NavStatus: service.NavEstimated, Value: 1520, Cost: 1500},
+		{UserID: userID, Date: date, FundCode: "000009", Shares: 0, RealizedReturn: 50},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Create(&models.Alert{UserID: userID, FundCode: "110022", Kind: models.AlertPriceAbove, Threshold: 3.9, Enabled: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Build(store, userID, date, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 份额和成本来自当天的快照而不是当前持仓
+	if len(d.Positions) != 2 || d.Positions[0].FundCode != "110022" || d.Positions[0].Shares != 1500 {
+		t.Fatalf("positions = %+v", d.Positions)
+	}
+	if s := d.Summary; !near(s.TotalValue, 5700+1520) || !near(s.TotalCost, 6900) {
+		t.Fatalf("summary = %+v", s)
+	}
+	qdii := d.Positions[1]
+	if qdii.NAV != 1.52 || qdii.NavStatus != service.NavEstimated || qdii.DayReturn != 0 {
+		t.Fatalf("513100 = %+v, want the snapshot estimate with unknown change", qdii)
+	}
+	// 没有当天净值的自选不在涨跌幅榜中，提醒不参与历史日期
+	if got := codesOf(d.Movers, func(m Mover) string { return m.FundCode }); !reflect.DeepEqual(got, []string{"161725", "110022", "513100"}) {
+		t.Fatalf("movers = %v", got)
+	}
+	if len(d.Events) != 1 || d.Events[0].Kind != EventPendingNav || d.Events[0].FundCode != "513100" {
+		t.Fatalf("events = %+v", d.Events)
+	}
+
+	// 没有快照的日期持仓为空
+	d, err = Build(store, userID, "2026-10-14", now)
+	if err != nil || len(d.Positions) != 0 {
+		t.Fatalf("positions without snapshot = %+v, %v", d.Positions, err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := &Digest{
+		Date:    "2026-10-16",
+		Summary: service.PortfolioSummary{DayReturn: 133.36, DayReturnRate: 1.48, TotalValue: 9150, TotalReturn: 450, HasEstimate: true},
+		Positions: []Position{
+			{FundCode: "110022", FundName: "易方达<消费>", ChangeRate: 2, DayReturn: 149.02, Value: 7600, NavStatus: service.NavConfirmed},
+			{FundCode: "513100", ChangeRate: -1, DayReturn: -15.66, Value: 1550, NavStatus: service.NavEstimated},
+		},
+		Movers: []Mover{{FundCode: "161725", FundName: "白酒", ChangeRate: -3.5, Watching: true}},
+		Deltas: []Delta{{FundCode: "110022", FundName: "易方达<消费>", Estimate: 3.7, EstimateRate: 0.5, Confirmed: 3.8, ConfirmedRate: 2, ErrorRate: 2.7027}},
+		Events: []Event{{Kind: EventPendingNav, FundCode: "513100", Message: "513100 2026-10-16 的净值尚未公布，当日收益按估值计算"}},
+	}
+
+	text := d.Text()
+	for _, want := range []string{
+		"2026-10-16 持仓日报",
+		"当日收益 +133.36 (+1.48%)，总市值 9150.00，累计收益 +450.00",
+		"部分持仓按估值计算",
+		"易方达<消费> (110022)  +2.00%  当日 +149.02  市值 7600.00 [确]",
+		"513100  -1.00%  当日 -15.66  市值 1550.00 [估]",
+		"白酒 (161725)  -3.50%  自选",
+		"估值 3.7000 (+0.50%) -> 确认 3.8000 (+2.00%)，偏差 +2.70%",
+		"  - 513100 2026-10-16 的净值尚未公布",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+
+	page, err := d.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>2026-10-16 持仓日报</title>",
+		"易方达&lt;消费&gt; (110022)",                                // 名称经过转义
+		`<b style="color:#d32f2f">&#43;133.36 (&#43;1.48%)</b>`, // html/template 会转义 "+"
+		`<td align="right" style="color:#2e7d32">-1.00%</td>`,
+		"<h3>估值偏差</h3>",
+		"<li>513100 2026-10-16 的净值尚未公布，当日收益按估值计算</li>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("html missing %q", want)
+		}
+	}
+	if strings.Contains(page, "易方达<消费>") {
+		t.Error("html contains an unescaped fund name")
+	}
+
+	// 空的部分不输出标题
+	empty := (&Digest{Date: "2026-10-16"}).Text()
+	if strings.Contains(empty, "【") {
+		t.Errorf("empty digest text = %q", empty)
+	}
+}
@@ -0,0 +1,129 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"fund-tracker-server/internal/service"
+	"html/template"
+	"strings"
+)
+
+// 净值状态的简称
+var statusLabels = map[string]string{
+	service.NavConfirmed: "确",
+	service.NavEstimated: "估",
+	service.NavRealtime:  "实时",
+	service.NavCached:    "缓存",
+}
+
+// Title 通知标题
+func (d *Digest) Title() string {
+	return d.Date + " 持仓日报"
+}
+
+// Text 纯文本格式，用于邮件正文和 ?format=text
+func (d *Digest) Text() string {
+	var b strings.Builder
+	s := d.Summary
+	b.WriteString(d.Title() + "\n\n")
+	if len(d.Positions) > 0 {
+		fmt.Fprintf(&b, "当日收益 %+.2f (%+.2f%%)，总市值 %.2f，累计收益 %+.2f\n",
+			s.DayReturn, s.DayReturnRate, s.TotalValue, s.TotalReturn)
+		if s.HasEstimate {
+			b.WriteString("部分持仓按估值计算，确认净值公布后可能变化\n")
+		}
+		b.WriteString("\n【持仓】\n")
+		for _, p := range d.Positions {
+			fmt.Fprintf(&b, "  %s  %+.2f%%  当日 %+.2f  市值 %.2f [%s]\n",
+				label(p.FundCode, p.FundName), p.ChangeRate, p.DayReturn, p.Value, statusLabels[p.NavStatus])
+		}
+	}
+	if len(d.Movers) > 0 {
+		b.WriteString("\n【涨跌幅榜】\n")
+		for _, m := range d.Movers {
+			fmt.Fprintf(&b, "  %s  %+.2f%%  %s\n", label(m.FundCode, m.FundName), m.ChangeRate, moverTag(m))
+		}
+	}
+	if len(d.Deltas) > 0 {
+		b.WriteString("\n【估值偏差】\n")
+		for _, x := range d.Deltas {
+			fmt.Fprintf(&b, "  %s  估值 %.4f (%+.2f%%) -> 确认 %.4f (%+.2f%%)，偏差 %+.2f%%\n",
+				label(x.FundCode, x.FundName), x.Estimate, x.EstimateRate, x.Confirmed, x.ConfirmedRate, x.ErrorRate)
+		}
+	}
+	if len(d.Events) > 0 {
+		b.WriteString("\n【关注事项】\n")
+		for _, e := range d.Events {
+			b.WriteString("  - " + e.Message + "\n")
+		}
+	}
+	return b.String()
+}
+
+// moverTag 标明来自持仓还是自选
+func moverTag(m Mover) string {
+	if m.Holding {
+		return "持仓"
+	}
+	return "自选"
+}
+
+var htmlTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"label":  label,
+	"status": func(s string) string { return statusLabels[s] },
+	"tag":    moverTag,
+	"money":  func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"signed": func(v float64) string { return fmt.Sprintf("%+.2f", v) },
+	"pct":    func(v float64) string { return fmt.Sprintf("%+.2f%%", v) },
+	"nav":    func(v float64) string { return fmt.Sprintf("%.4f", v) },
+	"color": func(v float64) string {
+		// A 股习惯：红涨绿跌
+		switch {
+		case v > 0:
+			return "#d32f2f"
+		case v < 0:
+			return "#2e7d32"
+		}
+		return "#555"
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family:sans-serif;color:#222;max-width:640px">
+<h2>{{.Title}}</h2>
+{{if .Positions}}{{with .Summary}}<p>当日收益 <b style="color:{{color .DayReturn}}">{{signed .DayReturn}} ({{pct .DayReturnRate}})</b>，
+总市值 {{money .TotalValue}}，累计收益 <span style="color:{{color .TotalReturn}}">{{signed .TotalReturn}}</span></p>
+{{if .HasEstimate}}<p style="color:#888">部分持仓按估值计算，确认净值公布后可能变化</p>{{end}}{{end}}
+<h3>持仓</h3>
+<table cellpadding="4" style="border-collapse:collapse">
+<tr><th align="left">基金</th><th>涨跌幅</th><th>当日收益</th><th>市值</th><th>状态</th></tr>
+{{range .Positions}}<tr><td>{{label .FundCode .FundName}}</td>
+<td align="right" style="color:{{color .ChangeRate}}">{{pct .ChangeRate}}</td>
+<td align="right" style="color:{{color .DayReturn}}">{{signed .DayReturn}}</td>
+<td align="right">{{money .Value}}</td><td>{{status .NavStatus}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Movers}}<h3>涨跌幅榜</h3>
+<table cellpadding="4" style="border-collapse:collapse">
+{{range .Movers}}<tr><td>{{label .FundCode .FundName}}</td>
+<td align="right" style="color:{{color .ChangeRate}}">{{pct .ChangeRate}}</td><td>{{tag .}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Deltas}}<h3>估值偏差</h3>
+<table cellpadding="4" style="border-collapse:collapse">
+<tr><th align="left">基金</th><th>估值</th><th>确认净值</th><th>偏差</th></tr>
+{{range .Deltas}}<tr><td>{{label .FundCode .FundName}}</td>
+<td align="right">{{nav .Estimate}} ({{pct .EstimateRate}})</td>
+<td align="right">{{nav .Confirmed}} ({{pct .ConfirmedRate}})</td>
+<td align="right" style="color:{{color .ErrorRate}}">{{pct .ErrorRate}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Events}}<h3>关注事项</h3>
+<ul>{{range .Events}}<li>{{.Message}}</li>{{end}}</ul>{{end}}
+</body></html>
+`))
+
+// HTML 网页格式，用于邮件和 ?format=html
+func (d *Digest) HTML() (string, error) {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
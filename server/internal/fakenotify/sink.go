@@ -0,0 +1,75 @@
+// Package fakenotify 本地替身通知服务：记录收到的 webhook 请求 (Sink) 和邮件 (SMTPServer)，
+// 使 notify 包的 webhook、邮件和重试可以在无网络环境下端到端验证。
+package fakenotify
+
+import (
+	"fund-tracker-server/internal/notify"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Hook Sink 收到的一次 webhook 请求
+type Hook struct {
+	Header   http.Header
+	Body     []byte
+	Verified bool // 签名是否正确 (未设置 Secret 时为 false)
+	Status   int  // Sink 返回的状态码
+	At       time.Time
+}
+
+// Sink 记录 webhook 请求的 HTTP 服务，可以模拟失败
+type Sink struct {
+	Secret string // 用于校验签名，可在启动后设置
+
+	mu    sync.Mutex
+	hooks []Hook
+	fail  []int // 依次用于接下来的请求的状态码
+}
+
+// StartSink 在本机随机端口启动 Sink
+func StartSink(secret string) (*Sink, *httptest.Server) {
+	sink := &Sink{Secret: secret}
+	return sink, httptest.NewServer(sink)
+}
+
+// FailNext 接下来的请求依次返回这些状态码 (如 500、503)，用完后恢复 200
+func (s *Sink) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = append(s.fail, statuses...)
+}
+
+// Hooks 收到的全部请求 (含被模拟失败的)
+func (s *Sink) Hooks() []Hook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Hook(nil), s.hooks...)
+}
+
+// Delivered 成功接收 (返回 2xx) 的请求
+func (s *Sink) Delivered() []Hook {
+	var ok []Hook
+	for _, h := range s.Hooks() {
+		if h.Status < 300 {
+			ok = append(ok, h)
+		}
+	}
+	return ok
+}
+
+// ServeHTTP 记录请求并按 FailNext 返回状态码
+func (s *Sink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	s.mu.Lock()
+	status := http.StatusOK
+	if len(s.fail) > 0 {
+		status, s.fail = s.fail[0], s.fail[1:]
+	}
+	verified := s.Secret != "" && notify.Verify(s.Secret, r.Header.Get(notify.HeaderTimestamp), body, r.Header.Get(notify.HeaderSignature))
+	s.hooks = append(s.hooks, Hook{Header: r.Header.Clone(), Body: body, Verified: verified, Status: status, At: time.Now()})
+	s.mu.Unlock()
+	w.WriteHeader(status)
+}
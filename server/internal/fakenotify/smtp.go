@@ -0,0 +1,178 @@
+package fakenotify
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail SMTPServer 收到的一封邮件
+type Mail struct {
+	From string
+	To   []string
+	Data []byte // 原始报文 (已去掉 DATA 结尾的 ".")
+	At   time.Time
+}
+
+// Parse 解析报文头和正文
+func (m Mail) Parse() (*mail.Message, error) {
+	return mail.ReadMessage(strings.NewReader(string(m.Data)))
+}
+
+// SMTPServer 最小的 SMTP 服务：支持 EHLO/HELO、AUTH PLAIN、MAIL、RCPT、DATA、RSET、NOOP、QUIT，
+// 不支持 STARTTLS。接受任意用户名密码
+type SMTPServer struct {
+	Addr string // 监听地址，如 127.0.0.1:2525
+
+	ln     net.Listener
+	mu     sync.Mutex
+	mails  []Mail
+	reject []int // 依次用于接下来的 DATA 结果的错误码 (如 451、550)
+}
+
+// StartSMTP 在 addr 启动 (为空时使用本机随机端口)
+func StartSMTP(addr string) (*SMTPServer, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &SMTPServer{Addr: ln.Addr().String(), ln: ln}
+	go s.serve()
+	return s, nil
+}
+
+// Close 停止监听
+func (s *SMTPServer) Close() error { return s.ln.Close() }
+
+// Host / Port 拆开的监听地址，便于填入 notify.SMTPConfig
+func (s *SMTPServer) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+func (s *SMTPServer) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	var n int
+	fmt.Sscan(port, &n)
+	return n
+}
+
+// RejectNext 接下来的邮件依次以这些错误码拒收 (4xx 为临时错误，5xx 为永久错误)
+func (s *SMTPServer) RejectNext(codes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = append(s.reject, codes...)
+}
+
+// Mails 收到的全部邮件
+func (s *SMTPServer) Mails() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mails...)
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var current Mail
+	reply("220 fakenotify ESMTP")
+	for {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-fakenotify")
+			reply("250 AUTH PLAIN")
+		case "HELO":
+			reply("250 fakenotify")
+		case "AUTH":
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			current = Mail{From: addrArg(arg)}
+			reply("250 OK")
+		case "RCPT":
+			current.To = append(current.To, addrArg(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			code := 0
+			if len(s.reject) > 0 {
+				code, s.reject = s.reject[0], s.reject[1:]
+			}
+			if code == 0 {
+				current.Data, current.At = data, time.Now()
+				s.mails = append(s.mails, current)
+			}
+			s.mu.Unlock()
+			if code != 0 {
+				reply("%d rejected by fakenotify", code)
+			} else {
+				reply("250 OK queued")
+			}
+			current = Mail{}
+		case "RSET":
+			current = Mail{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// addrArg 从 "FROM:<a@b.c>" / "TO:<a@b.c>" 中取出地址
+func addrArg(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
+
+// readData 读取到单独一行的 "."，并还原行首的 "." 转义
+func readData(r *bufio.Reader) ([]byte, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return []byte(b.String()), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
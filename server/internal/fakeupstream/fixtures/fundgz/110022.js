@@ -0,0 +1 @@
+jsonpgz({"fundcode":"110022","name":"易方达消费行业股票","jzrq":"2026-10-15","dwjz":"3.6120","gsz":"3.6355","gszzl":"0.65","gztime":"2026-10-16 15:00"});
\ No newline at end of file
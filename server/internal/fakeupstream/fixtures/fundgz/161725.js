@@ -0,0 +1 @@
+jsonpgz({"fundcode":"161725","name":"招商中证白酒指数(LOF)A","jzrq":"2026-10-15","dwjz":"0.8064","gsz":"0.8121","gszzl":"0.71","gztime":"2026-10-16 15:00"});
\ No newline at end of file
@@ -0,0 +1 @@
+jsonpgz({"fundcode":"510300","name":"沪深300ETF华泰柏瑞","jzrq":"2026-10-15","dwjz":"4.0215","gsz":"4.0102","gszzl":"-0.28","gztime":"2026-10-16 15:00"});
\ No newline at end of file
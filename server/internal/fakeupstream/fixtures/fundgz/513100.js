@@ -0,0 +1 @@
+jsonpgz({"fundcode":"513100","name":"国泰纳斯达克100ETF","jzrq":"2026-10-15","dwjz":"1.7433","gsz":"1.7581","gszzl":"0.85","gztime":"2026-10-16 15:00"});
\ No newline at end of file
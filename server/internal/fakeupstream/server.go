@@ -0,0 +1,220 @@
+// Package fakeupstream 本地替身上游服务：用录制的 fixtures 模拟 service 包访问的全部 Eastmoney 接口，
+// 使 FetchQuote / SearchFund / FetchFundDetail 可以在无网络环境下端到端运行。
+package fakeupstream
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"fund-tracker-server/internal/service"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+)
+
+//go:embed fixtures
+var embedded embed.FS
+
+// Fixtures 内置的录制数据
+func Fixtures() fs.FS {
+	sub, _ := fs.Sub(embedded, "fixtures")
+	return sub
+}
+
+// Server 运行中的替身服务
+type Server struct {
+	*httptest.Server
+}
+
+// Start 使用内置 fixtures 启动替身服务
+func Start() *Server {
+	return StartWithFixtures(Fixtures())
+}
+
+// StartWithFixtures 使用自定义 fixtures 目录启动替身服务
+func StartWithFixtures(fixtures fs.FS) *Server {
+	return &Server{Server: httptest.NewServer(Handler(fixtures))}
+}
+
+// Upstream 所有上游地址都指向本服务，可直接传给 service.SetUpstream
+func (s *Server) Upstream() service.Upstream {
+	return UpstreamFor(s.URL)
+}
+
+// UpstreamFor 所有上游地址都指向 baseURL
+func UpstreamFor(baseURL string) service.Upstream {
+	return service.Upstream{
+		Push2:   baseURL,
+		FundGZ:  baseURL,
+		F10:     baseURL,
+		Search:  baseURL,
+		FundMob: baseURL,
+	}
+}
+
+// Handler 按 Eastmoney 的路径分发请求
+//
+// fixtures 目录结构:
+//
+//	fundgz/{code}.js     估值 JSONP 原文
+//	push2/stock.json     secid -> 行情字段 (f43, f58, f60, f170 ...)
+//	push2/ulist.json     股票代码 -> 行情字段 (f12, f14, f2, f3)
+//	f10/{code}.json      历史净值行 [{date, nav, acc_nav, rate}]，按日期倒序
+//	search.json          FundSearchAPI 完整响应，按 key 过滤
+//	fundmob/{code}.json  FundMNBasicInformation 完整响应
+func Handler(fixtures fs.FS) http.Handler {
+	h := &handler{fixtures: fixtures}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/js/", h.fundGZ)
+	mux.HandleFunc("/api/qt/stock/get", h.stock)
+	mux.HandleFunc("/api/qt/ulist.np/get", h.ulist)
+	mux.HandleFunc("/f10/F10DataApi.aspx", h.f10)
+	mux.HandleFunc("/FundSearch/api/FundSearchAPI.ashx", h.search)
+	mux.HandleFunc("/FundMNewApi/FundMNBasicInformation", h.fundMob)
+	return mux
+}
+
+type handler struct {
+	fixtures fs.FS
+}
+
+func (h *handler) fundGZ(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/js/"), ".js")
+	body, err := fs.ReadFile(h.fixtures, "fundgz/"+code+".js")
+	if err != nil {
+		// 线上对不存在的代码返回空的 JSONP
+		body = []byte("jsonpgz();")
+	}
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Write(body)
+}
+
+func (h *handler) stock(w http.ResponseWriter, r *http.Request) {
+	var all map[string]json.RawMessage
+	h.readJSON("push2/stock.json", &all)
+	data, ok := all[r.URL.Query().Get("secid")]
+	if !ok {
+		data = json.RawMessage("null")
+	}
+	writeJSON(w, map[string]interface{}{"rc": 0, "data": data})
+}
+
+func (h *handler) ulist(w http.ResponseWriter, r *http.Request) {
+	var all map[string]json.RawMessage
+	h.readJSON("push2/ulist.json", &all)
+	var diff []json.RawMessage
+	for _, secid := range strings.Split(r.URL.Query().Get("secids"), ",") {
+		parts := strings.SplitN(secid, ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if item, ok := all[parts[1]]; ok {
+			diff = append(diff, item)
+		}
+	}
+	if len(diff) == 0 {
+		writeJSON(w, map[string]interface{}{"rc": 0, "data": nil})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"rc": 0, "data": map[string]interface{}{"total": len(diff), "diff": diff}})
+}
+
+// navRow f10 fixture 中的一行
+type navRow struct {
+	Date   string `json:"date"`
+	NAV    string `json:"nav"`
+	AccNAV string `json:"acc_nav"`
+	Rate   string `json:"rate"`
+}
+
+// f10 渲染与线上一致的 `var apidata={ content:"<table>...",records:,pages:,curpage:};`
+func (h *handler) f10(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	per, _ := strconv.Atoi(q.Get("per"))
+	if page < 1 {
+		page = 1
+	}
+	if per < 1 {
+		per = 10
+	}
+	sdate, edate := q.Get("sdate"), q.Get("edate")
+
+	var all, rows []navRow
+	h.readJSON("f10/"+q.Get("code")+".json", &all)
+	for _, row := range all {
+		if (sdate != "" && row.Date < sdate) || (edate != "" && row.Date > edate) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	pages := (len(rows) + per - 1) / per
+	start, end := (page-1)*per, page*per
+	if start > len(rows) {
+		start = len(rows)
+	}
+	if end > len(rows) {
+		end = len(rows)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<table class='w782 comm lsjz'><thead><tr><th class='first'>净值日期</th><th>单位净值</th><th>累计净值</th><th>日增长率</th><th>申购状态</th><th>赎回状态</th><th class='tor last'>分红送配</th></tr></thead><tbody>")
+	if start == end {
+		sb.WriteString("<tr><td colspan='7' align='center'>暂无数据!</td></tr>")
+	}
+	for _, row := range rows[start:end] {
+		fmt.Fprintf(&sb, "<tr><td>%s</td><td class='tor bold'>%s</td><td class='tor bold'>%s</td><td class='tor bold'>%s</td><td>开放申购</td><td>开放赎回</td><td class='red unbold'></td></tr>",
+			row.Date, row.NAV, row.AccNAV, row.Rate)
+	}
+	sb.WriteString("</tbody></table>")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "var apidata={ content:\"%s\",records:%d,pages:%d,curpage:%d};", sb.String(), len(rows), pages, page)
+}
+
+func (h *handler) search(w http.ResponseWriter, r *http.Request) {
+	var result struct {
+		ErrCode int               `json:"ErrCode"`
+		ErrMsg  *string           `json:"ErrMsg"`
+		Datas   []json.RawMessage `json:"Datas"`
+	}
+	h.readJSON("search.json", &result)
+	key := r.URL.Query().Get("key")
+	var matched []json.RawMessage
+	for _, raw := range result.Datas {
+		var item struct {
+			CODE string `json:"CODE"`
+			NAME string `json:"NAME"`
+		}
+		json.Unmarshal(raw, &item)
+		if strings.Contains(item.CODE, key) || strings.Contains(item.NAME, key) {
+			matched = append(matched, raw)
+		}
+	}
+	result.Datas = matched
+	writeJSON(w, result)
+}
+
+func (h *handler) fundMob(w http.ResponseWriter, r *http.Request) {
+	body, err := fs.ReadFile(h.fixtures, "fundmob/"+r.URL.Query().Get("FCODE")+".json")
+	if err != nil {
+		body = []byte(`{"Datas":null,"ErrCode":0,"Success":true}`)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
+
+// readJSON 读取 fixture，文件不存在时保持 v 的零值
+func (h *handler) readJSON(name string, v interface{}) {
+	if body, err := fs.ReadFile(h.fixtures, name); err == nil {
+		json.Unmarshal(body, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/notify"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"fund-tracker-server/internal/ws"
+	"time"
+)
+
+// 单次站外通知的超时 (含重试)
+const notifyTimeout = 5 * time.Minute
+
+// notifier 提醒和简报的站外通知渠道，为 nil 时只通过 WebSocket 推送
+var notifier notify.Notifier
+
+// SetNotifier 设置后台任务使用的站外通知渠道
+func SetNotifier(n notify.Notifier) {
+	notifier = n
+}
+
+// StartAlertEvaluator 每隔 interval 用最新行情检查所有已启用的提醒，阻塞运行。
+// 非交易时段行情缓存较久，检查几乎没有开销，晚间公布的确认净值也能及时触发
+func StartAlertEvaluator(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if _, err := EvaluateAlerts(); err != nil {
+			fmt.Println("⚠️ 检查行情提醒失败:", err)
+		}
+	}
+}
+
+// EvaluateAlerts 检查一轮提醒，返回本轮触发的数量。
+// 条件从不满足变为满足时记录触发并推送，条件解除后重新生效
+func EvaluateAlerts() (int, error) {
+	store := repo.NewGormStore(db.DB)
+	alerts, err := store.Alerts.ListEnabled()
+	if err != nil || len(alerts) == 0 {
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	var codes []string
+	for _, a := range alerts {
+		if !seen[a.FundCode] {
+			seen[a.FundCode] = true
+			codes = append(codes, a.FundCode)
+		}
+	}
+	quotes := service.FetchQuotes(codes)
+
+	holdingsByUser := make(map[uint][]models.Holding)
+	fired := 0
+	for i := range alerts {
+		a := &alerts[i]
+		q, ok := quotes[a.FundCode]
+		if !ok {
+			continue
+		}
+		var holdings []models.Holding
+		if a.Kind == models.AlertReturnTarget {
+			all, ok := holdingsByUser[a.UserID]
+			if !ok {
+				if all, err = store.Holdings.ListByUser(a.UserID, 0); err != nil {
+					return fired, err
+				}
+				holdingsByUser[a.UserID] = all
+			}
+			for _, h := range all {
+				if h.FundCode == a.FundCode && h.Shares > 0 && (a.PortfolioID == 0 || h.PortfolioID == a.PortfolioID) {
+					holdings = append(holdings, h)
+				}
+			}
+		}
+
+		value, hit, ok := service.EvaluateAlert(a, q, holdings)
+		if !ok || hit == a.Active {
+			continue
+		}
+		if !hit {
+			if err := store.Alerts.SetActive(a.ID, false, nil); err != nil {
+				return fired, err
+			}
+			continue
+		}
+
+		now := time.Now()
+		trigger := models.AlertTrigger{
+			AlertID:   a.ID,
+			UserID:    a.UserID,
+			FundCode:  a.FundCode,
+			Kind:      a.Kind,
+			Threshold: a.Threshold,
+			Value:     value,
+			QuoteTime: q.LegacyTime(),
+			Message:   service.AlertMessage(a, q.Name, value),
+			CreatedAt: now,
+		}
+		err := store.Atomic(func(s repo.Store) error {
+			if err := s.Alerts.CreateTrigger(&trigger); err != nil {
+				return err
+			}
+			return s.Alerts.SetActive(a.ID, true, &now)
+		})
+		if err != nil {
+			return fired, err
+		}
+		deliverAlert(&trigger, a.Note)
+		fired++
+	}
+	return fired, nil
+}
+
+// deliverAlert 推送到用户的所有在线设备，并交给站外通知渠道
+func deliverAlert(trigger *models.AlertTrigger, note string) {
+	ws.Manager.SendJSON(trigger.UserID, map[string]interface{}{"type": "alert", "event": "triggered", "data": trigger})
+	if notifier == nil {
+		return
+	}
+	body := trigger.Message
+	if note != "" {
+		body += "\n备注: " + note
+	}
+	msg := notify.Message{UserID: trigger.UserID, Kind: notify.KindAlert, Title: "行情提醒: " + trigger.FundCode, Body: body, Data: trigger}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := notifier.Notify(ctx, msg); err != nil {
+			fmt.Printf("⚠️ 提醒 %d 的站外通知发送失败: %v\n", trigger.AlertID, err)
+		}
+	}()
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"sync"
+	"testing"
+)
+
+// priceProvider 返回可调整价格的确认净值，代替上游行情
+type priceProvider struct {
+	mu    sync.Mutex
+	price string
+}
+
+func (p *priceProvider) Name() string              { return "test_price" }
+func (p *priceProvider) Kind() service.QuoteKind   { return service.KindConfirmed }
+func (p *priceProvider) Supports(code string) bool { return true }
+
+func (p *priceProvider) Fetch(code string) (*models.Quote, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	price, err := models.ParseDecimal(p.price)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Quote{FundCode: code, Name: "测试基金", Price: price}, nil
+}
+
+// set 修改价格并清掉行情缓存
+func (p *priceProvider) set(code, price string) {
+	p.mu.Lock()
+	p.price = price
+	p.mu.Unlock()
+	service.InvalidateQuote(code)
+}
+
+func usePriceProvider(t *testing.T) *priceProvider {
+	t.Helper()
+	p := &priceProvider{price: "1"}
+	prev := service.ProviderChain()
+	service.RegisterProvider(p)
+	if err := service.SetProviderChain(p.Name()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.SetProviderChain(prev...) })
+	return p
+}
+
+func TestEvaluateAlertsEdgeTriggered(t *testing.T) {
+	useMemoryDB(t)
+	prices := usePriceProvider(t)
+	store := repo.NewGormStore(db.DB)
+
+	alert := models.Alert{UserID: 1, FundCode: "110022", Kind: models.AlertPriceAbove, Threshold: 1.5, Enabled: true}
+	if err := store.Alerts.Create(&alert); err != nil {
+		t.Fatal(err)
+	}
+	// 停用的提醒不检查
+	disabled := models.Alert{UserID: 1, FundCode: "110022", Kind: models.AlertPriceAbove, Threshold: 1, Enabled: false}
+	if err := store.Alerts.Create(&disabled); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, round := range []struct {
+		price  string
+		fired  int
+		active bool
+	}{
+		{"1.4", 0, false},
+		{"1.6", 1, true},  // 条件变为满足时触发
+		{"1.7", 0, true},  // 持续满足不重复触发
+		{"1.5", 0, true},  // 等于阈值仍算满足
+		{"1.4", 0, false}, // 条件解除后重新生效
+		{"1.6", 1, true},  // 再次满足时再次触发
+	} {
+		prices.set("110022", round.price)
+		fired, err := EvaluateAlerts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Alerts.Find(1, alert.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fired != round.fired || got.Active != round.active {
+			t.Fatalf("round %d at %s: fired %d active %v, want %d %v", i+1, round.price, fired, got.Active, round.fired, round.active)
+		}
+	}
+
+	triggers, err := store.Alerts.Triggers(1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 2 {
+		t.Fatalf("%d triggers, want 2", len(triggers))
+	}
+	for _, tr := range triggers {
+		if tr.AlertID != alert.ID || tr.Value != 1.6 {
+			t.Fatalf("trigger = %+v", tr)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/digest"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/notify"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/ws"
+	"sync"
+	"sync/atomic"
+)
+
+// digestUsers 有持仓或自选的用户
+func digestUsers() ([]uint, error) {
+	var holders, watchers []uint
+	if err := db.DB.Model(&models.Holding{}).Where("shares > 0").Distinct().Pluck("user_id", &holders).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Model(&models.Watchlist{}).Distinct().Pluck("user_id", &watchers).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]bool)
+	var ids []uint
+	for _, id := range append(holders, watchers...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// DigestConcurrency 同时生成和投递简报的用户数，启动时由配置覆盖。
+// 站外渠道可能要重试几分钟，逐个发送会让后面的用户很晚才收到
+var DigestConcurrency = 8
+
+// SendDigests 为每个有持仓或自选的用户生成 date 的简报，推送到在线设备和站外通知渠道。
+// 最多 DigestConcurrency 个用户并行，返回发出的份数，单个用户失败只记录日志
+func SendDigests(date string) (int, error) {
+	users, err := digestUsers()
+	if err != nil {
+		return 0, err
+	}
+	store := repo.NewGormStore(db.DB)
+	now := chinaNow()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, DigestConcurrency)
+	var sent atomic.Int64
+	for _, userID := range users {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(userID uint) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			d, err := digest.Build(store, userID, date, now)
+			if err != nil {
+				fmt.Printf("⚠️ 生成用户 %d 的简报失败: %v\n", userID, err)
+				return
+			}
+			if d.Empty() {
+				return
+			}
+			deliverDigest(d)
+			sent.Add(1)
+		}(userID)
+	}
+	wg.Wait()
+	return int(sent.Load()), nil
+}
+
+// deliverDigest 推送到用户的所有在线设备，并交给站外通知渠道 (邮件同时带 HTML 正文)
+func deliverDigest(d *digest.Digest) {
+	ws.Manager.SendJSON(d.UserID, map[string]interface{}{"type": "digest", "data": d})
+	if notifier == nil {
+		return
+	}
+	page, err := d.HTML()
+	if err != nil {
+		fmt.Printf("⚠️ 用户 %d 的简报 HTML 渲染失败: %v\n", d.UserID, err)
+	}
+	msg := notify.Message{UserID: d.UserID, Kind: notify.KindDigest, Title: d.Title(), Body: d.Text(), HTML: page, Data: d}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := notifier.Notify(ctx, msg); err != nil {
+		fmt.Printf("⚠️ 用户 %d 的简报发送失败: %v\n", d.UserID, err)
+	}
+}
+
+// sendDigests 发送 date 的简报，由晚间任务在持仓快照之后执行
+func sendDigests(date string) {
+	n, err := SendDigests(date)
+	if err != nil {
+		fmt.Println("⚠️ 发送每日简报失败:", err)
+		return
+	}
+	fmt.Printf("📰 每日简报发送完成: %d 份\n", n)
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/notify"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useMemoryDB 把 db.DB 换成本测试独占的内存库，避免和其他测试的数据互相影响
+func useMemoryDB(t *testing.T) {
+	t.Helper()
+	conn, err := db.OpenMemory(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = old
+		// 关闭最后一个连接时内存库随之释放，-count 重跑时从空库开始
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestSendDigestsBoundedConcurrency(t *testing.T) {
+	useMemoryDB(t)
+
+	const date = "2026-10-15"
+	const users = 12
+	for id := uint(101); id < 101+users; id++ {
+		db.DB.Create(&models.Holding{UserID: id, FundCode: "110022", Shares: 100, CostPrice: 3.5})
+		db.DB.Create(&models.PortfolioSnapshot{UserID: id, Date: date, FundCode: "110022", Shares: 100, NAV: 3.6, Value: 360, Cost: 350})
+	}
+
+	old := DigestConcurrency
+	DigestConcurrency = 3
+	defer func() { DigestConcurrency = old; SetNotifier(nil) }()
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	delivered := make(map[uint]bool)
+	SetNotifier(notify.Func(func(ctx context.Context, msg notify.Message) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		delivered[msg.UserID] = true
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}))
+
+	if _, err := SendDigests(date); err != nil {
+		t.Fatal(err)
+	}
+	for id := uint(101); id < 101+users; id++ {
+		if !delivered[id] {
+			t.Errorf("user %d got no digest", id)
+		}
+	}
+	if peak > 3 {
+		t.Fatalf("%d digests in flight, want at most 3", peak)
+	}
+	if peak < 2 {
+		t.Fatalf("digests were delivered one at a time")
+	}
+}
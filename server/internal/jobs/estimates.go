@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"time"
+)
+
+// CaptureEstimates 记录所有被跟踪基金 date 当天最后一次盘中估值，返回新记录的数量。
+// 收盘后估值不再变化，已有记录的基金直接跳过
+func CaptureEstimates(date string) (int, error) {
+	codes, err := trackedCodes()
+	if err != nil || len(codes) == 0 {
+		return 0, err
+	}
+	store := repo.NewGormStore(db.DB)
+	existing, err := store.NavEstimates.OnDate(date, codes)
+	if err != nil {
+		return 0, err
+	}
+	done := make(map[string]bool)
+	for _, e := range existing {
+		done[e.FundCode] = true
+	}
+
+	saved := 0
+	for _, code := range codes {
+		if done[code] {
+			continue
+		}
+		info, err := service.FetchEstimate(code)
+		if err != nil {
+			continue
+		}
+		// 停牌或节假日时 fundgz 返回的是之前的估值
+		estimate, err := service.EstimateFromQuote(info, date)
+		if err != nil {
+			continue
+		}
+		estimate.FundCode = code
+		// 其他实例同时记录了同一只基金时不重复计数
+		err = store.NavEstimates.Create(estimate)
+		if errors.Is(err, repo.ErrDuplicate) {
+			continue
+		}
+		if err != nil {
+			return saved, err
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+// ReconcileEstimates 为还没有对比的估值记录查找同一天的确认净值并计算偏差，返回完成对比的数量。
+// QDII 等净值公布较晚的基金会在之后的某次执行中补上
+func ReconcileEstimates() (int, error) {
+	store := repo.NewGormStore(db.DB)
+	pending, err := store.NavEstimates.Pending()
+	if err != nil {
+		return 0, err
+	}
+	byDate := make(map[string][]*models.NavEstimate)
+	var dates []string
+	for i := range pending {
+		e := &pending[i]
+		if _, ok := byDate[e.Date]; !ok {
+			dates = append(dates, e.Date)
+		}
+		byDate[e.Date] = append(byDate[e.Date], e)
+	}
+
+	confirmed := 0
+	for _, date := range dates {
+		var codes []string
+		for _, e := range byDate[date] {
+			codes = append(codes, e.FundCode)
+		}
+		navs, err := store.NavHistory.OnDate(date, codes)
+		if err != nil {
+			return confirmed, err
+		}
+		navByCode := make(map[string]models.NavHistory)
+		for _, n := range navs {
+			navByCode[n.FundCode] = n
+		}
+		for _, e := range byDate[date] {
+			nav, ok := navByCode[e.FundCode]
+			if !ok {
+				continue
+			}
+			service.ConfirmEstimate(e, nav)
+			if err := store.NavEstimates.Save(e); err != nil {
+				return confirmed, err
+			}
+			confirmed++
+		}
+	}
+	return confirmed, nil
+}
+
+// captureEstimates 记录今天的估值 (周末跳过)
+func captureEstimates() {
+	now := chinaNow()
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		return
+	}
+	n, err := CaptureEstimates(now.Format("2006-01-02"))
+	if err != nil {
+		fmt.Println("⚠️ 记录收盘估值失败:", err)
+		return
+	}
+	fmt.Printf("📝 收盘估值记录完成: %d 只基金\n", n)
+}
+
+// reconcileEstimates 补记遗漏的估值后与确认净值对比，在历史净值同步之后执行
+func reconcileEstimates() {
+	captureEstimates()
+	n, err := ReconcileEstimates()
+	if err != nil {
+		fmt.Println("⚠️ 对比估值与确认净值失败:", err)
+		return
+	}
+	fmt.Printf("🎯 估值偏差对比完成: %d 条\n", n)
+}
+
+// StartEstimateCapture 工作日 15:10 (收盘后估值定格) 记录当天最后一次估值
+func StartEstimateCapture() {
+	runDaily(15, 10, captureEstimates)
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"errors"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"math"
+	"testing"
+	"time"
+)
+
+// estimateProvider 按代码返回固定的盘中估值，beforeFetch 可模拟并发写入
+type estimateProvider struct {
+	quotes      map[string]*models.Quote
+	beforeFetch func(code string)
+}
+
+func (p *estimateProvider) Name() string              { return "test_estimate" }
+func (p *estimateProvider) Kind() service.QuoteKind   { return service.KindEstimate }
+func (p *estimateProvider) Supports(code string) bool { return true }
+
+func (p *estimateProvider) Fetch(code string) (*models.Quote, error) {
+	if p.beforeFetch != nil {
+		p.beforeFetch(code)
+	}
+	q, ok := p.quotes[code]
+	if !ok {
+		return nil, errors.New("no estimate")
+	}
+	copied := *q
+	return &copied, nil
+}
+
+func TestCaptureAndReconcileEstimates(t *testing.T) {
+	useMemoryDB(t)
+	store := repo.NewGormStore(db.DB)
+
+	const date = "2026-10-15"
+	closeAt := time.Date(2026, 10, 15, 15, 0, 0, 0, service.ChinaTZ)
+	estimate := func(price string, at time.Time) *models.Quote {
+		d, _ := models.ParseDecimal(price)
+		return &models.Quote{Price: d, Time: at}
+	}
+	p := &estimateProvider{quotes: map[string]*models.Quote{
+		"110022": estimate("3.6000", closeAt),
+		"513100": estimate("1.5000", closeAt),                   // QDII，确认净值隔天才公布
+		"161725": estimate("0.9000", closeAt.AddDate(0, 0, -1)), // 停牌，fundgz 还是前一天的估值
+	}}
+	prev := service.ProviderChain()
+	service.RegisterProvider(p)
+	if err := service.SetProviderChain(p.Name()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.SetProviderChain(prev...) })
+
+	for _, code := range []string{"110022", "513100", "161725"} {
+		db.DB.Create(&models.Watchlist{UserID: 1, FundCode: code})
+	}
+
+	n, err := CaptureEstimates(date)
+	if err != nil || n != 2 {
+		t.Fatalf("capture = %d, %v, want 2", n, err)
+	}
+	rows, err := store.NavEstimates.OnDate(date, []string{"110022", "513100", "161725"})
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("estimates = %+v, %v", rows, err)
+	}
+	for _, r := range rows {
+		if r.FundCode == "161725" {
+			t.Fatal("stale estimate should be rejected")
+		}
+	}
+	// 已记录的基金不再抓取
+	if n, err := CaptureEstimates(date); err != nil || n != 0 {
+		t.Fatalf("second capture = %d, %v, want 0", n, err)
+	}
+
+	// 其他实例抢先写入的记录不计入本次数量
+	p.quotes["161725"] = estimate("0.9100", closeAt)
+	p.beforeFetch = func(code string) {
+		db.DB.Create(&models.NavEstimate{FundCode: code, Date: date, Estimate: 0.91})
+	}
+	if n, err := CaptureEstimates(date); err != nil || n != 0 {
+		t.Fatalf("capture racing another writer = %d, %v, want 0", n, err)
+	}
+	p.beforeFetch = nil
+
+	// 当晚只有 110022 的确认净值
+	db.DB.Create(&models.NavHistory{FundCode: "110022", Date: date, NAV: 3.618, ChangeRate: 1.5})
+	if n, err := ReconcileEstimates(); err != nil || n != 1 {
+		t.Fatalf("reconcile = %d, %v, want 1", n, err)
+	}
+	// 第二天 QDII 净值补上后完成对比
+	db.DB.Create(&models.NavHistory{FundCode: "513100", Date: date, NAV: 1.497, ChangeRate: -0.2})
+	db.DB.Create(&models.NavHistory{FundCode: "161725", Date: date, NAV: 0.91})
+	if n, err := ReconcileEstimates(); err != nil || n != 2 {
+		t.Fatalf("late reconcile = %d, %v, want 2", n, err)
+	}
+	if n, err := ReconcileEstimates(); err != nil || n != 0 {
+		t.Fatalf("reconcile with nothing pending = %d, %v", n, err)
+	}
+
+	rows, err = store.NavEstimates.Range("513100", date, date)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("513100 rows = %+v, %v", rows, err)
+	}
+	qdii := rows[0]
+	if !qdii.Confirmed || qdii.NAV != 1.497 || math.Abs(qdii.ErrorRate-(-0.2)) > 1e-9 {
+		t.Fatalf("qdii = %+v, want confirmed with -0.2%% error", qdii)
+	}
+}
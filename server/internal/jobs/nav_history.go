@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/service"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// 翻页之间的间隔，避免对上游造成压力
+const navPageInterval = 200 * time.Millisecond
+
+// 正在同步中的基金代码，避免重复抓取
+var syncing sync.Map
+
+// SyncNavHistory 同步单只基金的历史净值：先回填已有数据之前的部分 (直到 F10 没有更早的数据)，
+// 再从最新日期之后增量抓取。返回写入的行数
+func SyncNavHistory(code string) (int, error) {
+	if _, busy := syncing.LoadOrStore(code, true); busy {
+		return 0, nil
+	}
+	defer syncing.Delete(code)
+
+	var state models.NavSyncState
+	if err := db.DB.Where("fund_code = ?", code).Limit(1).Find(&state).Error; err != nil {
+		return 0, err
+	}
+	saved := 0
+	if !state.BackfillDone {
+		n, err := backfillNavHistory(code)
+		saved += n
+		if err != nil {
+			return saved, err
+		}
+		state.FundCode, state.BackfillDone = code, true
+		if err := db.DB.Save(&state).Error; err != nil {
+			return saved, err
+		}
+	}
+	n, err := syncRecentNavHistory(code)
+	if n > 0 {
+		// 新的确认净值入库，缓存中可能还是盘中估值
+		service.InvalidateQuote(code)
+	}
+	return saved + n, err
+}
+
+// backfillNavHistory 抓取已有最早日期之前的全部历史 (没有存量时即全量)，逐页写入。
+// 中途失败时已写入的页保留，下次从新的最早日期之前继续
+func backfillNavHistory(code string) (int, error) {
+	var oldest models.NavHistory
+	if err := db.DB.Where("fund_code = ?", code).Order("date asc").Limit(1).Find(&oldest).Error; err != nil {
+		return 0, err
+	}
+	edate := ""
+	if oldest.Date != "" {
+		first, err := time.Parse("2006-01-02", oldest.Date)
+		if err != nil {
+			return 0, err
+		}
+		edate = first.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+
+	saved := 0
+	for page := 1; ; page++ {
+		rows, pages, err := service.FetchNavHistoryPage(code, page, "", edate)
+		if err != nil {
+			return saved, err
+		}
+		if len(rows) > 0 {
+			if err := saveNavHistory(rows); err != nil {
+				return saved, err
+			}
+			saved += len(rows)
+		}
+		if page >= pages || len(rows) == 0 {
+			return saved, nil
+		}
+		time.Sleep(navPageInterval)
+	}
+}
+
+// syncRecentNavHistory 抓取最新日期之后的数据。
+// 全部页抓完后一次写入，避免中途失败时只写入较新的页而留下无法补齐的缺口
+func syncRecentNavHistory(code string) (int, error) {
+	var latest models.NavHistory
+	if err := db.DB.Where("fund_code = ?", code).Order("date desc").Limit(1).Find(&latest).Error; err != nil {
+		return 0, err
+	}
+	if latest.Date == "" {
+		// F10 没有该基金的任何数据
+		return 0, nil
+	}
+	last, err := time.Parse("2006-01-02", latest.Date)
+	if err != nil {
+		return 0, err
+	}
+	sdate := last.AddDate(0, 0, 1).Format("2006-01-02")
+
+	var all []models.NavHistory
+	for page := 1; ; page++ {
+		rows, pages, err := service.FetchNavHistoryPage(code, page, sdate, "")
+		if err != nil {
+			return 0, err
+		}
+		all = append(all, rows...)
+		if page >= pages || len(rows) == 0 {
+			break
+		}
+		time.Sleep(navPageInterval)
+	}
+	if len(all) == 0 {
+		return 0, nil
+	}
+	return len(all), saveNavHistory(all)
+}
+
+// saveNavHistory 按 (fund_code, date) 写入，已存在的行以最新抓取为准
+func saveNavHistory(rows []models.NavHistory) error {
+	return db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "fund_code"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"nav", "acc_nav", "change_rate", "updated_at"}),
+	}).Create(&rows).Error
+}
+
+// trackedCodes 所有用户持仓和自选中的基金代码 (去重)
+func trackedCodes() ([]string, error) {
+	var holdingCodes, watchCodes []string
+	if err := db.DB.Model(&models.Holding{}).Distinct().Pluck("fund_code", &holdingCodes).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Model(&models.Watchlist{}).Distinct().Pluck("fund_code", &watchCodes).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var codes []string
+	for _, code := range append(holdingCodes, watchCodes...) {
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+// SyncAllNavHistory 同步所有被跟踪基金的历史净值
+func SyncAllNavHistory() {
+	codes, err := trackedCodes()
+	if err != nil {
+		fmt.Println("⚠️ 读取基金列表失败:", err)
+		return
+	}
+	total := 0
+	for _, code := range codes {
+		n, err := SyncNavHistory(code)
+		if err != nil {
+			fmt.Printf("⚠️ 同步 %s 历史净值失败: %v\n", code, err)
+		}
+		total += n
+	}
+	fmt.Printf("📈 历史净值同步完成: %d 只基金，写入 %d 行\n", len(codes), total)
+}
+
+// StartNightlyJobs 每天 22:30 (F10 确认净值基本公布完毕后) 增量同步历史净值，
+// 完成后依次对比估值偏差，工作日再生成持仓快照和发送简报。
+// 后面的步骤等前一步结束才开始，同步耗时再长也不会用未确认的净值生成快照和简报
+func StartNightlyJobs() {
+	runDaily(22, 30, func() {
+		// 日期在开始时确定，任务链跨过零点时仍处理当天
+		now := chinaNow()
+		date := now.Format("2006-01-02")
+		SyncAllNavHistory()
+		reconcileEstimates()
+		if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+			return
+		}
+		snapshotPortfolios(date)
+		sendDigests(date)
+	})
+}
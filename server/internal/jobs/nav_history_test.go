@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/fakeupstream"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// navFixtures code 从 latest 往前 n 个自然日的历史净值 (按日期倒序)
+func navFixtures(t *testing.T, code string, latest time.Time, n int) fstest.MapFS {
+	t.Helper()
+	rows := make([]map[string]string, n)
+	for i := range rows {
+		nav := fmt.Sprintf("%.4f", 1+float64(n-i)/1000)
+		rows[i] = map[string]string{"date": latest.AddDate(0, 0, -i).Format("2006-01-02"), "nav": nav, "acc_nav": nav, "rate": "0.10%"}
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fstest.MapFS{"f10/" + code + ".json": {Data: data}}
+}
+
+func TestSyncNavHistoryResumesBackfill(t *testing.T) {
+	useMemoryDB(t)
+
+	// 第一次回填在第 2 页失败
+	var failPage2 atomic.Bool
+	failPage2.Store(true)
+	latest := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	fixtures := fakeupstream.Handler(navFixtures(t, "000001", latest, 100))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failPage2.Load() && r.URL.Query().Get("page") == "2" {
+			http.Error(w, "upstream timeout", http.StatusGatewayTimeout)
+			return
+		}
+		fixtures.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	prev := service.CurrentUpstream()
+	service.SetUpstream(fakeupstream.UpstreamFor(srv.URL))
+	defer service.SetUpstream(prev)
+
+	count := func() int64 {
+		var n int64
+		db.DB.Model(&models.NavHistory{}).Where("fund_code = ?", "000001").Count(&n)
+		return n
+	}
+
+	if _, err := SyncNavHistory("000001"); err == nil {
+		t.Fatal("first sync should fail on page 2")
+	}
+	if got := count(); got != service.NavHistoryPageSize {
+		t.Fatalf("after failed backfill: %d rows, want the first page (%d)", got, service.NavHistoryPageSize)
+	}
+
+	// 之后的同步从已有最早日期之前继续，直到 F10 没有更早的数据
+	failPage2.Store(false)
+	n, err := SyncNavHistory("000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 100 || n != 100-service.NavHistoryPageSize {
+		t.Fatalf("after resumed backfill: %d rows (%d new), want 100", got, n)
+	}
+	var state models.NavSyncState
+	db.DB.First(&state, "fund_code = ?", "000001")
+	if !state.BackfillDone {
+		t.Fatal("backfill should be marked done")
+	}
+
+	// 回填完成后只做增量
+	n, err = SyncNavHistory("000001")
+	if err != nil || n != 0 {
+		t.Fatalf("incremental sync wrote %d rows, err %v", n, err)
+	}
+}
@@ -0,0 +1,25 @@
+// Package jobs 后台定时任务
+package jobs
+
+import (
+	"fund-tracker-server/internal/service"
+	"time"
+)
+
+// chinaNow 北京时间的当前时刻，任务的执行时间、日期和是否周末都按北京时间计算 (与服务器时区无关)
+func chinaNow() time.Time {
+	return time.Now().In(service.ChinaTZ)
+}
+
+// runDaily 每天在北京时间 hour:minute 执行一次 fn，阻塞运行
+func runDaily(hour, minute int, fn func()) {
+	for {
+		now := chinaNow()
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, service.ChinaTZ)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+		fn()
+	}
+}
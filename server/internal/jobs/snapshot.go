@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/service"
+
+	"gorm.io/gorm/clause"
+)
+
+// position 一个用户在所有组合中持有同一只基金的合计
+type position struct {
+	userID   uint
+	code     string
+	shares   float64
+	cost     float64
+	realized float64
+}
+
+// SnapshotPortfolios 为所有用户的持仓生成 date 当天的快照，同一只基金在多个组合中的持仓合并为一条。
+// 优先使用当天已确认的历史净值，没有时退回最新行情 (估值)，行情也取不到时沿用最近一次已知净值 (cached)。
+// 已清仓但有已实现收益的基金也会记录 (市值为 0)，否则资产曲线在清仓当天会少掉这部分收益
+func SnapshotPortfolios(date string) (int, error) {
+	var holdings []models.Holding
+	if err := db.DB.Where("shares > 0 OR realized_return <> 0").Order("user_id asc, id asc").Find(&holdings).Error; err != nil {
+		return 0, err
+	}
+	if len(holdings) == 0 {
+		return 0, nil
+	}
+
+	type key struct {
+		userID uint
+		code   string
+	}
+	merged := make(map[key]*position)
+	var positions []*position
+	for _, h := range holdings {
+		k := key{h.UserID, h.FundCode}
+		p, ok := merged[k]
+		if !ok {
+			p = &position{userID: h.UserID, code: h.FundCode}
+			merged[k] = p
+			positions = append(positions, p)
+		}
+		p.shares += h.Shares
+		p.cost += h.Shares * h.CostPrice
+		p.realized += h.RealizedReturn
+	}
+
+	// 只有仍有份额的基金需要净值
+	seen := make(map[string]bool)
+	var codes []string
+	for _, p := range positions {
+		if p.shares > 0 && !seen[p.code] {
+			seen[p.code] = true
+			codes = append(codes, p.code)
+		}
+	}
+
+	var navs []models.NavHistory
+	if err := db.DB.Where("date = ? AND fund_code IN ?", date, codes).Find(&navs).Error; err != nil {
+		return 0, err
+	}
+	confirmed := make(map[string]float64)
+	for _, n := range navs {
+		confirmed[n.FundCode] = n.NAV
+	}
+	var missing []string
+	for _, code := range codes {
+		if _, ok := confirmed[code]; !ok {
+			missing = append(missing, code)
+		}
+	}
+	quotes := service.FetchQuotes(missing)
+
+	var snapshots []models.PortfolioSnapshot
+	for _, p := range positions {
+		nav, status := confirmed[p.code], service.NavConfirmed
+		if nav == 0 && p.shares > 0 {
+			if q, ok := quotes[p.code]; ok {
+				nav, status = q.Price.Float64(), string(q.Status)
+			} else {
+				// 跳过会让这只基金从当天快照中消失，资产曲线出现虚假的下跌，沿用最近一次已知净值
+				var err error
+				if nav, err = lastKnownNAV(p.userID, p.code, date); err != nil {
+					return 0, err
+				}
+				if nav == 0 {
+					fmt.Printf("⚠️ %s 没有 %s 及之前的净值，跳过快照\n", p.code, date)
+					continue
+				}
+				status = service.NavCached
+			}
+		}
+		snapshots = append(snapshots, models.PortfolioSnapshot{
+			UserID:         p.userID,
+			Date:           date,
+			FundCode:       p.code,
+			Shares:         p.shares,
+			NAV:            nav,
+			NavStatus:      status,
+			Value:          p.shares * nav,
+			Cost:           p.cost,
+			RealizedReturn: p.realized,
+		})
+	}
+	if len(snapshots) == 0 {
+		return 0, nil
+	}
+
+	// 同一天重复执行时以最后一次为准
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "date"}, {Name: "fund_code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"shares", "nav", "nav_status", "value", "cost", "realized_return", "updated_at"}),
+	}).Create(&snapshots).Error
+	return len(snapshots), err
+}
+
+// lastKnownNAV date 之前最近的历史净值，没有时取该用户此前快照中的净值，都没有返回 0
+func lastKnownNAV(userID uint, code, date string) (float64, error) {
+	var navs []models.NavHistory
+	if err := db.DB.Where("fund_code = ? AND date < ?", code, date).Order("date desc").Limit(1).Find(&navs).Error; err != nil {
+		return 0, err
+	}
+	if len(navs) > 0 {
+		return navs[0].NAV, nil
+	}
+	var snaps []models.PortfolioSnapshot
+	err := db.DB.Where("user_id = ? AND fund_code = ? AND date < ? AND nav > 0", userID, code, date).
+		Order("date desc").Limit(1).Find(&snaps).Error
+	if err != nil || len(snaps) == 0 {
+		return 0, err
+	}
+	return snaps[0].NAV, nil
+}
+
+// snapshotPortfolios 生成 date 的持仓快照，由晚间任务在历史净值同步之后执行
+func snapshotPortfolios(date string) {
+	n, err := SnapshotPortfolios(date)
+	if err != nil {
+		fmt.Println("⚠️ 持仓快照失败:", err)
+		return
+	}
+	fmt.Printf("📸 持仓快照完成: %d 条\n", n)
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/repo"
+	"fund-tracker-server/internal/service"
+	"testing"
+)
+
+func TestSnapshotKeepsClosedPositions(t *testing.T) {
+	useMemoryDB(t)
+
+	const date = "2026-10-16"
+	db.DB.Create(&[]models.Holding{
+		{UserID: 1, FundCode: "110022", Shares: 1000, CostPrice: 3.5},
+		// 当天全部卖出，只剩已实现收益
+		{UserID: 1, FundCode: "161725", Shares: 0, RealizedReturn: 120},
+		// 从未有过收益的空持仓不记录
+		{UserID: 1, FundCode: "000001", Shares: 0},
+	})
+	db.DB.Create(&models.NavHistory{FundCode: "110022", Date: date, NAV: 3.6391})
+
+	n, err := SnapshotPortfolios(date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("snapshotted %d positions, want 2", n)
+	}
+
+	points, err := repo.NewGormStore(db.DB).Snapshots.EquityCurve(1, date, date)
+	if err != nil || len(points) != 1 {
+		t.Fatalf("equity curve = %+v, %v", points, err)
+	}
+	p := points[0]
+	if p.RealizedReturn != 120 || p.Estimated {
+		t.Fatalf("point = %+v, want realized 120 and confirmed", p)
+	}
+
+	var closed models.PortfolioSnapshot
+	db.DB.First(&closed, "fund_code = ?", "161725")
+	if closed.Value != 0 || closed.NavStatus != service.NavConfirmed {
+		t.Fatalf("closed snapshot = %+v", closed)
+	}
+}
+
+func TestSnapshotCarriesForwardLastKnownNAV(t *testing.T) {
+	useMemoryDB(t)
+	// 上游行情全部取不到
+	prices := usePriceProvider(t)
+	for _, code := range []string{"110022", "161725", "000001"} {
+		prices.set(code, "unavailable")
+	}
+
+	const prev, date = "2026-10-15", "2026-10-16"
+	db.DB.Create(&[]models.Holding{
+		{UserID: 1, FundCode: "110022", Shares: 1000, CostPrice: 3.5},
+		{UserID: 1, FundCode: "161725", Shares: 500, CostPrice: 1},
+		{UserID: 1, FundCode: "000001", Shares: 100, CostPrice: 1},
+	})
+	db.DB.Create(&[]models.NavHistory{
+		{FundCode: "110022", Date: "2026-10-14", NAV: 3.5},
+		{FundCode: "110022", Date: prev, NAV: 3.6},
+		// 之后的净值不能用来补前一天
+		{FundCode: "110022", Date: "2026-10-17", NAV: 9.9},
+	})
+	// 161725 没有历史净值，只有前一天的快照
+	db.DB.Create(&models.PortfolioSnapshot{UserID: 1, Date: prev, FundCode: "161725", Shares: 500, NAV: 1.2, NavStatus: service.NavConfirmed, Value: 600, Cost: 500})
+
+	n, err := SnapshotPortfolios(date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 000001 没有任何已知净值，仍然跳过
+	if n != 2 {
+		t.Fatalf("snapshotted %d positions, want 2", n)
+	}
+
+	snaps, err := repo.NewGormStore(db.DB).Snapshots.OnDate(1, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"110022": 3.6, "161725": 1.2}
+	for _, s := range snaps {
+		if s.NAV != want[s.FundCode] || s.NavStatus != service.NavCached || s.Value != s.Shares*s.NAV {
+			t.Errorf("%s snapshot = %+v, want nav %v cached", s.FundCode, s, want[s.FundCode])
+		}
+		delete(want, s.FundCode)
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing snapshots for %v", want)
+	}
+
+	points, err := repo.NewGormStore(db.DB).Snapshots.EquityCurve(1, date, date)
+	if err != nil || len(points) != 1 {
+		t.Fatalf("equity curve = %+v, %v", points, err)
+	}
+	if p := points[0]; p.Value != 1000*3.6+500*1.2 || !p.Estimated {
+		t.Fatalf("point = %+v, want carried-forward value marked estimated", p)
+	}
+}
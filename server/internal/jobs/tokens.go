@@ -0,0 +1,17 @@
+package jobs
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/db"
+	"fund-tracker-server/internal/repo"
+	"time"
+)
+
+// StartTokenCleanup 每天凌晨清理已过期的刷新令牌和访问令牌吊销记录，阻塞运行
+func StartTokenCleanup() {
+	runDaily(4, 0, func() {
+		if err := repo.NewGormStore(db.DB).Tokens.PurgeExpired(time.Now()); err != nil {
+			fmt.Println("⚠️ 清理过期令牌失败:", err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// 提醒类型
+const (
+	AlertPriceAbove   = "price_above"   // 价格 (实时价/估值/净值) 涨到阈值以上
+	AlertPriceBelow   = "price_below"   // 价格跌到阈值以下
+	AlertChange       = "change"        // 日涨跌幅绝对值超过阈值 (%)
+	AlertPremium      = "premium"       // 场内基金溢价/折价率绝对值超过阈值 (%)
+	AlertReturnTarget = "return_target" // 持仓收益率达到阈值 (%)，阈值为负数时表示跌到该收益率 (止损)
+)
+
+// Alert 用户设置的行情提醒。条件满足时触发一次并标记 Active，条件解除后才会再次触发
+type Alert struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	FundCode    string    `gorm:"index;not null" json:"fund_code"`
+	Kind        string    `gorm:"not null" json:"kind"`
+	Threshold   float64   `gorm:"not null" json:"threshold"`
+	PortfolioID uint      `gorm:"not null;default:0" json:"portfolio_id"` // 只用于 return_target，0 为全部组合合计
+	Note        string    `json:"note"`
+	Enabled     bool      `gorm:"not null;default:true" json:"enabled"`
+	Active      bool      `gorm:"not null;default:false" json:"active"` // 条件当前是否满足
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+}
+
+// AlertTrigger 提醒的触发记录，保存触发时的阈值和观测值
+type AlertTrigger struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	AlertID   uint      `gorm:"index;not null" json:"alert_id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	FundCode  string    `gorm:"not null" json:"fund_code"`
+	Kind      string    `gorm:"not null" json:"kind"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`      // 触发时的价格、涨跌幅、溢价率或收益率
+	QuoteTime string    `json:"quote_time"` // 所用行情的时间
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 最多保留的小数位数 (净值 4 位，个别货币基金的万份收益更多)
+const maxDecimalScale = 8
+
+var pow10 = [maxDecimalScale + 1]int64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000}
+
+// Decimal 定点小数，值为 units / 10^scale。
+// 保留上游给出的小数位数，"3.6120" 解析后输出仍是 "3.6120"，不经过 float 也就没有舍入误差
+type Decimal struct {
+	units int64
+	scale uint8
+}
+
+// NewDecimal 把 float 四舍五入到 scale 位小数
+func NewDecimal(f float64, scale int) Decimal {
+	scale = min(max(scale, 0), maxDecimalScale)
+	return Decimal{units: int64(math.Round(f * float64(pow10[scale]))), scale: uint8(scale)}
+}
+
+// ParseDecimal 解析 "-0.70"、"3.6120" 这样的十进制字符串 (允许首尾空白和正负号)
+func ParseDecimal(s string) (Decimal, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	digits := whole + frac
+	if digits == "" || len(frac) > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("无效的数值: %q", raw)
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Decimal{}, fmt.Errorf("无效的数值: %q", raw)
+		}
+	}
+	units, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("无效的数值: %q", raw)
+	}
+	if neg {
+		units = -units
+	}
+	return Decimal{units: units, scale: uint8(len(frac))}, nil
+}
+
+// Float64 转为 float，用于计算市值、收益等
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / float64(pow10[d.scale])
+}
+
+// IsZero 是否为 0 (包括未赋值)
+func (d Decimal) IsZero() bool { return d.units == 0 }
+
+// Sign 符号: -1、0 或 1
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// String 按原有的小数位数输出
+func (d Decimal) String() string {
+	u := d.units
+	neg := u < 0
+	if neg {
+		u = -u
+	}
+	s := strconv.FormatInt(u, 10)
+	if n := int(d.scale); n > 0 {
+		if len(s) <= n {
+			s = strings.Repeat("0", n-len(s)+1) + s
+		}
+		s = s[:len(s)-n] + "." + s[len(s)-n:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalJSON 输出为 JSON 数字，保留小数位数
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON 接受 JSON 数字或数字字符串
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+	v, err := ParseDecimal(string(bytes.Trim(data, `"`)))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
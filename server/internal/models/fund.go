@@ -1,6 +1,7 @@
 package models
 
-// FundInfo 基础行情信息
+// FundInfo 旧版行情格式，所有数字都是字符串，状态拼在 GZTime 里。
+// 服务内部使用 Quote，只在输出给旧客户端时经 Quote.FundInfo 转换
 type FundInfo struct {
 	FundCode    string `json:"fundcode"`
 	Name        string `json:"name"`
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// NavHistory 历史净值表，(FundCode, Date) 唯一
+type NavHistory struct {
+	ID         uint      `gorm:"primarykey" json:"-"`
+	FundCode   string    `gorm:"uniqueIndex:idx_nav_code_date;not null" json:"fund_code"`
+	Date       string    `gorm:"uniqueIndex:idx_nav_code_date;size:10;not null" json:"date"` // 2006-01-02
+	NAV        float64   `json:"nav"`                                                        // 单位净值
+	AccNAV     float64   `json:"acc_nav"`                                                    // 累计净值
+	ChangeRate float64   `json:"change_rate"`                                                // 日增长率 (%)
+	CreatedAt  time.Time `json:"-"`
+	UpdatedAt  time.Time `json:"-"`
+}
+
+// NavSyncState 每只基金历史净值的同步进度。
+// 回填按日期倒序逐页写入，中断后下次从已有的最早日期之前继续，直到 F10 没有更早的数据
+type NavSyncState struct {
+	FundCode     string `gorm:"primarykey"`
+	BackfillDone bool   `gorm:"not null;default:false"` // 已回填到 F10 的最早一天
+	UpdatedAt    time.Time
+}
+
+// PortfolioSnapshot 每日收盘后的持仓快照，(UserID, Date, FundCode) 唯一
+type PortfolioSnapshot struct {
+	ID             uint      `gorm:"primarykey" json:"-"`
+	UserID         uint      `gorm:"uniqueIndex:idx_snapshot_user_date_code;not null" json:"user_id"`
+	Date           string    `gorm:"uniqueIndex:idx_snapshot_user_date_code;size:10;not null" json:"date"` // 2006-01-02
+	FundCode       string    `gorm:"uniqueIndex:idx_snapshot_user_date_code;not null" json:"fund_code"`
+	Shares         float64   `json:"shares"`
+	NAV            float64   `json:"nav"`        // 快照使用的净值
+	NavStatus      string    `json:"nav_status"` // confirmed / estimated / realtime
+	Value          float64   `json:"value"`      // 市值 = 份额 * 净值
+	Cost           float64   `json:"cost"`       // 持仓成本
+	RealizedReturn float64   `json:"realized_return"`
+	CreatedAt      time.Time `json:"-"`
+	UpdatedAt      time.Time `json:"-"`
+}
+
+// NavEstimate 交易日收盘后的最后一次盘中估值，确认净值公布后补上偏差，(FundCode, Date) 唯一
+type NavEstimate struct {
+	ID           uint      `gorm:"primarykey" json:"-"`
+	FundCode     string    `gorm:"uniqueIndex:idx_estimate_code_date;not null" json:"fund_code"`
+	Date         string    `gorm:"uniqueIndex:idx_estimate_code_date;size:10;not null" json:"date"` // 2006-01-02
+	Estimate     float64   `json:"estimate"`                                                        // 估算净值
+	EstimateRate float64   `json:"estimate_rate"`                                                   // 估算涨跌幅 (%)
+	EstimateTime string    `json:"estimate_time"`                                                   // 估值时间，如 2006-01-02 15:00
+	Confirmed    bool      `gorm:"index;not null;default:false" json:"confirmed"`                   // 是否已对比确认净值
+	NAV          float64   `json:"nav"`                                                             // 确认净值
+	ChangeRate   float64   `json:"change_rate"`                                                     // 确认涨跌幅 (%)
+	Error        float64   `json:"error"`                                                           // 确认净值 - 估值
+	ErrorRate    float64   `json:"error_rate"`                                                      // 相对估值的偏差 (%)
+	CreatedAt    time.Time `json:"-"`
+	UpdatedAt    time.Time `json:"-"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 通知渠道类型
+const (
+	ChannelWebhook = "webhook" // 签名的 JSON POST
+	ChannelEmail   = "email"   // 通过服务器配置的 SMTP 发送
+)
+
+// NotifyChannel 用户配置的站外通知渠道
+type NotifyChannel struct {
+	ID      uint     `gorm:"primarykey" json:"id"`
+	UserID  uint     `gorm:"index;not null" json:"user_id"`
+	Kind    string   `gorm:"not null" json:"kind"`
+	Target  string   `gorm:"not null" json:"target"`        // webhook 地址或收件邮箱
+	Secret  string   `json:"secret,omitempty"`              // webhook 签名密钥，创建时由服务器生成
+	Events  []string `gorm:"serializer:json" json:"events"` // 订阅的消息类型，为空表示全部
+	Enabled bool     `gorm:"not null;default:true" json:"enabled"`
+
+	// 邮箱渠道需要收件人用邮件中的确认码确认后才能启用和测试，webhook 创建即视为已确认
+	Verified      bool       `gorm:"not null;default:false" json:"verified"`
+	VerifyCode    string     `json:"-"` // 确认码的 SHA-256
+	VerifyExpires *time.Time `json:"-"`
+
+	// 最近一次投递的结果
+	LastSentAt *time.Time `json:"last_sent_at"`
+	LastError  string     `json:"last_error"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribed 渠道是否接收 kind 类型的消息
+func (ch *NotifyChannel) Subscribed(kind string) bool {
+	if len(ch.Events) == 0 {
+		return true
+	}
+	for _, e := range ch.Events {
+		if e == kind {
+			return true
+		}
+	}
+	return false
+}
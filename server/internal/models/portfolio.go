@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// 注册和迁移时自动创建的组合名称
+const DefaultPortfolioName = "默认组合"
+
+// Portfolio 投资组合 (如养老账户、交易账户)，持仓和流水归属于组合。
+// 每个用户至少有一个组合，ID 最小的为默认组合
+type Portfolio struct {
+	gorm.Model
+	UserID   uint   `gorm:"uniqueIndex:idx_portfolio_user_name;not null" json:"user_id"`
+	Name     string `gorm:"uniqueIndex:idx_portfolio_user_name;size:64;not null" json:"name"`
+	Currency string `gorm:"size:3;not null;default:CNY" json:"currency"`
+	Broker   string `json:"broker"` // 开户平台，如 "天天基金"
+	Notes    string `json:"notes"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuoteSource 提供价格的数据源 (即 service 中注册的数据源名称)
+type QuoteSource string
+
+const (
+	SourceEastmoneyMarket QuoteSource = "eastmoney_market" // 场内实时成交价
+	SourceFundGZ          QuoteSource = "fundgz"           // 天天基金盘中估值
+	SourceEastmoneyF10    QuoteSource = "eastmoney_f10"    // F10 历史净值 (确认净值)
+)
+
+// PriceStatus 价格的性质
+type PriceStatus string
+
+const (
+	PriceRealtime  PriceStatus = "realtime"  // 场内实时成交价
+	PriceEstimated PriceStatus = "estimated" // 盘中估值，晚间可能被确认净值替换
+	PriceConfirmed PriceStatus = "confirmed" // 已公布的确认净值
+)
+
+// MarketStatus 跟踪境外市场的基金 (QDII 等) 所跟踪市场的交易状态，其他基金为空
+type MarketStatus string
+
+const (
+	MarketUSTrading MarketStatus = "us_trading" // 美股交易中
+	MarketUSClosed  MarketStatus = "us_closed"  // 美股休市
+)
+
+// Quote 结构化的基金行情
+type Quote struct {
+	FundCode     string       `json:"fund_code"`
+	Name         string       `json:"name"`
+	Price        Decimal      `json:"price"`       // 实时价、估值或确认净值，见 Status
+	ChangeRate   Decimal      `json:"change_rate"` // 日涨跌幅 (%)
+	Time         time.Time    `json:"time"`        // 行情时间 (北京时间)，确认净值为净值日期当天零点
+	Source       QuoteSource  `json:"source"`
+	Status       PriceStatus  `json:"status"`
+	MarketStatus MarketStatus `json:"market_status,omitempty"`
+	PremiumRate  *Decimal     `json:"premium_rate,omitempty"` // 场内价格相对净值的溢价率 (%)，只有场内基金有
+}
+
+// 旧版 GZTime 中表示价格性质和美股状态的后缀
+var (
+	legacyStatusSuffix = map[PriceStatus]string{
+		PriceRealtime:  " (实时)",
+		PriceEstimated: " (估)",
+		PriceConfirmed: " (确)",
+	}
+	legacyMarketSuffix = map[MarketStatus]string{
+		MarketUSTrading: " [美股交易中]",
+		MarketUSClosed:  " [美股休市]",
+	}
+)
+
+// LegacyTime 旧版的 GZTime 字符串，如 "2026-10-16 14:35 (估) [美股交易中]"、"2026-10-16 (确)"
+func (q *Quote) LegacyTime() string {
+	layout := "2006-01-02 15:04"
+	if q.Status == PriceConfirmed {
+		layout = "2006-01-02"
+	}
+	var t string
+	if !q.Time.IsZero() {
+		t = q.Time.Format(layout)
+	}
+	return t + legacyStatusSuffix[q.Status] + legacyMarketSuffix[q.MarketStatus]
+}
+
+// FundInfo 兼容编码：转换为旧版客户端使用的全字符串格式，
+// 价格性质和美股状态以后缀形式拼在 GZTime 中
+func (q *Quote) FundInfo() *FundInfo {
+	info := &FundInfo{
+		FundCode: q.FundCode,
+		Name:     q.Name,
+		GSZ:      q.Price.String(),
+		GSZZL:    q.ChangeRate.String(),
+		GZTime:   q.LegacyTime(),
+	}
+	if q.PremiumRate != nil {
+		info.PremiumRate = fmt.Sprintf("%+.2f%%", q.PremiumRate.Float64())
+	}
+	return info
+}
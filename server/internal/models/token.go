@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// RefreshToken 刷新令牌表，只保存令牌的 SHA-256。
+// 每次刷新都吊销旧令牌并换发新令牌，同一次登录换发出的令牌共享 FamilyID
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey"`
+	UserID    uint       `gorm:"index;not null"`
+	FamilyID  string     `gorm:"index;size:32;not null"`
+	TokenHash string     `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time // 非空表示已吊销 (已换发或已退出)
+	CreatedAt time.Time
+}
+
+// RevokedToken 提前吊销的访问令牌 (按 jti)，令牌过期后即可清理
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:32"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 交易类型
+const (
+	TxBuy      = "buy"      // 买入/申购
+	TxSell     = "sell"     // 卖出/赎回
+	TxDividend = "dividend" // 分红 (Amount 为现金分红，Shares 为红利再投份额)
+	TxFee      = "fee"      // 独立费用 (Amount)
+	TxSplit    = "split"    // 份额折算/拆分 (Shares 为份额变动，总成本不变)
+	TxTransfer = "transfer" // 转入 (Shares > 0，Price 为成本价) / 转出 (Shares < 0)
+)
+
+// Transaction 交易流水表，Holding 由流水重放得出
+type Transaction struct {
+	gorm.Model
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	PortfolioID uint      `gorm:"index;not null;default:0" json:"portfolio_id"`
+	FundCode    string    `gorm:"index;not null" json:"fund_code"`
+	Type        string    `gorm:"not null" json:"type"`
+	TradeDate   time.Time `gorm:"not null" json:"trade_date"`
+	Shares      float64   `gorm:"not null;default:0" json:"shares"` // 份额
+	Price       float64   `gorm:"not null;default:0" json:"price"`  // 成交单价
+	Fee         float64   `gorm:"not null;default:0" json:"fee"`    // 手续费
+	Amount      float64   `gorm:"not null;default:0" json:"amount"` // 现金金额 (分红/独立费用)
+	Note        string    `json:"note"`
+}
@@ -9,32 +9,38 @@ type User struct {
 	gorm.Model
 	Username string `gorm:"uniqueIndex;not null" json:"username"`
 	Password string `gorm:"not null" json:"-"`
+
+	DisplayName  string `json:"display_name"`                                     // 昵称，为空时客户端显示用户名
+	BaseCurrency string `gorm:"size:3;not null;default:CNY" json:"base_currency"` // 记账本位币
+
+	// 令牌版本，"退出所有设备" 时加一，之前签发的访问令牌随之失效
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
 }
 
 // Holding 持仓表
 type Holding struct {
 	gorm.Model
-	UserID   uint   `gorm:"index;not null" json:"user_id"`
-	FundCode string `gorm:"not null" json:"fund_code"`
-	FundName string `json:"fund_name"`
+	UserID      uint   `gorm:"index;not null" json:"user_id"`
+	PortfolioID uint   `gorm:"index;not null;default:0" json:"portfolio_id"`
+	FundCode    string `gorm:"not null" json:"fund_code"`
+	FundName    string `json:"fund_name"`
 
-	// 🔥 修改：增加 ;default:0 以兼容旧数据
+	// 表结构由 internal/db 的迁移维护，旧数据中的空值由迁移 0006 补为 0
 	Shares    float64 `gorm:"not null;default:0" json:"shares"`     // 持有份额
 	CostPrice float64 `gorm:"not null;default:0" json:"cost_price"` // 平均成本单价
 
+	// 已实现收益 (平均成本法，卖出差价 + 现金分红 - 费用)，由流水重放得出
+	RealizedReturn float64 `gorm:"not null;default:0" json:"realized_return"`
+
 	// 缓存字段
 	LastPrice string `json:"last_price"`
 	Change    string `json:"change"`
 
 	// 动态计算字段
-	TotalValue  float64 `gorm:"-" json:"total_value"`
-	TotalReturn float64 `gorm:"-" json:"total_return"`
-	DayReturn   float64 `gorm:"-" json:"day_return"`
-}
-
-// Watchlist 自选表
-type Watchlist struct {
-	gorm.Model
-	UserID   uint   `gorm:"index;not null" json:"user_id"`
-	FundCode string `gorm:"not null" json:"fund_code"`
+	TotalValue       float64 `gorm:"-" json:"total_value"`
+	TotalReturn      float64 `gorm:"-" json:"total_return"`
+	DayReturn        float64 `gorm:"-" json:"day_return"`
+	UnrealizedReturn float64 `gorm:"-" json:"unrealized_return"`
+	NavStatus        string  `gorm:"-" json:"nav_status"` // confirmed / estimated / realtime / cached
+	QuoteTime        string  `gorm:"-" json:"quote_time"`
 }
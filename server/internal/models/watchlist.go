@@ -0,0 +1,30 @@
+package models
+
+import "gorm.io/gorm"
+
+// Watchlist 自选表，同一用户的同一只基金只有一条
+type Watchlist struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;uniqueIndex:idx_watchlist_user_code;not null" json:"user_id"`
+	FundCode string `gorm:"uniqueIndex:idx_watchlist_user_code;not null" json:"fund_code"`
+	FundName string `json:"fund_name"` // 添加时的基金名称，取不到行情时显示
+
+	GroupID   uint     `gorm:"index;not null;default:0" json:"group_id"` // 0 为未分组
+	SortOrder int      `gorm:"not null;default:0" json:"sort_order"`     // 组内顺序，从小到大
+	Note      string   `json:"note"`
+	Tags      []string `gorm:"serializer:json" json:"tags"`
+
+	// 动态计算字段
+	LastPrice string `gorm:"-" json:"last_price"`
+	Change    string `gorm:"-" json:"change"`
+	NavStatus string `gorm:"-" json:"nav_status"`
+	QuoteTime string `gorm:"-" json:"quote_time"`
+}
+
+// WatchlistGroup 自选分组 (如 "观察中"、"定投候选")
+type WatchlistGroup struct {
+	gorm.Model
+	UserID    uint   `gorm:"uniqueIndex:idx_watchgroup_user_name;not null" json:"user_id"`
+	Name      string `gorm:"uniqueIndex:idx_watchgroup_user_name;size:32;not null" json:"name"`
+	SortOrder int    `gorm:"not null;default:0" json:"sort_order"`
+}
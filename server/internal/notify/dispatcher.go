@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ChannelStore 读取用户的通知渠道并记录投递结果，由 repo.NotifyChannelRepo 实现
+type ChannelStore interface {
+	ListByUser(userID uint) ([]models.NotifyChannel, error)
+	// RecordResult 成功时 sentAt 非空、lastError 为空
+	RecordResult(id uint, sentAt *time.Time, lastError string) error
+}
+
+// Options 投递参数
+type Options struct {
+	SMTP           SMTPConfig
+	WebhookTimeout time.Duration
+	AllowPrivate   bool // 允许 webhook 指向内网地址，仅用于本地调试
+	Attempts       int
+	RetryBase      time.Duration
+	RetryMax       time.Duration
+}
+
+// Dispatcher 按用户的渠道设置投递消息，实现 Notifier
+type Dispatcher struct {
+	store  ChannelStore
+	opts   Options
+	client *http.Client
+}
+
+// NewDispatcher 创建 Dispatcher
+func NewDispatcher(store ChannelStore, opts Options) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		opts:   opts,
+		client: NewWebhookClient(opts.WebhookTimeout, opts.AllowPrivate),
+	}
+}
+
+// EmailEnabled 服务器是否配置了发件服务器
+func (d *Dispatcher) EmailEnabled() bool { return d.opts.SMTP.Enabled() }
+
+// Channel 按渠道设置创建 Notifier (不含重试)
+func (d *Dispatcher) Channel(ch *models.NotifyChannel) (Notifier, error) {
+	switch ch.Kind {
+	case models.ChannelWebhook:
+		return Webhook{URL: ch.Target, Secret: ch.Secret, Client: d.client}, nil
+	case models.ChannelEmail:
+		return Email{Server: d.opts.SMTP, To: ch.Target}, nil
+	}
+	return nil, fmt.Errorf("未知的通知渠道: %s", ch.Kind)
+}
+
+// Send 通过一个渠道发送 (失败时重试) 并记录结果
+func (d *Dispatcher) Send(ctx context.Context, ch *models.NotifyChannel, msg Message) error {
+	return d.deliver(ctx, ch, msg, d.opts.Attempts)
+}
+
+// Test 只尝试一次，用于用户手动测试渠道，结果同样会被记录
+func (d *Dispatcher) Test(ctx context.Context, ch *models.NotifyChannel, msg Message) error {
+	return d.deliver(ctx, ch, msg, 1)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, ch *models.NotifyChannel, msg Message, attempts int) error {
+	if msg.ID == "" {
+		msg.ID = newDeliveryID()
+	}
+	n, err := d.Channel(ch)
+	if err == nil {
+		err = Retry{Notifier: n, Attempts: attempts, Base: d.opts.RetryBase, Max: d.opts.RetryMax}.Notify(ctx, msg)
+	}
+
+	var sentAt *time.Time
+	lastError := ""
+	if err == nil {
+		now := time.Now()
+		sentAt = &now
+	} else {
+		lastError = err.Error()
+	}
+	if recordErr := d.store.RecordResult(ch.ID, sentAt, lastError); recordErr != nil {
+		fmt.Printf("⚠️ 记录通知渠道 %d 的投递结果失败: %v\n", ch.ID, recordErr)
+	}
+	return err
+}
+
+// Notify 并发发给该用户所有已启用 (邮箱还需已确认) 且订阅了 msg.Kind 的渠道，各渠道独立重试，返回所有失败渠道的错误
+func (d *Dispatcher) Notify(ctx context.Context, msg Message) error {
+	channels, err := d.store.ListByUser(msg.UserID)
+	if err != nil {
+		return err
+	}
+	if msg.ID == "" {
+		msg.ID = newDeliveryID()
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i := range channels {
+		ch := &channels[i]
+		// 未确认的邮箱不投递，即使被标记为启用
+		if !ch.Enabled || !ch.Subscribed(msg.Kind) || (ch.Kind == models.ChannelEmail && !ch.Verified) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.Send(ctx, ch, msg); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("渠道 %d (%s): %w", ch.ID, ch.Kind, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// newDeliveryID 随机的投递 ID
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
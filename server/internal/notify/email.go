@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig 发件服务器，所有用户的邮件渠道共用
+type SMTPConfig struct {
+	Host     string `json:"host"` // 为空表示不启用邮件通知
+	Port     int    `json:"port"` // 465 使用 TLS 直连，其他端口在服务器支持时使用 STARTTLS
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"` // 发件人地址
+}
+
+// Enabled 是否配置了发件服务器
+func (c SMTPConfig) Enabled() bool { return c.Host != "" }
+
+// Email 通过 SMTP 发给一个收件人
+type Email struct {
+	Server SMTPConfig
+	To     string
+}
+
+// Notify 发送一封邮件，服务器返回 5xx 时视为不可重试
+func (e Email) Notify(ctx context.Context, msg Message) error {
+	if !e.Server.Enabled() {
+		return Permanent(errors.New("服务器未配置 SMTP"))
+	}
+	err := e.send(ctx, buildMail(e.Server.From, e.To, msg))
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+func (e Email) send(ctx context.Context, data []byte) error {
+	host := e.Server.Host
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(e.Server.Port)))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	implicitTLS := e.Server.Port == 465
+	if implicitTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !implicitTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.Server.Username != "" {
+		// PlainAuth 只允许在 TLS 连接或本机上发送密码
+		if err := client.Auth(smtp.PlainAuth("", e.Server.Username, e.Server.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.Server.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(e.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMail 组装 UTF-8 邮件，标题用 RFC 2047 编码，正文 base64。
+// msg.HTML 非空时发送 multipart/alternative，同时带上纯文本正文
+func buildMail(from, to string, msg Message) []byte {
+	var b bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+	}
+	header("From", from)
+	header("To", to)
+	header("Subject", mime.BEncoding.Encode("UTF-8", msg.Title))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	if msg.ID != "" {
+		header("Message-ID", "<"+msg.ID+"@fund-tracker>")
+	}
+	header("MIME-Version", "1.0")
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="UTF-8"`)
+		header("Content-Transfer-Encoding", "base64")
+		b.WriteString("\r\n")
+		writeBase64(&b, []byte(msg.Body))
+		return b.Bytes()
+	}
+
+	boundary := "fund-tracker-" + newDeliveryID()
+	header("Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary))
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Body},
+		{"text/html", msg.HTML},
+	} {
+		b.WriteString("--" + boundary + "\r\n")
+		header("Content-Type", part.contentType+`; charset="UTF-8"`)
+		header("Content-Transfer-Encoding", "base64")
+		b.WriteString("\r\n")
+		writeBase64(&b, []byte(part.body))
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	return b.Bytes()
+}
+
+// writeBase64 按每行 76 个字符写入 base64
+func writeBase64(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
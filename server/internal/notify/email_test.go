@@ -0,0 +1,111 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fund-tracker-server/internal/fakenotify"
+	"fund-tracker-server/internal/notify"
+	"io"
+	"mime"
+	"mime/multipart"
+	"testing"
+)
+
+func startSMTP(t *testing.T) (*fakenotify.SMTPServer, notify.Email) {
+	t.Helper()
+	srv, err := fakenotify.StartSMTP("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv, notify.Email{
+		Server: notify.SMTPConfig{Host: srv.Host(), Port: srv.Port(), From: "alerts@fund.test"},
+		To:     "alice@example.com",
+	}
+}
+
+func TestEmailMultipart(t *testing.T) {
+	srv, email := startSMTP(t)
+	msg := notify.Message{ID: "d-1", Kind: notify.KindDigest, Title: "每日简报 2026-10-16", Body: "今日收益 +12.30", HTML: "<p>今日收益 <b>+12.30</b></p>"}
+	if err := email.Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	mails := srv.Mails()
+	if len(mails) != 1 || mails[0].From != "alerts@fund.test" || len(mails[0].To) != 1 || mails[0].To[0] != "alice@example.com" {
+		t.Fatalf("mails = %+v", mails)
+	}
+	parsed, err := mails[0].Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Title {
+		t.Fatalf("subject = %q, %v", subject, err)
+	}
+	if got := parsed.Header.Get("Message-ID"); got != "<d-1@fund-tracker>" {
+		t.Fatalf("Message-ID = %q", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v", mediaType, err)
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	want := []struct{ contentType, body string }{{"text/plain", msg.Body}, {"text/html", msg.HTML}}
+	for i, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if ct, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); ct != w.contentType {
+			t.Fatalf("part %d content type = %q, want %q", i, ct, w.contentType)
+		}
+		if enc := part.Header.Get("Content-Transfer-Encoding"); enc != "base64" {
+			t.Fatalf("part %d encoding = %q", i, enc)
+		}
+		body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil || string(body) != w.body {
+			t.Fatalf("part %d body = %q, %v", i, body, err)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Fatalf("extra part: %v", err)
+	}
+}
+
+func TestEmailPlainText(t *testing.T) {
+	srv, email := startSMTP(t)
+	if err := email.Notify(context.Background(), notify.Message{Title: "测试", Body: "只有纯文本"}); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := srv.Mails()[0].Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct, _, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type")); ct != "text/plain" {
+		t.Fatalf("content type = %q", ct)
+	}
+	body, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, parsed.Body))
+	if string(body) != "只有纯文本" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestEmailRejections(t *testing.T) {
+	srv, email := startSMTP(t)
+	srv.RejectNext(451, 550)
+	if err := email.Notify(context.Background(), notify.Message{Title: "a"}); err == nil || notify.IsPermanent(err) {
+		t.Fatalf("451: err = %v, want temporary", err)
+	}
+	if err := email.Notify(context.Background(), notify.Message{Title: "b"}); !notify.IsPermanent(err) {
+		t.Fatalf("550: err = %v, want permanent", err)
+	}
+	if len(srv.Mails()) != 0 {
+		t.Fatal("rejected mails should not be stored")
+	}
+
+	// 没有配置 SMTP 时不可重试
+	if err := (notify.Email{To: "alice@example.com"}).Notify(context.Background(), notify.Message{}); !notify.IsPermanent(err) {
+		t.Fatalf("disabled: err = %v, want permanent", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Package notify 把提醒等消息投递到 WebSocket 之外的渠道 (webhook、邮件)
+package notify
+
+import (
+	"context"
+	"errors"
+)
+
+// 消息类型
+const (
+	KindAlert  = "alert"  // 行情提醒触发
+	KindDigest = "digest" // 每日持仓简报
+	KindTest   = "test"   // 用户手动发送的测试消息，不受订阅设置限制
+)
+
+// Kinds 用户可以订阅的消息类型
+var Kinds = map[string]bool{KindAlert: true, KindDigest: true}
+
+// Message 发给某个用户的一条通知
+type Message struct {
+	ID     string      `json:"id"` // 投递 ID，重试时不变，接收方可据此去重
+	UserID uint        `json:"user_id"`
+	Kind   string      `json:"kind"`
+	Title  string      `json:"title"`
+	Body   string      `json:"body"` // 纯文本
+	HTML   string      `json:"-"`    // 可选的 HTML 正文，邮件中与纯文本一起发送
+	Data   interface{} `json:"data"` // 原始数据，如 models.AlertTrigger
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Func 把函数适配为 Notifier
+type Func func(ctx context.Context, msg Message) error
+
+// Notify 调用 f
+func (f Func) Notify(ctx context.Context, msg Message) error { return f(ctx, msg) }
+
+// permanentError 重试也不会成功的错误 (如地址不存在、签名被拒绝)
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记 err 不需要重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent err 是否被标记为不需要重试
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Retry 失败后按指数退避重试：第 n 次重试前等待 Base * 2^(n-1)，最长 Max。
+// 最多尝试 Attempts 次，遇到 Permanent 错误或 ctx 结束时立即返回
+type Retry struct {
+	Notifier Notifier
+	Attempts int
+	Base     time.Duration
+	Max      time.Duration
+}
+
+// Notify 发送并在失败时重试，返回最后一次的错误
+func (r Retry) Notify(ctx context.Context, msg Message) error {
+	for attempt := 1; ; attempt++ {
+		err := r.Notifier.Notify(ctx, msg)
+		if err == nil || IsPermanent(err) || attempt >= r.Attempts {
+			return err
+		}
+		timer := time.NewTimer(r.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("第 %d 次发送失败后超时: %w", attempt, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff 第 attempt 次发送失败后、下一次发送前的等待
+func (r Retry) backoff(attempt int) time.Duration {
+	delay := r.Base
+	for i := 1; i < attempt && delay < r.Max; i++ {
+		delay *= 2
+	}
+	if r.Max > 0 && delay > r.Max {
+		delay = r.Max
+	}
+	return delay
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffCap(t *testing.T) {
+	r := Retry{Base: time.Second, Max: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := r.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	// 很多次之后也不会溢出或超过上限
+	if got := r.backoff(100); got != r.Max {
+		t.Errorf("backoff(100) = %s, want %s", got, r.Max)
+	}
+	if got := (Retry{Base: time.Minute, Max: time.Second}).backoff(1); got != time.Second {
+		t.Errorf("base above max: %s, want 1s", got)
+	}
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	calls := 0
+	r := Retry{Attempts: 5, Base: time.Millisecond, Max: time.Millisecond, Notifier: Func(func(context.Context, Message) error {
+		calls++
+		if calls < 3 {
+			return errors.New("temporary")
+		}
+		return Permanent(errors.New("rejected"))
+	})}
+	err := r.Notify(context.Background(), Message{})
+	if !IsPermanent(err) || calls != 3 {
+		t.Fatalf("err=%v after %d calls, want permanent after 3", err, calls)
+	}
+}
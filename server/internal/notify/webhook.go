@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// webhook 请求头
+const (
+	HeaderEvent     = "X-Fund-Event"
+	HeaderDelivery  = "X-Fund-Delivery"
+	HeaderTimestamp = "X-Fund-Timestamp"
+	HeaderSignature = "X-Fund-Signature"
+)
+
+// errPrivateAddr 拒绝向内网地址发送 webhook，避免被用来探测服务器所在网络
+var errPrivateAddr = errors.New("不允许向内网或本机地址发送 webhook")
+
+// Sign 签名 = "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))。
+// 接收方用同样的方法计算并比较，同时检查时间戳以防重放
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名 (常量时间比较)
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// webhook 请求体
+type webhookPayload struct {
+	Message
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Webhook 向 URL 发送签名的 JSON POST，2xx 视为成功
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// NewWebhookClient webhook 使用的 HTTP 客户端：不跟随重定向；allowPrivate 为 false 时拒绝连接内网地址
+// (在 DNS 解析之后检查，域名解析到内网同样会被拒绝)
+func NewWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return errPrivateAddr
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Notify 发送一次，4xx (408、429 除外) 视为不可重试
+func (w Webhook) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(webhookPayload{Message: msg, SentAt: time.Now()})
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "fund-tracker-webhook/1")
+	req.Header.Set(HeaderEvent, msg.Kind)
+	req.Header.Set(HeaderDelivery, msg.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(w.Secret, timestamp, body))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		if errors.Is(err, errPrivateAddr) {
+			return Permanent(err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook 返回 %s", resp.Status)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"fund-tracker-server/internal/fakenotify"
+	"fund-tracker-server/internal/notify"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startSink 启动 fakenotify.Sink 并返回指向它的 webhook (允许本机地址)
+func startSink(t *testing.T, sinkSecret, hookSecret string) (*fakenotify.Sink, notify.Webhook) {
+	t.Helper()
+	sink, srv := fakenotify.StartSink(sinkSecret)
+	t.Cleanup(srv.Close)
+	return sink, notify.Webhook{URL: srv.URL, Secret: hookSecret, Client: notify.NewWebhookClient(time.Second, true)}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	sink, hook := startSink(t, "s3cret", "s3cret")
+	msg := notify.Message{ID: "delivery-1", UserID: 7, Kind: notify.KindAlert, Title: "提醒", Body: "110022 涨幅超过 2%"}
+	if err := hook.Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	hooks := sink.Hooks()
+	if len(hooks) != 1 || !hooks[0].Verified {
+		t.Fatalf("hooks = %+v, want one verified delivery", hooks)
+	}
+	h := hooks[0]
+	if h.Header.Get(notify.HeaderEvent) != notify.KindAlert || h.Header.Get(notify.HeaderDelivery) != "delivery-1" {
+		t.Fatalf("headers = %v", h.Header)
+	}
+	var payload struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(h.Body, &payload); err != nil || payload.ID != "delivery-1" || payload.Title != "提醒" {
+		t.Fatalf("payload = %s", h.Body)
+	}
+	// 改动正文或时间戳后签名不再成立
+	ts := h.Header.Get(notify.HeaderTimestamp)
+	sig := h.Header.Get(notify.HeaderSignature)
+	if notify.Verify("s3cret", ts, append(h.Body, ' '), sig) || notify.Verify("s3cret", ts+"1", h.Body, sig) {
+		t.Fatal("signature should not verify a modified request")
+	}
+
+	// 密钥不一致时接收方校验失败
+	sink, hook = startSink(t, "s3cret", "other")
+	if err := hook.Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if sink.Hooks()[0].Verified {
+		t.Fatal("signature with the wrong secret should not verify")
+	}
+}
+
+func TestWebhookPermanentStatuses(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusNotFound, true},
+		{http.StatusGone, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			sink, hook := startSink(t, "", "k")
+			sink.FailNext(tt.status)
+			err := hook.Notify(context.Background(), notify.Message{Kind: notify.KindTest})
+			if err == nil || notify.IsPermanent(err) != tt.permanent {
+				t.Fatalf("status %d: err=%v permanent=%v, want permanent=%v", tt.status, err, notify.IsPermanent(err), tt.permanent)
+			}
+		})
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	retry := func(hook notify.Webhook) notify.Retry {
+		return notify.Retry{Notifier: hook, Attempts: 4, Base: time.Millisecond, Max: 2 * time.Millisecond}
+	}
+
+	// 5xx 和 429 重试直到成功，投递 ID 不变
+	sink, hook := startSink(t, "k", "k")
+	sink.FailNext(503, 429, 500)
+	if err := retry(hook).Notify(context.Background(), notify.Message{ID: "d-1", Kind: notify.KindAlert}); err != nil {
+		t.Fatal(err)
+	}
+	hooks := sink.Hooks()
+	if len(hooks) != 4 || len(sink.Delivered()) != 1 {
+		t.Fatalf("%d requests, %d delivered, want 4 and 1", len(hooks), len(sink.Delivered()))
+	}
+	for _, h := range hooks {
+		if h.Header.Get(notify.HeaderDelivery) != "d-1" || !h.Verified {
+			t.Fatalf("retry changed the delivery: %v", h.Header)
+		}
+	}
+
+	// 4xx 不重试
+	sink, hook = startSink(t, "k", "k")
+	sink.FailNext(404)
+	if err := retry(hook).Notify(context.Background(), notify.Message{Kind: notify.KindAlert}); !notify.IsPermanent(err) {
+		t.Fatalf("err = %v, want permanent", err)
+	}
+	if n := len(sink.Hooks()); n != 1 {
+		t.Fatalf("4xx was retried: %d requests", n)
+	}
+
+	// 次数用完后返回最后一次的错误
+	sink, hook = startSink(t, "k", "k")
+	sink.FailNext(500, 500, 500, 500, 500)
+	if err := retry(hook).Notify(context.Background(), notify.Message{Kind: notify.KindAlert}); err == nil || notify.IsPermanent(err) {
+		t.Fatalf("err = %v, want a temporary error", err)
+	}
+	if n := len(sink.Hooks()); n != 4 {
+		t.Fatalf("%d requests, want 4 attempts", n)
+	}
+}
+
+func TestWebhookRejectsPrivateAddress(t *testing.T) {
+	sink, hook := startSink(t, "", "k")
+	hook.Client = notify.NewWebhookClient(time.Second, false)
+	if err := hook.Notify(context.Background(), notify.Message{Kind: notify.KindTest}); !notify.IsPermanent(err) {
+		t.Fatalf("err = %v, want permanent", err)
+	}
+	if len(sink.Hooks()) != 0 {
+		t.Fatal("request reached a loopback address")
+	}
+}
@@ -0,0 +1,157 @@
+// Package ratelimit 进程内的限流 (令牌桶) 和登录失败锁定，多实例部署时各实例独立计数
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// 空闲记录的清理间隔
+const sweepInterval = time.Minute
+
+// Limiter 按 key 的令牌桶：最多连续 requests 次，之后按 requests/per 的速度恢复
+type Limiter struct {
+	rate  float64 // 每秒恢复的令牌数
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New 创建 Limiter，每个 key 在 per 时间内最多 requests 次
+func New(requests int, per time.Duration) *Limiter {
+	return &Limiter{
+		rate:    float64(requests) / per.Seconds(),
+		burst:   float64(requests),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow 消耗一次额度，额度不足时返回 false 和需要等待的时长
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.rate
+	if tokens > l.burst {
+		return l.burst
+	}
+	return tokens
+}
+
+// sweep 删除已经回满的桶，避免 key 无限增长
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Lockout 连续失败锁定：失败 threshold 次后锁定 base，之后每次失败锁定时长翻倍，最长 max。
+// 成功或超过 max 没有再失败时清零
+type Lockout struct {
+	threshold int
+	base, max time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*lockEntry
+	lastSweep time.Time
+}
+
+type lockEntry struct {
+	failures int
+	last     time.Time // 最近一次失败
+	until    time.Time // 锁定截止时间
+}
+
+// NewLockout 创建 Lockout
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{threshold: threshold, base: base, max: max, entries: make(map[string]*lockEntry)}
+}
+
+// Locked 剩余的锁定时长，未锁定时为 0
+func (l *Lockout) Locked(key string) time.Duration {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok && now.Before(e.until) {
+		return e.until.Sub(now)
+	}
+	return 0
+}
+
+// Fail 记录一次失败，返回本次触发的锁定时长 (未触发为 0)
+func (l *Lockout) Fail(key string) time.Duration {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.last) > l.max {
+		e = &lockEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+	if e.failures < l.threshold {
+		return 0
+	}
+	d := l.base
+	for i := l.threshold; i < e.failures && d < l.max; i++ {
+		d *= 2
+	}
+	if d > l.max {
+		d = l.max
+	}
+	e.until = now.Add(d)
+	return d
+}
+
+// Reset 成功后清零
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, e := range l.entries {
+		if now.After(e.until) && now.Sub(e.last) > l.max {
+			delete(l.entries, key)
+		}
+	}
+}
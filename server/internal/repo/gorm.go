@@ -0,0 +1,678 @@
+package repo
+
+import (
+	"errors"
+	"fund-tracker-server/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewGormStore 基于 GORM 的 Store，Atomic 使用数据库事务
+func NewGormStore(conn *gorm.DB) Store {
+	s := Store{
+		Users:           gormUsers{conn},
+		Tokens:          gormTokens{conn},
+		Portfolios:      gormPortfolios{conn},
+		Holdings:        gormHoldings{conn},
+		Watchlist:       gormWatchlist{conn},
+		WatchlistGroups: gormWatchlistGroups{conn},
+		Transactions:    gormTransactions{conn},
+		NavHistory:      gormNavHistory{conn},
+		NavEstimates:    gormNavEstimates{conn},
+		Snapshots:       gormSnapshots{conn},
+		Alerts:          gormAlerts{conn},
+		NotifyChannels:  gormNotifyChannels{conn},
+	}
+	s.atomic = func(fn func(Store) error) error {
+		return conn.Transaction(func(tx *gorm.DB) error {
+			// 事务内的 Store 不再嵌套事务
+			inner := NewGormStore(tx)
+			inner.atomic = nil
+			return fn(inner)
+		})
+	}
+	return s
+}
+
+// first 查询单条，记录不存在时返回 ErrNotFound
+func first(query *gorm.DB, dst interface{}) error {
+	err := query.First(dst).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// dateRange 按 from/to 过滤 date 列
+func dateRange(query *gorm.DB, from, to string) *gorm.DB {
+	if from != "" {
+		query = query.Where("date >= ?", from)
+	}
+	if to != "" {
+		query = query.Where("date <= ?", to)
+	}
+	return query
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) Create(user *models.User) error {
+	return duplicate(r.db.Create(user).Error)
+}
+
+func (r gormUsers) FindByUsername(username string) (*models.User, error) {
+	var user models.User
+	if err := first(r.db.Where("username = ?", username), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r gormUsers) FindByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := first(r.db.Where("id = ?", id), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r gormUsers) BumpTokenVersion(id uint) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+func (r gormUsers) UpdatePassword(id uint, hash string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("password", hash).Error
+}
+
+func (r gormUsers) UpdateProfile(id uint, displayName, baseCurrency string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"display_name": displayName, "base_currency": baseCurrency}).Error
+}
+
+func (r gormUsers) Delete(id uint) error {
+	return r.db.Unscoped().Delete(&models.User{}, id).Error
+}
+
+type gormTokens struct{ db *gorm.DB }
+
+func (r gormTokens) CreateRefresh(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r gormTokens) FindRefresh(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := first(r.db.Where("token_hash = ?", hash), &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r gormTokens) RevokeRefresh(id uint) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r gormTokens) RevokeFamily(userID uint, familyID string) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r gormTokens) RevokeAllRefresh(userID uint) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r gormTokens) RevokeAccess(jti string, expiresAt time.Time) error {
+	// 重复吊销同一个令牌时保留已有记录
+	var count int64
+	if err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return r.db.Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (r gormTokens) IsAccessRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+func (r gormTokens) PurgeExpired(now time.Time) error {
+	if err := r.db.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error
+}
+
+func (r gormTokens) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.RefreshToken{}).Error
+}
+
+// inPortfolio 按用户过滤，portfolioID 非 0 时再按组合过滤
+func inPortfolio(query *gorm.DB, userID, portfolioID uint) *gorm.DB {
+	query = query.Where("user_id = ?", userID)
+	if portfolioID != 0 {
+		query = query.Where("portfolio_id = ?", portfolioID)
+	}
+	return query
+}
+
+// duplicate 唯一约束冲突转换为 ErrDuplicate
+func duplicate(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+type gormPortfolios struct{ db *gorm.DB }
+
+func (r gormPortfolios) ListByUser(userID uint) ([]models.Portfolio, error) {
+	var portfolios []models.Portfolio
+	err := r.db.Where("user_id = ?", userID).Order("id asc").Find(&portfolios).Error
+	return portfolios, err
+}
+
+func (r gormPortfolios) Find(userID, id uint) (*models.Portfolio, error) {
+	var portfolio models.Portfolio
+	if err := first(r.db.Where("id = ? AND user_id = ?", id, userID), &portfolio); err != nil {
+		return nil, err
+	}
+	return &portfolio, nil
+}
+
+func (r gormPortfolios) Default(userID uint) (*models.Portfolio, error) {
+	var portfolio models.Portfolio
+	if err := first(r.db.Where("user_id = ?", userID).Order("id asc"), &portfolio); err != nil {
+		return nil, err
+	}
+	return &portfolio, nil
+}
+
+func (r gormPortfolios) Create(portfolio *models.Portfolio) error {
+	return duplicate(r.db.Create(portfolio).Error)
+}
+
+func (r gormPortfolios) Save(portfolio *models.Portfolio) error {
+	return duplicate(r.db.Save(portfolio).Error)
+}
+
+func (r gormPortfolios) Delete(userID, id uint) error {
+	return r.db.Unscoped().Where("id = ? AND user_id = ?", id, userID).Delete(&models.Portfolio{}).Error
+}
+
+func (r gormPortfolios) DeleteByUser(userID uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userID).Delete(&models.Portfolio{}).Error
+}
+
+type gormHoldings struct{ db *gorm.DB }
+
+func (r gormHoldings) ListByUser(userID, portfolioID uint) ([]models.Holding, error) {
+	var holdings []models.Holding
+	err := inPortfolio(r.db, userID, portfolioID).Order("portfolio_id asc, id asc").Find(&holdings).Error
+	return holdings, err
+}
+
+func (r gormHoldings) Find(userID, portfolioID uint, code string) (*models.Holding, error) {
+	var holding models.Holding
+	if err := first(inPortfolio(r.db, userID, portfolioID).Where("fund_code = ?", code), &holding); err != nil {
+		return nil, err
+	}
+	return &holding, nil
+}
+
+func (r gormHoldings) Save(holding *models.Holding) error {
+	return r.db.Save(holding).Error
+}
+
+func (r gormHoldings) Delete(userID, portfolioID uint, code string) error {
+	return inPortfolio(r.db.Unscoped(), userID, portfolioID).Where("fund_code = ?", code).Delete(&models.Holding{}).Error
+}
+
+func (r gormHoldings) DeleteByPortfolio(userID, portfolioID uint) error {
+	return r.db.Unscoped().Where("user_id = ? AND portfolio_id = ?", userID, portfolioID).Delete(&models.Holding{}).Error
+}
+
+func (r gormHoldings) UpdateQuote(id uint, name, price, change string) error {
+	return r.db.Model(&models.Holding{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"fund_name":  name,
+			"last_price": price,
+			"change":     change,
+		}).Error
+}
+
+func (r gormHoldings) DeleteByUser(userID uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userID).Delete(&models.Holding{}).Error
+}
+
+type gormWatchlist struct{ db *gorm.DB }
+
+func (r gormWatchlist) ListByUser(userID uint) ([]models.Watchlist, error) {
+	var watchlist []models.Watchlist
+	err := r.db.Where("user_id = ?", userID).Order("group_id asc, sort_order asc, id asc").Find(&watchlist).Error
+	return watchlist, err
+}
+
+func (r gormWatchlist) Codes(userID uint) ([]string, error) {
+	var codes []string
+	err := r.db.Model(&models.Watchlist{}).Where("user_id = ?", userID).Pluck("fund_code", &codes).Error
+	return codes, err
+}
+
+func (r gormWatchlist) Find(userID uint, code string) (*models.Watchlist, error) {
+	var entry models.Watchlist
+	if err := first(r.db.Where("user_id = ? AND fund_code = ?", userID, code), &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r gormWatchlist) Add(entry *models.Watchlist) error {
+	var last int
+	err := r.db.Model(&models.Watchlist{}).Where("user_id = ? AND group_id = ?", entry.UserID, entry.GroupID).
+		Select("COALESCE(MAX(sort_order), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	entry.SortOrder = last + 1
+	return duplicate(r.db.Create(entry).Error)
+}
+
+func (r gormWatchlist) Save(entry *models.Watchlist) error {
+	return r.db.Save(entry).Error
+}
+
+func (r gormWatchlist) Reorder(userID, groupID uint, codes []string) error {
+	for i, code := range codes {
+		res := r.db.Model(&models.Watchlist{}).Where("user_id = ? AND fund_code = ?", userID, code).
+			Updates(map[string]interface{}{"group_id": groupID, "sort_order": i + 1})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+	}
+	query := r.db.Where("user_id = ? AND group_id = ?", userID, groupID)
+	if len(codes) > 0 {
+		query = query.Where("fund_code NOT IN ?", codes)
+	}
+	var rest []models.Watchlist
+	if err := query.Order("sort_order asc, id asc").Find(&rest).Error; err != nil {
+		return err
+	}
+	for i, entry := range rest {
+		if err := r.db.Model(&entry).Update("sort_order", len(codes)+i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r gormWatchlist) Ungroup(userID, groupID uint) error {
+	var last int
+	err := r.db.Model(&models.Watchlist{}).Where("user_id = ? AND group_id = 0", userID).
+		Select("COALESCE(MAX(sort_order), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	var moved []models.Watchlist
+	if err := r.db.Where("user_id = ? AND group_id = ?", userID, groupID).Order("sort_order asc, id asc").Find(&moved).Error; err != nil {
+		return err
+	}
+	// 排在未分组的最后，保持原来的相对顺序
+	for i, entry := range moved {
+		err := r.db.Model(&entry).Updates(map[string]interface{}{"group_id": 0, "sort_order": last + i + 1}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r gormWatchlist) Delete(userID uint, code string) error {
+	return r.db.Unscoped().Where("user_id = ? AND fund_code = ?", userID, code).Delete(&models.Watchlist{}).Error
+}
+
+func (r gormWatchlist) DeleteByUser(userID uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userID).Delete(&models.Watchlist{}).Error
+}
+
+type gormWatchlistGroups struct{ db *gorm.DB }
+
+func (r gormWatchlistGroups) ListByUser(userID uint) ([]models.WatchlistGroup, error) {
+	var groups []models.WatchlistGroup
+	err := r.db.Where("user_id = ?", userID).Order("sort_order asc, id asc").Find(&groups).Error
+	return groups, err
+}
+
+func (r gormWatchlistGroups) Find(userID, id uint) (*models.WatchlistGroup, error) {
+	var group models.WatchlistGroup
+	if err := first(r.db.Where("id = ? AND user_id = ?", id, userID), &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r gormWatchlistGroups) Create(group *models.WatchlistGroup) error {
+	var last int
+	err := r.db.Model(&models.WatchlistGroup{}).Where("user_id = ?", group.UserID).
+		Select("COALESCE(MAX(sort_order), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	group.SortOrder = last + 1
+	return duplicate(r.db.Create(group).Error)
+}
+
+func (r gormWatchlistGroups) Save(group *models.WatchlistGroup) error {
+	return duplicate(r.db.Save(group).Error)
+}
+
+func (r gormWatchlistGroups) Reorder(userID uint, ids []uint) error {
+	for i, id := range ids {
+		res := r.db.Model(&models.WatchlistGroup{}).Where("id = ? AND user_id = ?", id, userID).Update("sort_order", i+1)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+func (r gormWatchlistGroups) Delete(userID, id uint) error {
+	return r.db.Unscoped().Where("id = ? AND user_id = ?", id, userID).Delete(&models.WatchlistGroup{}).Error
+}
+
+func (r gormWatchlistGroups) DeleteByUser(userID uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userID).Delete(&models.WatchlistGroup{}).Error
+}
+
+type gormTransactions struct{ db *gorm.DB }
+
+func (r gormTransactions) List(userID, portfolioID uint, code string) ([]models.Transaction, error) {
+	query := inPortfolio(r.db, userID, portfolioID)
+	if code != "" {
+		query = query.Where("fund_code = ?", code)
+	}
+	var txs []models.Transaction
+	err := query.Order("trade_date desc, id desc").Find(&txs).Error
+	return txs, err
+}
+
+func (r gormTransactions) Find(userID, id uint) (*models.Transaction, error) {
+	var record models.Transaction
+	if err := first(r.db.Where("id = ? AND user_id = ?", id, userID), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r gormTransactions) Create(record *models.Transaction) error {
+	return r.db.Create(record).Error
+}
+
+func (r gormTransactions) Save(record *models.Transaction) error {
+	return r.db.Save(record).Error
+}
+
+func (r gormTransactions) Delete(record *models.Transaction) error {
+	return r.db.Unscoped().Delete(record).Error
+}
+
+func (r gormTransactions) DeleteByFund(userID, portfolioID uint, code string) error {
+	return inPortfolio(r.db.Unscoped(), userID, portfolioID).Where("fund_code = ?", code).Delete(&models.Transaction{}).Error
+}
+
+func (r gormTransactions) DeleteByPortfolio(userID, portfolioID uint) error {
+	return r.db.Unscoped().Where("user_id = ? AND portfolio_id = ?", userID, portfolioID).Delete(&models.Transaction{}).Error
+}
+
+func (r gormTransactions) DeleteByUser(userID uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userID).Delete(&models.Transaction{}).Error
+}
+
+type gormNavHistory struct{ db *gorm.DB }
+
+func (r gormNavHistory) Range(code, from, to string) ([]models.NavHistory, error) {
+	var rows []models.NavHistory
+	err := dateRange(r.db.Where("fund_code = ?", code), from, to).Order("date asc").Find(&rows).Error
+	return rows, err
+}
+
+func (r gormNavHistory) Count(code string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.NavHistory{}).Where("fund_code = ?", code).Count(&count).Error
+	return count, err
+}
+
+func (r gormNavHistory) Tracked(code string) (bool, error) {
+	for _, model := range []interface{}{&models.Holding{}, &models.Watchlist{}} {
+		var count int64
+		if err := r.db.Model(model).Where("fund_code = ?", code).Limit(1).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r gormNavHistory) OnDate(date string, codes []string) ([]models.NavHistory, error) {
+	var rows []models.NavHistory
+	if len(codes) == 0 {
+		return rows, nil
+	}
+	err := r.db.Where("date = ? AND fund_code IN ?", date, codes).Find(&rows).Error
+	return rows, err
+}
+
+type gormNavEstimates struct{ db *gorm.DB }
+
+func (r gormNavEstimates) Create(estimate *models.NavEstimate) error {
+	return duplicate(r.db.Create(estimate).Error)
+}
+
+func (r gormNavEstimates) Save(estimate *models.NavEstimate) error {
+	return r.db.Save(estimate).Error
+}
+
+func (r gormNavEstimates) OnDate(date string, codes []string) ([]models.NavEstimate, error) {
+	var rows []models.NavEstimate
+	if len(codes) == 0 {
+		return rows, nil
+	}
+	err := r.db.Where("date = ? AND fund_code IN ?", date, codes).Find(&rows).Error
+	return rows, err
+}
+
+func (r gormNavEstimates) Pending() ([]models.NavEstimate, error) {
+	var rows []models.NavEstimate
+	err := r.db.Where("confirmed = ?", false).Order("date asc, fund_code asc").Find(&rows).Error
+	return rows, err
+}
+
+func (r gormNavEstimates) Range(code, from, to string) ([]models.NavEstimate, error) {
+	var rows []models.NavEstimate
+	err := dateRange(r.db.Where("fund_code = ?", code), from, to).Order("date asc").Find(&rows).Error
+	return rows, err
+}
+
+func (r gormNavEstimates) Confirmed(codes []string, from string) ([]models.NavEstimate, error) {
+	var rows []models.NavEstimate
+	if len(codes) == 0 {
+		return rows, nil
+	}
+	err := dateRange(r.db.Where("confirmed = ? AND fund_code IN ?", true, codes), from, "").
+		Order("fund_code asc, date asc").Find(&rows).Error
+	return rows, err
+}
+
+type gormSnapshots struct{ db *gorm.DB }
+
+func (r gormSnapshots) EquityCurve(userID uint, from, to string) ([]EquityPoint, error) {
+	var points []EquityPoint
+	err := dateRange(r.db.Model(&models.PortfolioSnapshot{}).Where("user_id = ?", userID), from, to).
+		Select("date, SUM(value) AS value, SUM(cost) AS cost, SUM(realized_return) AS realized_return, " +
+			"SUM(CASE WHEN nav_status = 'confirmed' THEN 0 ELSE 1 END) > 0 AS estimated").
+		Group("date").Order("date asc").
+		Scan(&points).Error
+	return points, err
+}
+
+func (r gormSnapshots) ListByUser(userID uint) ([]models.PortfolioSnapshot, error) {
+	var rows []models.PortfolioSnapshot
+	err := r.db.Where("user_id = ?", userID).Order("date asc, fund_code asc").Find(&rows).Error
+	return rows, err
+}
+
+func (r gormSnapshots) OnDate(userID uint, date string) ([]models.PortfolioSnapshot, error) {
+	var rows []models.PortfolioSnapshot
+	err := r.db.Where("user_id = ? AND date = ?", userID, date).Order("fund_code asc").Find(&rows).Error
+	return rows, err
+}
+
+func (r gormSnapshots) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.PortfolioSnapshot{}).Error
+}
+
+type gormAlerts struct{ db *gorm.DB }
+
+func (r gormAlerts) ListByUser(userID uint) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.Where("user_id = ?", userID).Order("id asc").Find(&alerts).Error
+	return alerts, err
+}
+
+func (r gormAlerts) ListEnabled() ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.Where("enabled = ?", true).Order("id asc").Find(&alerts).Error
+	return alerts, err
+}
+
+func (r gormAlerts) Find(userID, id uint) (*models.Alert, error) {
+	var alert models.Alert
+	if err := first(r.db.Where("id = ? AND user_id = ?", id, userID), &alert); err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r gormAlerts) Create(alert *models.Alert) error {
+	// 同 NotifyChannel，enabled 列默认为 true，创建时停用的提醒需要单独写入
+	enabled := alert.Enabled
+	if err := r.db.Create(alert).Error; err != nil {
+		return err
+	}
+	if !enabled {
+		alert.Enabled = false
+		return r.db.Model(alert).UpdateColumn("enabled", false).Error
+	}
+	return nil
+}
+
+func (r gormAlerts) Save(alert *models.Alert) error {
+	return r.db.Save(alert).Error
+}
+
+func (r gormAlerts) SetActive(id uint, active bool, triggeredAt *time.Time) error {
+	updates := map[string]interface{}{"active": active}
+	if triggeredAt != nil {
+		updates["last_triggered_at"] = *triggeredAt
+	}
+	return r.db.Model(&models.Alert{}).Where("id = ?", id).UpdateColumns(updates).Error
+}
+
+func (r gormAlerts) Delete(userID, id uint) error {
+	if err := r.db.Where("alert_id = ? AND user_id = ?", id, userID).Delete(&models.AlertTrigger{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Alert{}).Error
+}
+
+func (r gormAlerts) CreateTrigger(trigger *models.AlertTrigger) error {
+	return r.db.Create(trigger).Error
+}
+
+func (r gormAlerts) Triggers(userID, alertID uint, limit int) ([]models.AlertTrigger, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if alertID != 0 {
+		query = query.Where("alert_id = ?", alertID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var triggers []models.AlertTrigger
+	err := query.Order("id desc").Find(&triggers).Error
+	return triggers, err
+}
+
+func (r gormAlerts) DeleteByUser(userID uint) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&models.AlertTrigger{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("user_id = ?", userID).Delete(&models.Alert{}).Error
+}
+
+type gormNotifyChannels struct{ db *gorm.DB }
+
+func (r gormNotifyChannels) ListByUser(userID uint) ([]models.NotifyChannel, error) {
+	var channels []models.NotifyChannel
+	err := r.db.Where("user_id = ?", userID).Order("id asc").Find(&channels).Error
+	return channels, err
+}
+
+func (r gormNotifyChannels) Find(userID, id uint) (*models.NotifyChannel, error) {
+	var channel models.NotifyChannel
+	if err := first(r.db.Where("id = ? AND user_id = ?", id, userID), &channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (r gormNotifyChannels) Create(channel *models.NotifyChannel) error {
+	// enabled 列默认为 true，零值不会被写入 (创建后还会被回填为 true)
+	enabled := channel.Enabled
+	if err := r.db.Create(channel).Error; err != nil {
+		return err
+	}
+	if !enabled {
+		channel.Enabled = false
+		return r.db.Model(channel).UpdateColumn("enabled", false).Error
+	}
+	return nil
+}
+
+func (r gormNotifyChannels) Save(channel *models.NotifyChannel) error {
+	return r.db.Save(channel).Error
+}
+
+func (r gormNotifyChannels) RecordResult(id uint, sentAt *time.Time, lastError string) error {
+	updates := map[string]interface{}{"last_error": lastError}
+	if sentAt != nil {
+		updates["last_sent_at"] = *sentAt
+	}
+	return r.db.Model(&models.NotifyChannel{}).Where("id = ?", id).UpdateColumns(updates).Error
+}
+
+func (r gormNotifyChannels) Delete(userID, id uint) error {
+	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.NotifyChannel{}).Error
+}
+
+func (r gormNotifyChannels) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.NotifyChannel{}).Error
+}
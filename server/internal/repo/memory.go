@@ -0,0 +1,13 @@
+package repo
+
+import "fund-tracker-server/internal/db"
+
+// NewMemoryStore 基于独立 sqlite 内存库 (已迁移到最新版本) 的 Store，供测试使用。
+// 每个 name 一个库，测试之间不共享数据
+func NewMemoryStore(name string) (Store, error) {
+	conn, err := db.OpenMemory(name)
+	if err != nil {
+		return Store{}, err
+	}
+	return NewGormStore(conn), nil
+}
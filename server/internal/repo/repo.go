@@ -0,0 +1,215 @@
+// Package repo 数据访问接口。handler 只依赖这里的接口，GORM 实现见 gorm.go
+package repo
+
+import (
+	"errors"
+	"fund-tracker-server/internal/models"
+	"time"
+)
+
+var (
+	// ErrNotFound 查询的记录不存在
+	ErrNotFound = errors.New("记录不存在")
+	// ErrDuplicate 违反唯一约束 (如用户名已存在)
+	ErrDuplicate = errors.New("记录已存在")
+)
+
+// UserRepo 用户
+type UserRepo interface {
+	// Create 用户名已存在时返回 ErrDuplicate
+	Create(user *models.User) error
+	FindByUsername(username string) (*models.User, error)
+	FindByID(id uint) (*models.User, error)
+	// BumpTokenVersion 令牌版本加一，使该用户已签发的访问令牌全部失效
+	BumpTokenVersion(id uint) error
+	UpdatePassword(id uint, hash string) error
+	UpdateProfile(id uint, displayName, baseCurrency string) error
+	// Delete 彻底删除用户 (不是软删除)，用户名可被重新注册
+	Delete(id uint) error
+}
+
+// TokenRepo 刷新令牌和被吊销的访问令牌
+type TokenRepo interface {
+	CreateRefresh(token *models.RefreshToken) error
+	FindRefresh(hash string) (*models.RefreshToken, error)
+	// RevokeRefresh 吊销一个刷新令牌，返回是否由本次调用吊销 (并发换发时只有一个成功)
+	RevokeRefresh(id uint) (bool, error)
+	RevokeFamily(userID uint, familyID string) error
+	RevokeAllRefresh(userID uint) error
+	RevokeAccess(jti string, expiresAt time.Time) error
+	IsAccessRevoked(jti string) (bool, error)
+	// PurgeExpired 清理已过期的刷新令牌和吊销记录
+	PurgeExpired(now time.Time) error
+	DeleteByUser(userID uint) error
+}
+
+// PortfolioRepo 投资组合
+type PortfolioRepo interface {
+	// ListByUser 按创建顺序，第一个为默认组合
+	ListByUser(userID uint) ([]models.Portfolio, error)
+	Find(userID, id uint) (*models.Portfolio, error)
+	// Default 用户 ID 最小的组合，没有时返回 ErrNotFound
+	Default(userID uint) (*models.Portfolio, error)
+	// Create / Save 同一用户下组合重名时返回 ErrDuplicate
+	Create(portfolio *models.Portfolio) error
+	Save(portfolio *models.Portfolio) error
+	Delete(userID, id uint) error
+	DeleteByUser(userID uint) error
+}
+
+// HoldingRepo 持仓 (流水重放后的投影)，portfolioID 为 0 表示用户的全部组合
+type HoldingRepo interface {
+	ListByUser(userID, portfolioID uint) ([]models.Holding, error)
+	Find(userID, portfolioID uint, code string) (*models.Holding, error)
+	Save(holding *models.Holding) error
+	Delete(userID, portfolioID uint, code string) error
+	// UpdateQuote 只更新缓存的名称和行情，不影响份额和成本
+	UpdateQuote(id uint, name, price, change string) error
+	DeleteByPortfolio(userID, portfolioID uint) error
+	DeleteByUser(userID uint) error
+}
+
+// WatchlistRepo 自选，列表按 sort_order 排序
+type WatchlistRepo interface {
+	ListByUser(userID uint) ([]models.Watchlist, error)
+	Codes(userID uint) ([]string, error)
+	Find(userID uint, code string) (*models.Watchlist, error)
+	// Add 排在所在分组的最后，已存在时返回 ErrDuplicate
+	Add(entry *models.Watchlist) error
+	Save(entry *models.Watchlist) error
+	// Reorder 把 codes 依次移入 groupID 分组并排在最前，分组内其余自选保持原顺序排在后面。
+	// 有不在自选中的代码时返回 ErrNotFound
+	Reorder(userID, groupID uint, codes []string) error
+	// Ungroup 把分组内的自选移到未分组的最后
+	Ungroup(userID, groupID uint) error
+	Delete(userID uint, code string) error
+	DeleteByUser(userID uint) error
+}
+
+// WatchlistGroupRepo 自选分组，列表按 sort_order 排序
+type WatchlistGroupRepo interface {
+	ListByUser(userID uint) ([]models.WatchlistGroup, error)
+	Find(userID, id uint) (*models.WatchlistGroup, error)
+	// Create 排在最后；Create / Save 同一用户下重名时返回 ErrDuplicate
+	Create(group *models.WatchlistGroup) error
+	Save(group *models.WatchlistGroup) error
+	// Reorder 按 ids 的顺序重新编号，有不存在的分组时返回 ErrNotFound
+	Reorder(userID uint, ids []uint) error
+	Delete(userID, id uint) error
+	DeleteByUser(userID uint) error
+}
+
+// TransactionRepo 交易流水
+type TransactionRepo interface {
+	// List portfolioID 为 0 时返回全部组合，code 为空时返回全部基金，按交易日期倒序
+	List(userID, portfolioID uint, code string) ([]models.Transaction, error)
+	Find(userID, id uint) (*models.Transaction, error)
+	Create(record *models.Transaction) error
+	Save(record *models.Transaction) error
+	Delete(record *models.Transaction) error
+	DeleteByFund(userID, portfolioID uint, code string) error
+	DeleteByPortfolio(userID, portfolioID uint) error
+	DeleteByUser(userID uint) error
+}
+
+// NavHistoryRepo 历史净值
+type NavHistoryRepo interface {
+	// Range from/to 为 2006-01-02，空字符串表示不限，按日期升序
+	Range(code, from, to string) ([]models.NavHistory, error)
+	Count(code string) (int64, error)
+	// Tracked 是否有用户持有或自选了 code
+	Tracked(code string) (bool, error)
+	// OnDate 多只基金在 date 当天的净值，没有数据的代码不出现在结果中
+	OnDate(date string, codes []string) ([]models.NavHistory, error)
+}
+
+// NavEstimateRepo 每天收盘后的最后一次估值及其与确认净值的对比
+type NavEstimateRepo interface {
+	// Create (fund_code, date) 已存在时返回 ErrDuplicate
+	Create(estimate *models.NavEstimate) error
+	Save(estimate *models.NavEstimate) error
+	// OnDate 多只基金在 date 当天的估值，没有记录的代码不出现在结果中
+	OnDate(date string, codes []string) ([]models.NavEstimate, error)
+	// Pending 还没有对比确认净值的记录，按日期升序
+	Pending() ([]models.NavEstimate, error)
+	// Range 一只基金的记录，from/to 含义同 NavHistoryRepo.Range
+	Range(code, from, to string) ([]models.NavEstimate, error)
+	// Confirmed 多只基金自 from 起已对比确认净值的记录，按代码和日期升序
+	Confirmed(codes []string, from string) ([]models.NavEstimate, error)
+}
+
+// EquityPoint 资产曲线上的一天
+type EquityPoint struct {
+	Date           string  `json:"date"`
+	Value          float64 `json:"value"`
+	Cost           float64 `json:"cost"`
+	RealizedReturn float64 `json:"realized_return"`
+	Estimated      bool    `json:"estimated"` // 当天有持仓使用的是估值
+}
+
+// SnapshotRepo 持仓快照
+type SnapshotRepo interface {
+	// EquityCurve 按日汇总快照，from/to 含义同 NavHistoryRepo.Range
+	EquityCurve(userID uint, from, to string) ([]EquityPoint, error)
+	ListByUser(userID uint) ([]models.PortfolioSnapshot, error)
+	// OnDate 用户 date 当天的快照
+	OnDate(userID uint, date string) ([]models.PortfolioSnapshot, error)
+	DeleteByUser(userID uint) error
+}
+
+// AlertRepo 行情提醒及其触发记录
+type AlertRepo interface {
+	ListByUser(userID uint) ([]models.Alert, error)
+	// ListEnabled 所有用户已启用的提醒，供后台检查
+	ListEnabled() ([]models.Alert, error)
+	Find(userID, id uint) (*models.Alert, error)
+	Create(alert *models.Alert) error
+	Save(alert *models.Alert) error
+	// SetActive 只更新触发状态，不覆盖用户同时做的修改；triggeredAt 为 nil 时不更新触发时间
+	SetActive(id uint, active bool, triggeredAt *time.Time) error
+	// Delete 同时删除该提醒的触发记录
+	Delete(userID, id uint) error
+	CreateTrigger(trigger *models.AlertTrigger) error
+	// Triggers 最近的 limit 条触发记录 (新的在前)，limit <= 0 时不限；alertID 为 0 时返回全部提醒
+	Triggers(userID, alertID uint, limit int) ([]models.AlertTrigger, error)
+	DeleteByUser(userID uint) error
+}
+
+// NotifyChannelRepo 用户的站外通知渠道
+type NotifyChannelRepo interface {
+	ListByUser(userID uint) ([]models.NotifyChannel, error)
+	Find(userID, id uint) (*models.NotifyChannel, error)
+	Create(channel *models.NotifyChannel) error
+	Save(channel *models.NotifyChannel) error
+	// RecordResult 记录最近一次投递的结果，成功时 sentAt 非空、lastError 为空
+	RecordResult(id uint, sentAt *time.Time, lastError string) error
+	Delete(userID, id uint) error
+	DeleteByUser(userID uint) error
+}
+
+// Store 一组仓储
+type Store struct {
+	Users           UserRepo
+	Tokens          TokenRepo
+	Portfolios      PortfolioRepo
+	Holdings        HoldingRepo
+	Watchlist       WatchlistRepo
+	WatchlistGroups WatchlistGroupRepo
+	Transactions    TransactionRepo
+	NavHistory      NavHistoryRepo
+	NavEstimates    NavEstimateRepo
+	Snapshots       SnapshotRepo
+	Alerts          AlertRepo
+	NotifyChannels  NotifyChannelRepo
+
+	atomic func(fn func(Store) error) error
+}
+
+// Atomic 在同一个事务里执行 fn，fn 返回错误时整体回滚。
+// 没有事务支持的 Store (如内存实现) 直接执行 fn
+func (s Store) Atomic(fn func(Store) error) error {
+	if s.atomic == nil {
+		return fn(s)
+	}
+	return s.atomic(fn)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"math"
+)
+
+// 估值可信度
+const (
+	TrustReliable     = "reliable"     // 平均绝对偏差不超过 0.2%
+	TrustFair         = "fair"         // 平均绝对偏差不超过 0.5%
+	TrustUnreliable   = "unreliable"   // 偏差较大，盘中估值仅供参考
+	TrustInsufficient = "insufficient" // 样本太少，无法判断
+)
+
+// 判断可信度所需的最少样本数，以及 "命中" 的偏差范围 (%)
+const (
+	minAccuracySamples = 5
+	accuracyTolerance  = 0.1
+)
+
+// EstimateFromQuote 把 fundgz 的估值转换为 date 当天的估值记录，估值不属于 date 或价格无效时返回错误
+func EstimateFromQuote(q *models.Quote, date string) (*models.NavEstimate, error) {
+	if q.Time.IsZero() || q.Time.In(ChinaTZ).Format("2006-01-02") != date {
+		return nil, fmt.Errorf("估值时间 %s 不是 %s", q.Time.Format("2006-01-02 15:04"), date)
+	}
+	if q.Price.Sign() <= 0 {
+		return nil, fmt.Errorf("无效的估值: %s", q.Price)
+	}
+	return &models.NavEstimate{
+		FundCode:     q.FundCode,
+		Date:         date,
+		Estimate:     q.Price.Float64(),
+		EstimateRate: q.ChangeRate.Float64(),
+		EstimateTime: q.Time.In(ChinaTZ).Format("2006-01-02 15:04"),
+	}, nil
+}
+
+// ConfirmEstimate 用当天的确认净值补上估值偏差
+func ConfirmEstimate(e *models.NavEstimate, nav models.NavHistory) {
+	e.Confirmed = true
+	e.NAV = nav.NAV
+	e.ChangeRate = nav.ChangeRate
+	e.Error = nav.NAV - e.Estimate
+	if e.Estimate > 0 {
+		e.ErrorRate = e.Error / e.Estimate * 100
+	}
+}
+
+// EstimateAccuracy 一只基金盘中估值的误差统计，偏差均为相对估值的百分比
+type EstimateAccuracy struct {
+	FundCode     string  `json:"fund_code"`
+	FundName     string  `json:"fund_name"`
+	Samples      int     `json:"samples"`
+	MeanError    float64 `json:"mean_error"`     // 平均偏差，正数表示估值普遍偏低
+	MeanAbsError float64 `json:"mean_abs_error"` // 平均绝对偏差
+	RMSE         float64 `json:"rmse"`           // 均方根偏差
+	MaxAbsError  float64 `json:"max_abs_error"`
+	HitRate      float64 `json:"hit_rate"` // 偏差在 ±0.1% 以内的天数占比 (%)
+	Trust        string  `json:"trust"`
+	From         string  `json:"from"` // 样本的日期范围
+	To           string  `json:"to"`
+}
+
+// Accuracy 统计已确认的估值记录 (应属于同一只基金)
+func Accuracy(code string, rows []models.NavEstimate) EstimateAccuracy {
+	a := EstimateAccuracy{FundCode: code, Trust: TrustInsufficient}
+	var sum, sumAbs, sumSq float64
+	hits := 0
+	for _, r := range rows {
+		if !r.Confirmed {
+			continue
+		}
+		if a.Samples == 0 {
+			a.From = r.Date
+		}
+		a.To = r.Date
+		a.Samples++
+		abs := math.Abs(r.ErrorRate)
+		sum += r.ErrorRate
+		sumAbs += abs
+		sumSq += r.ErrorRate * r.ErrorRate
+		a.MaxAbsError = math.Max(a.MaxAbsError, abs)
+		if abs <= accuracyTolerance {
+			hits++
+		}
+	}
+	if a.Samples == 0 {
+		return a
+	}
+	n := float64(a.Samples)
+	a.MeanError = sum / n
+	a.MeanAbsError = sumAbs / n
+	a.RMSE = math.Sqrt(sumSq / n)
+	a.HitRate = float64(hits) / n * 100
+
+	switch {
+	case a.Samples < minAccuracySamples:
+		a.Trust = TrustInsufficient
+	case a.MeanAbsError <= 0.2:
+		a.Trust = TrustReliable
+	case a.MeanAbsError <= 0.5:
+		a.Trust = TrustFair
+	default:
+		a.Trust = TrustUnreliable
+	}
+	return a
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fund-tracker-server/internal/models"
+	"math"
+	"testing"
+	"time"
+)
+
+// confirmedRows 每个偏差一天的已确认估值记录
+func confirmedRows(rates ...float64) []models.NavEstimate {
+	rows := make([]models.NavEstimate, len(rates))
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, ChinaTZ)
+	for i, r := range rates {
+		rows[i] = models.NavEstimate{FundCode: "110022", Date: day.AddDate(0, 0, i).Format("2006-01-02"), Confirmed: true, ErrorRate: r}
+	}
+	return rows
+}
+
+func TestAccuracyStatistics(t *testing.T) {
+	rows := confirmedRows(0.05, -0.1, 0.3, -0.2, 0.15)
+	// 还没有确认净值的记录不计入
+	rows = append(rows, models.NavEstimate{FundCode: "110022", Date: "2026-10-09", ErrorRate: 5})
+
+	a := Accuracy("110022", rows)
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if a.Samples != 5 || a.From != "2026-10-01" || a.To != "2026-10-05" {
+		t.Fatalf("samples = %d (%s ~ %s)", a.Samples, a.From, a.To)
+	}
+	if !near(a.MeanError, 0.04) || !near(a.MeanAbsError, 0.16) || !near(a.MaxAbsError, 0.3) {
+		t.Fatalf("mean %v, mean abs %v, max abs %v", a.MeanError, a.MeanAbsError, a.MaxAbsError)
+	}
+	if !near(a.RMSE, math.Sqrt(0.165/5)) {
+		t.Fatalf("rmse = %v", a.RMSE)
+	}
+	// ±0.1% 以内 (含边界) 算命中：0.05 和 -0.1
+	if !near(a.HitRate, 40) {
+		t.Fatalf("hit rate = %v, want 40", a.HitRate)
+	}
+	if a.Trust != TrustReliable {
+		t.Fatalf("trust = %s", a.Trust)
+	}
+}
+
+func TestAccuracyTrust(t *testing.T) {
+	for _, tc := range []struct {
+		rates []float64
+		trust string
+	}{
+		{nil, TrustInsufficient},
+		{[]float64{0, 0, 0, 0}, TrustInsufficient}, // 少于 5 个样本
+		{[]float64{0.2, -0.2, 0.2, -0.2, 0.2}, TrustReliable},
+		{[]float64{0.21, 0.21, 0.21, 0.21, 0.21}, TrustFair},
+		{[]float64{0.5, -0.5, 0.5, -0.5, 0.5}, TrustFair},
+		{[]float64{0.51, 0.51, 0.51, 0.51, 0.51}, TrustUnreliable},
+	} {
+		if a := Accuracy("110022", confirmedRows(tc.rates...)); a.Trust != tc.trust {
+			t.Errorf("rates %v: trust %s, want %s", tc.rates, a.Trust, tc.trust)
+		}
+	}
+}
+
+func TestEstimateFromQuote(t *testing.T) {
+	at := func(s string) time.Time {
+		v, _ := time.ParseInLocation("2006-01-02 15:04", s, ChinaTZ)
+		return v
+	}
+	quote := func(price string, when time.Time) *models.Quote {
+		return &models.Quote{FundCode: "110022", Price: dec(t, price), ChangeRate: dec(t, "1.25"), Time: when}
+	}
+
+	e, err := EstimateFromQuote(quote("3.6391", at("2026-10-16 15:00")), "2026-10-16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Estimate != 3.6391 || e.EstimateRate != 1.25 || e.EstimateTime != "2026-10-16 15:00" {
+		t.Fatalf("estimate = %+v", e)
+	}
+	// 按北京时间判断日期：UTC 前一天 16:30 已是北京时间当天
+	if _, err := EstimateFromQuote(quote("3.6", time.Date(2026, 10, 15, 16, 30, 0, 0, time.UTC)), "2026-10-16"); err != nil {
+		t.Fatalf("UTC time on the same Beijing day: %v", err)
+	}
+
+	for name, q := range map[string]*models.Quote{
+		"stale (holiday)": quote("3.6", at("2026-10-15 15:00")),
+		"no time":         quote("3.6", time.Time{}),
+		"zero price":      quote("0", at("2026-10-16 15:00")),
+	} {
+		if _, err := EstimateFromQuote(q, "2026-10-16"); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"math"
+)
+
+// EvaluateAlert 用最新行情检查提醒，返回观测值和条件是否满足。
+// 行情缺少所需的数据 (如非场内基金没有溢价率、没有持仓) 时 ok 为 false。
+// holdings 只在 return_target 时使用，应为该提醒对应基金 (和组合) 的持仓
+func EvaluateAlert(a *models.Alert, q *models.Quote, holdings []models.Holding) (value float64, hit, ok bool) {
+	switch a.Kind {
+	case models.AlertPriceAbove, models.AlertPriceBelow:
+		price := q.Price.Float64()
+		if price <= 0 {
+			return 0, false, false
+		}
+		if a.Kind == models.AlertPriceAbove {
+			return price, price >= a.Threshold, true
+		}
+		return price, price <= a.Threshold, true
+
+	case models.AlertChange:
+		rate := q.ChangeRate.Float64()
+		return rate, math.Abs(rate) >= a.Threshold, true
+
+	case models.AlertPremium:
+		if q.PremiumRate == nil {
+			return 0, false, false
+		}
+		rate := q.PremiumRate.Float64()
+		return rate, math.Abs(rate) >= a.Threshold, true
+
+	case models.AlertReturnTarget:
+		var cost, unrealized float64
+		for _, h := range holdings {
+			ValueHolding(&h, q)
+			if h.TotalValue <= 0 {
+				return 0, false, false
+			}
+			cost += h.CostPrice * h.Shares
+			unrealized += h.UnrealizedReturn
+		}
+		if cost <= 0 {
+			return 0, false, false
+		}
+		rate := unrealized / cost * 100
+		if a.Threshold < 0 {
+			return rate, rate <= a.Threshold, true
+		}
+		return rate, rate >= a.Threshold, true
+	}
+	return 0, false, false
+}
+
+// AlertMessage 触发提醒时推送给用户的说明
+func AlertMessage(a *models.Alert, name string, value float64) string {
+	label := a.FundCode
+	if name != "" {
+		label = fmt.Sprintf("%s (%s)", name, a.FundCode)
+	}
+	switch a.Kind {
+	case models.AlertPriceAbove:
+		return fmt.Sprintf("%s 价格 %.4f，已涨到 %.4f 以上", label, value, a.Threshold)
+	case models.AlertPriceBelow:
+		return fmt.Sprintf("%s 价格 %.4f，已跌到 %.4f 以下", label, value, a.Threshold)
+	case models.AlertChange:
+		return fmt.Sprintf("%s 日涨跌幅 %+.2f%%，超过 ±%.2f%%", label, value, a.Threshold)
+	case models.AlertPremium:
+		return fmt.Sprintf("%s 溢价率 %+.2f%%，超出 ±%.2f%%", label, value, a.Threshold)
+	case models.AlertReturnTarget:
+		if a.Threshold < 0 {
+			return fmt.Sprintf("%s 持仓收益率 %+.2f%%，已触及止损线 %+.2f%%", label, value, a.Threshold)
+		}
+		return fmt.Sprintf("%s 持仓收益率 %+.2f%%，已达到目标 %+.2f%%", label, value, a.Threshold)
+	}
+	return label
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fund-tracker-server/internal/models"
+	"math"
+	"testing"
+)
+
+func TestEvaluateAlert(t *testing.T) {
+	premium := func(s string) *models.Decimal {
+		d := dec(t, s)
+		return &d
+	}
+	position := []models.Holding{{FundCode: "110022", Shares: 100, CostPrice: 1}}
+	for _, tc := range []struct {
+		name      string
+		kind      string
+		threshold float64
+		quote     models.Quote
+		holdings  []models.Holding
+		value     float64
+		hit, ok   bool
+	}{
+		{"price above at threshold", models.AlertPriceAbove, 1.5, models.Quote{Price: dec(t, "1.5")}, nil, 1.5, true, true},
+		{"price above just below", models.AlertPriceAbove, 1.5001, models.Quote{Price: dec(t, "1.5")}, nil, 1.5, false, true},
+		{"price below at threshold", models.AlertPriceBelow, 1.5, models.Quote{Price: dec(t, "1.5")}, nil, 1.5, true, true},
+		{"price below just above", models.AlertPriceBelow, 1.4999, models.Quote{Price: dec(t, "1.5")}, nil, 1.5, false, true},
+		{"price missing", models.AlertPriceAbove, 1, models.Quote{}, nil, 0, false, false},
+
+		{"change up at threshold", models.AlertChange, 3, models.Quote{Price: dec(t, "1"), ChangeRate: dec(t, "3")}, nil, 3, true, true},
+		{"change down beyond threshold", models.AlertChange, 3, models.Quote{Price: dec(t, "1"), ChangeRate: dec(t, "-3.5")}, nil, -3.5, true, true},
+		{"change within threshold", models.AlertChange, 3, models.Quote{Price: dec(t, "1"), ChangeRate: dec(t, "-2.99")}, nil, -2.99, false, true},
+
+		{"premium at threshold", models.AlertPremium, 2, models.Quote{Price: dec(t, "1"), PremiumRate: premium("2")}, nil, 2, true, true},
+		{"discount beyond threshold", models.AlertPremium, 2, models.Quote{Price: dec(t, "1"), PremiumRate: premium("-2.5")}, nil, -2.5, true, true},
+		{"premium within threshold", models.AlertPremium, 2, models.Quote{Price: dec(t, "1"), PremiumRate: premium("1.99")}, nil, 1.99, false, true},
+		{"premium missing (off-exchange fund)", models.AlertPremium, 2, models.Quote{Price: dec(t, "1")}, nil, 0, false, false},
+
+		{"return target reached", models.AlertReturnTarget, 20, models.Quote{Price: dec(t, "1.2")}, position, 20, true, true},
+		{"return target not reached", models.AlertReturnTarget, 20, models.Quote{Price: dec(t, "1.19")}, position, 19, false, true},
+		{"stop loss reached", models.AlertReturnTarget, -10, models.Quote{Price: dec(t, "0.9")}, position, -10, true, true},
+		{"stop loss not reached", models.AlertReturnTarget, -10, models.Quote{Price: dec(t, "0.95")}, position, -5, false, true},
+		// 多个组合的持仓合并计算：市值 264，成本 240
+		{"return across portfolios", models.AlertReturnTarget, 10, models.Quote{Price: dec(t, "1.32")}, []models.Holding{
+			{FundCode: "110022", PortfolioID: 1, Shares: 100, CostPrice: 1},
+			{FundCode: "110022", PortfolioID: 2, Shares: 100, CostPrice: 1.4},
+		}, 10, true, true},
+		{"return without holdings", models.AlertReturnTarget, 10, models.Quote{Price: dec(t, "1.2")}, nil, 0, false, false},
+		{"return without price", models.AlertReturnTarget, 10, models.Quote{}, position, 0, false, false},
+
+		{"unknown kind", "volume", 1, models.Quote{Price: dec(t, "1")}, nil, 0, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &models.Alert{FundCode: "110022", Kind: tc.kind, Threshold: tc.threshold}
+			q := tc.quote
+			value, hit, ok := EvaluateAlert(a, &q, tc.holdings)
+			if ok != tc.ok || hit != tc.hit || math.Abs(value-tc.value) > 1e-9 {
+				t.Fatalf("EvaluateAlert = (%v, %v, %v), want (%v, %v, %v)", value, hit, ok, tc.value, tc.hit, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"expvar"
+	"fund-tracker-server/internal/models"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// 行情缓存有效期：交易时段估值持续变化，收盘后只等晚间的确认净值
+var (
+	QuoteTTLTrading = 15 * time.Second
+	QuoteTTLClosed  = 10 * time.Minute
+)
+
+// 缓存命中统计，通过 /debug/vars 的 quote_cache 查看
+var cacheStats = expvar.NewMap("quote_cache")
+
+// 写入缓存时最多每隔 cacheSweepInterval 清理一次过期条目，避免不再被查询的代码一直占用内存
+const cacheSweepInterval = time.Minute
+
+type cacheEntry struct {
+	quote   *models.Quote
+	expires time.Time
+}
+
+var (
+	cacheMu    sync.RWMutex
+	quoteCache = make(map[string]cacheEntry)
+	lastSweep  time.Time // 受 cacheMu 保护
+	inflight   singleflight.Group
+)
+
+// quoteTTL 当前时刻写入的缓存有效期
+func quoteTTL(now time.Time) time.Duration {
+	if IsTradingHours(now) {
+		return QuoteTTLTrading
+	}
+	return QuoteTTLClosed
+}
+
+// cachedQuote 先查缓存，未命中时同一代码的并发请求只会触发一次上游调用。
+// 返回的是副本，调用方可以随意修改
+func cachedQuote(code string, fetch func(string) (*models.Quote, error)) (*models.Quote, error) {
+	cacheMu.RLock()
+	entry, ok := quoteCache[code]
+	cacheMu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		cacheStats.Add("hits", 1)
+		return copyQuote(entry.quote), nil
+	}
+
+	cacheStats.Add("misses", 1)
+	v, err, shared := inflight.Do(code, func() (interface{}, error) {
+		cacheStats.Add("upstream_fetches", 1)
+		quote, err := fetch(code)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		cacheMu.Lock()
+		sweepCache(now)
+		quoteCache[code] = cacheEntry{quote: quote, expires: now.Add(quoteTTL(now))}
+		cacheMu.Unlock()
+		return quote, nil
+	})
+	if shared {
+		cacheStats.Add("coalesced", 1)
+	}
+	if err != nil {
+		cacheStats.Add("errors", 1)
+		return nil, err
+	}
+	return copyQuote(v.(*models.Quote)), nil
+}
+
+// sweepCache 删除已过期的条目，调用方需持有 cacheMu 写锁
+func sweepCache(now time.Time) {
+	if now.Sub(lastSweep) < cacheSweepInterval {
+		return
+	}
+	lastSweep = now
+	for code, entry := range quoteCache {
+		if !now.Before(entry.expires) {
+			delete(quoteCache, code)
+			cacheStats.Add("evictions", 1)
+		}
+	}
+}
+
+// InvalidateQuote 删除某只基金的缓存，确认净值入库后调用，下次查询不再返回缓存中的估值
+func InvalidateQuote(code string) {
+	cacheMu.Lock()
+	delete(quoteCache, code)
+	cacheMu.Unlock()
+}
+
+func copyQuote(q *models.Quote) *models.Quote {
+	cp := *q
+	if q.PremiumRate != nil {
+		premium := *q.PremiumRate
+		cp.PremiumRate = &premium
+	}
+	return &cp
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fund-tracker-server/internal/models"
+	"testing"
+	"time"
+)
+
+func TestCacheSweepsExpiredEntries(t *testing.T) {
+	now := time.Now()
+	cacheMu.Lock()
+	quoteCache = map[string]cacheEntry{
+		"000001": {quote: &models.Quote{FundCode: "000001"}, expires: now.Add(-time.Second)},
+		"000002": {quote: &models.Quote{FundCode: "000002"}, expires: now.Add(time.Hour)},
+	}
+	lastSweep = time.Time{}
+	cacheMu.Unlock()
+	t.Cleanup(func() {
+		cacheMu.Lock()
+		quoteCache = make(map[string]cacheEntry)
+		cacheMu.Unlock()
+	})
+
+	fetch := func(code string) (*models.Quote, error) { return &models.Quote{FundCode: code}, nil }
+	if _, err := cachedQuote("000003", fetch); err != nil {
+		t.Fatal(err)
+	}
+	cacheMu.RLock()
+	_, expired := quoteCache["000001"]
+	_, fresh := quoteCache["000002"]
+	n := len(quoteCache)
+	cacheMu.RUnlock()
+	if expired || !fresh || n != 2 {
+		t.Fatalf("after sweep: expired kept=%v fresh kept=%v size=%d", expired, fresh, n)
+	}
+
+	InvalidateQuote("000002")
+	cacheMu.RLock()
+	_, fresh = quoteCache["000002"]
+	cacheMu.RUnlock()
+	if fresh {
+		t.Fatal("InvalidateQuote should drop the entry")
+	}
+}
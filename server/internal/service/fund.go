@@ -9,82 +9,76 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-// FetchFundData 智能混合模式
-func FetchFundData(code string) (*models.FundInfo, error) {
-	var finalFund *models.FundInfo
+// FetchQuote 获取基金行情，同一代码在缓存有效期内共享结果
+func FetchQuote(code string) (*models.Quote, error) {
+	return cachedQuote(code, fetchMergedQuote)
+}
 
-	if isExchangeTraded(code) {
-		marketFund, err := fetchMarketData(code)
-		if err == nil && marketFund != nil {
-			finalFund = marketFund
-			finalFund.GZTime += " (实时)"
+// FetchEstimate 直接取盘中估值，不经过缓存也不与确认净值合并。
+// 收盘后 fundgz 仍返回当天最后一次估值，可用来和晚间公布的确认净值对比
+func FetchEstimate(code string) (*models.Quote, error) {
+	return fetchKind(KindEstimate, code)
+}
 
-			gzFund, _ := fetchEstimateData(code)
-			if gzFund == nil {
-				gzFund, _ = fetchFinalData(code)
-			}
+// fetchMergedQuote 智能混合模式：按数据源优先级分别取实时价、估值和确认净值后合并
+func fetchMergedQuote(code string) (*models.Quote, error) {
+	var final *models.Quote
 
-			if gzFund != nil && gzFund.GSZ != "" {
-				price, _ := strconv.ParseFloat(finalFund.GSZ, 64)
-				nav, _ := strconv.ParseFloat(gzFund.GSZ, 64)
-				if nav > 0 {
-					rate := (price - nav) / nav * 100
-					finalFund.PremiumRate = fmt.Sprintf("%+.2f%%", rate)
-				}
-			}
+	// 场内基金优先使用实时成交价 (只有支持该代码的数据源会被尝试)
+	if market, err := fetchKind(KindRealtime, code); err == nil {
+		final = market
+
+		nav, _ := fetchKind(KindEstimate, code)
+		if nav == nil {
+			nav, _ = fetchKind(KindConfirmed, code)
+		}
+		if nav != nil && nav.Price.Sign() > 0 {
+			price, base := final.Price.Float64(), nav.Price.Float64()
+			premium := models.NewDecimal((price-base)/base*100, 2)
+			final.PremiumRate = &premium
 		}
 	}
 
-	if finalFund == nil {
-		gzFund, err := fetchEstimateData(code)
-		if err == nil && gzFund != nil {
-			finalFund = gzFund
+	if final == nil {
+		if estimate, err := fetchKind(KindEstimate, code); err == nil {
+			final = estimate
 		}
 	}
 
-	f10Fund, err := fetchFinalData(code)
+	f10, err := fetchKind(KindConfirmed, code)
 
-	if finalFund == nil {
-		if f10Fund != nil {
-			f10Fund.GZTime += " (确)"
-			return f10Fund, nil
+	if final == nil {
+		if f10 != nil {
+			return f10, nil
 		}
 		return nil, fmt.Errorf("无数据: %v", err)
 	}
 
-	if f10Fund != nil {
-		gzDateStr := strings.Split(finalFund.GZTime, " ")[0]
-		if f10Fund.GZTime >= gzDateStr && !strings.Contains(finalFund.GZTime, "实时") {
-			finalFund.GSZ = f10Fund.GSZ
-			finalFund.GSZZL = f10Fund.GSZZL
-			finalFund.GZTime = f10Fund.GZTime + " (确)"
-		} else if !strings.Contains(finalFund.GZTime, "实时") {
-			finalFund.GZTime += " (估)"
-		}
-	} else if !strings.Contains(finalFund.GZTime, "实时") {
-		finalFund.GZTime += " (估)"
+	// 估值当天 (或之后) 的净值已经公布时以确认净值为准，场内实时价不替换
+	if f10 != nil && final.Status != models.PriceRealtime && !f10.Time.Before(startOfDay(final.Time)) {
+		final.Price = f10.Price
+		final.ChangeRate = f10.ChangeRate
+		final.Time = f10.Time
+		final.Source = f10.Source
+		final.Status = models.PriceConfirmed
 	}
 
-	if isQDII(finalFund.Name) {
-		status := getUSMarketStatus()
-		if status != "" {
-			finalFund.GZTime += " " + status
-		}
+	if isQDII(final.Name) {
+		final.MarketStatus = usMarketStatus(time.Now())
 	}
 
-	return finalFund, nil
+	return final, nil
 }
 
 // SearchFund 模糊搜索
 func SearchFund(keyword string) ([]models.FundSearchResult, error) {
-	api := fmt.Sprintf("http://fundsuggest.eastmoney.com/FundSearch/api/FundSearchAPI.ashx?m=1&key=%s", url.QueryEscape(keyword))
+	api := fmt.Sprintf("%s/FundSearch/api/FundSearchAPI.ashx?m=1&key=%s", CurrentUpstream().Search, url.QueryEscape(keyword))
 	body, err := httpGet(api)
 	if err != nil {
 		return nil, err
@@ -114,7 +108,7 @@ func SearchFund(keyword string) ([]models.FundSearchResult, error) {
 
 // FetchFundDetail 基金详情
 func FetchFundDetail(code string) (*models.FundDetail, error) {
-	urlBase := fmt.Sprintf("https://fundmobapi.eastmoney.com/FundMNewApi/FundMNBasicInformation?FCODE=%s&deviceid=123&plat=Iphone&product=EFund&version=6.0.0", code)
+	urlBase := fmt.Sprintf("%s/FundMNewApi/FundMNBasicInformation?FCODE=%s&deviceid=123&plat=Iphone&product=EFund&version=6.0.0", CurrentUpstream().FundMob, code)
 	body, err := httpGet(urlBase)
 	if err != nil {
 		return nil, err
@@ -151,7 +145,7 @@ func FetchFundDetail(code string) (*models.FundDetail, error) {
 	}
 
 	if len(secids) > 0 {
-		api := fmt.Sprintf("http://push2.eastmoney.com/api/qt/ulist.np/get?secids=%s&fields=f12,f14,f2,f3", strings.Join(secids, ","))
+		api := fmt.Sprintf("%s/api/qt/ulist.np/get?secids=%s&fields=f12,f14,f2,f3", CurrentUpstream().Push2, strings.Join(secids, ","))
 		body, err := httpGet(api)
 		if err == nil {
 			var stockRes struct {
@@ -222,26 +216,51 @@ func isQDII(name string) bool {
 	return false
 }
 
-func getUSMarketStatus() string {
-	now := time.Now()
+// ChinaTZ 北京时间，系统缺少时区数据时使用固定 +8
+var ChinaTZ = func() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST", 8*3600)
+}()
+
+// IsTradingHours A 股交易时段 (工作日 9:30-11:30, 13:00-15:00)，不考虑节假日
+func IsTradingHours(t time.Time) bool {
+	t = t.In(ChinaTZ)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	minutes := t.Hour()*60 + t.Minute()
+	return (minutes >= 9*60+30 && minutes < 11*60+30) || (minutes >= 13*60 && minutes < 15*60)
+}
+
+// startOfDay t 当天零点 (北京时间)
+func startOfDay(t time.Time) time.Time {
+	t = t.In(ChinaTZ)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, ChinaTZ)
+}
+
+// usMarketStatus 美股时段 (北京时间 21:30-04:00) 内的交易状态，其他时间为空
+func usMarketStatus(now time.Time) models.MarketStatus {
+	now = now.In(ChinaTZ)
 	hour := now.Hour()
 	minute := now.Minute()
 	isTrading := (hour == 21 && minute >= 30) || (hour > 21) || (hour < 4)
 	if isTrading {
 		if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-			return "[美股休市]"
+			return models.MarketUSClosed
 		}
-		return "[美股交易中]"
+		return models.MarketUSTrading
 	}
 	return ""
 }
 
-func fetchMarketData(code string) (*models.FundInfo, error) {
+func fetchMarketData(code string) (*models.Quote, error) {
 	market := "0"
 	if strings.HasPrefix(code, "5") || strings.HasPrefix(code, "6") {
 		market = "1"
 	}
-	url := fmt.Sprintf("http://push2.eastmoney.com/api/qt/stock/get?secid=%s.%s&fields=f43,f57,f58,f169,f170,f46,f60", market, code)
+	url := fmt.Sprintf("%s/api/qt/stock/get?secid=%s.%s&fields=f43,f57,f58,f169,f170,f46,f60", CurrentUpstream().Push2, market, code)
 	body, err := httpGet(url)
 	if err != nil {
 		return nil, err
@@ -265,17 +284,17 @@ func fetchMarketData(code string) (*models.FundInfo, error) {
 	if price <= 0.0001 {
 		return nil, fmt.Errorf("price is zero")
 	}
-	return &models.FundInfo{
-		FundCode: code,
-		Name:     result.Data.F58,
-		GSZ:      fmt.Sprintf("%.3f", price),
-		GSZZL:    fmt.Sprintf("%.2f", result.Data.F170),
-		GZTime:   time.Now().Format("2006-01-02 15:04"),
+	return &models.Quote{
+		FundCode:   code,
+		Name:       result.Data.F58,
+		Price:      models.NewDecimal(price, 3),
+		ChangeRate: models.NewDecimal(result.Data.F170, 2),
+		Time:       time.Now().In(ChinaTZ).Truncate(time.Minute),
 	}, nil
 }
 
-func fetchEstimateData(code string) (*models.FundInfo, error) {
-	url := fmt.Sprintf("http://fundgz.1234567.com.cn/js/%s.js?rt=%d", code, time.Now().Unix())
+func fetchEstimateData(code string) (*models.Quote, error) {
+	url := fmt.Sprintf("%s/js/%s.js?rt=%d", CurrentUpstream().FundGZ, code, time.Now().Unix())
 	body, err := httpGet(url)
 	if err != nil {
 		return nil, err
@@ -284,13 +303,32 @@ func fetchEstimateData(code string) (*models.FundInfo, error) {
 	if jsonString == "" {
 		return nil, fmt.Errorf("empty jsonp")
 	}
-	var fund models.FundInfo
+	var fund struct {
+		FundCode string `json:"fundcode"`
+		Name     string `json:"name"`
+		GSZ      string `json:"gsz"`
+		GSZZL    string `json:"gszzl"`
+		GZTime   string `json:"gztime"`
+	}
 	json.Unmarshal([]byte(jsonString), &fund)
-	return &fund, nil
+	price, err := models.ParseDecimal(fund.GSZ)
+	if err != nil {
+		return nil, err
+	}
+	rate, _ := models.ParseDecimal(fund.GSZZL)
+	// 时间格式不对时留空，合并时视为已被确认净值覆盖
+	t, _ := time.ParseInLocation("2006-01-02 15:04", fund.GZTime, ChinaTZ)
+	return &models.Quote{
+		FundCode:   fund.FundCode,
+		Name:       fund.Name,
+		Price:      price,
+		ChangeRate: rate,
+		Time:       t,
+	}, nil
 }
 
-func fetchFinalData(code string) (*models.FundInfo, error) {
-	url := fmt.Sprintf("http://fund.eastmoney.com/f10/F10DataApi.aspx?type=lsjz&code=%s&page=1&per=1", code)
+func fetchFinalData(code string) (*models.Quote, error) {
+	url := fmt.Sprintf("%s/f10/F10DataApi.aspx?type=lsjz&code=%s&page=1&per=1", CurrentUpstream().F10, code)
 	body, err := httpGet(url)
 	if err != nil {
 		return nil, err
@@ -307,17 +345,30 @@ func fetchFinalData(code string) (*models.FundInfo, error) {
 	if tds.Length() < 4 {
 		return nil, fmt.Errorf("table error")
 	}
-	return &models.FundInfo{
-		GZTime: tds.Eq(0).Text(),
-		GSZ:    tds.Eq(1).Text(),
-		GSZZL:  strings.ReplaceAll(tds.Eq(3).Text(), "%", ""),
+	date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(tds.Eq(0).Text()), ChinaTZ)
+	if err != nil {
+		return nil, fmt.Errorf("净值日期格式错误: %v", err)
+	}
+	nav, err := models.ParseDecimal(tds.Eq(1).Text())
+	if err != nil {
+		return nil, err
+	}
+	// 货币基金等没有日涨跌幅 (显示为 "--")，记为 0
+	rate, _ := models.ParseDecimal(strings.ReplaceAll(tds.Eq(3).Text(), "%", ""))
+	return &models.Quote{
+		FundCode:   code,
+		Price:      nav,
+		ChangeRate: rate,
+		Time:       date,
 	}, nil
 }
 
-// 通用 HTTP GET 请求 (🔥 优化：5秒超时)
+// HTTPTimeout 上游单次请求超时，启动时由配置覆盖
+var HTTPTimeout = 5 * time.Second
+
+// 通用 HTTP GET 请求 (🔥 优化：超时可配置，默认 5 秒)
 func httpGet(url string) ([]byte, error) {
-	// 🔥 Timeout 设置为 5 秒
-	client := http.Client{Timeout: 5 * time.Second}
+	client := http.Client{Timeout: HTTPTimeout}
 	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 	req.Header.Set("Referer", "http://fund.eastmoney.com/")
@@ -326,5 +377,8 @@ func httpGet(url string) ([]byte, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("上游返回 %s", resp.Status)
+	}
 	return io.ReadAll(resp.Body)
 }
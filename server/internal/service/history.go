@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// NavHistoryPageSize F10 接口单页最多返回的行数
+const NavHistoryPageSize = 40
+
+var apidataPagesRe = regexp.MustCompile(`pages:(\d+)`)
+
+// FetchNavHistoryPage 抓取 F10 历史净值的一页 (按日期倒序)，
+// sdate / edate 非空时只取该日期及之后 / 之前的数据。返回本页数据和总页数
+func FetchNavHistoryPage(code string, page int, sdate, edate string) ([]models.NavHistory, int, error) {
+	api := fmt.Sprintf("%s/f10/F10DataApi.aspx?type=lsjz&code=%s&page=%d&per=%d&sdate=%s&edate=%s",
+		CurrentUpstream().F10, url.QueryEscape(code), page, NavHistoryPageSize, url.QueryEscape(sdate), url.QueryEscape(edate))
+	body, err := httpGet(api)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pages := 0
+	if m := apidataPagesRe.FindSubmatch(body); m != nil {
+		pages, _ = strconv.Atoi(string(m[1]))
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	var rows []models.NavHistory
+	doc.Find("tbody tr").Each(func(_ int, tr *goquery.Selection) {
+		tds := tr.Find("td")
+		// "暂无数据" 只有一个合并单元格
+		if tds.Length() < 4 {
+			return
+		}
+		nav, err := strconv.ParseFloat(strings.TrimSpace(tds.Eq(1).Text()), 64)
+		if err != nil {
+			return
+		}
+		accNav, _ := strconv.ParseFloat(strings.TrimSpace(tds.Eq(2).Text()), 64)
+		rate, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(tds.Eq(3).Text()), "%"), 64)
+		rows = append(rows, models.NavHistory{
+			FundCode:   code,
+			Date:       strings.TrimSpace(tds.Eq(0).Text()),
+			NAV:        nav,
+			AccNAV:     accNav,
+			ChangeRate: rate,
+		})
+	})
+	return rows, pages, nil
+}
+
+// HistoryQuote 把数据库中的历史净值转换为确认净值行情 (name 为基金名称，历史净值中没有)
+func HistoryQuote(n models.NavHistory, name string) *models.Quote {
+	date, _ := time.ParseInLocation("2006-01-02", n.Date, ChinaTZ)
+	return &models.Quote{
+		FundCode:   n.FundCode,
+		Name:       name,
+		Price:      models.NewDecimal(n.NAV, 4),
+		ChangeRate: models.NewDecimal(n.ChangeRate, 2),
+		Time:       date,
+		Source:     models.SourceEastmoneyF10,
+		Status:     models.PriceConfirmed,
+	}
+}
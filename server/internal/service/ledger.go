@@ -0,0 +1,208 @@
+package service
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"sort"
+	"time"
+)
+
+// 份额比较容差，避免浮点误差导致 "卖出超过持有"
+const shareEpsilon = 1e-6
+
+// CostMethod 卖出时结转成本的方式
+type CostMethod string
+
+const (
+	CostAverage CostMethod = "average" // 移动加权平均
+	CostFIFO    CostMethod = "fifo"    // 先进先出
+)
+
+// ParseCostMethod 解析成本方法，空字符串视为平均成本
+func ParseCostMethod(s string) (CostMethod, error) {
+	switch CostMethod(s) {
+	case "", CostAverage:
+		return CostAverage, nil
+	case CostFIFO:
+		return CostFIFO, nil
+	}
+	return "", fmt.Errorf("不支持的成本方法: %s", s)
+}
+
+// Lot FIFO 批次
+type Lot struct {
+	TradeDate time.Time `json:"trade_date"`
+	Shares    float64   `json:"shares"`
+	Cost      float64   `json:"cost"` // 批次剩余份额的总成本
+}
+
+// Position 由交易流水重放得到的持仓
+type Position struct {
+	Shares    float64 `json:"shares"`
+	CostBasis float64 `json:"cost_basis"` // 剩余份额的总成本
+	Realized  float64 `json:"realized"`   // 已实现收益 (卖出差价 + 现金分红 - 费用)
+	Lots      []Lot   `json:"lots,omitempty"`
+
+	method CostMethod
+}
+
+// CostPrice 平均成本单价
+func (p Position) CostPrice() float64 {
+	if p.Shares <= shareEpsilon {
+		return 0
+	}
+	return p.CostBasis / p.Shares
+}
+
+// ValidateTransaction 校验单条流水的字段组合
+func ValidateTransaction(tx *models.Transaction) error {
+	if tx.FundCode == "" {
+		return fmt.Errorf("缺少基金代码")
+	}
+	if tx.TradeDate.IsZero() {
+		return fmt.Errorf("缺少交易日期")
+	}
+	if tx.Fee < 0 || tx.Amount < 0 {
+		return fmt.Errorf("费用和金额不能为负")
+	}
+	switch tx.Type {
+	case models.TxBuy, models.TxSell:
+		if tx.Shares <= 0 || tx.Price <= 0 {
+			return fmt.Errorf("份额和价格必须大于 0")
+		}
+	case models.TxDividend:
+		if tx.Shares < 0 || (tx.Shares == 0 && tx.Amount == 0) {
+			return fmt.Errorf("分红需要现金金额或再投份额")
+		}
+	case models.TxFee:
+		if tx.Amount <= 0 {
+			return fmt.Errorf("费用金额必须大于 0")
+		}
+	case models.TxSplit:
+		if tx.Shares == 0 {
+			return fmt.Errorf("折算份额变动不能为 0")
+		}
+	case models.TxTransfer:
+		if tx.Shares == 0 || tx.Price < 0 {
+			return fmt.Errorf("转入转出份额不能为 0")
+		}
+	default:
+		return fmt.Errorf("未知交易类型: %s", tx.Type)
+	}
+	return nil
+}
+
+// SortTransactions 按交易日期 (同日按录入顺序) 排序
+func SortTransactions(txs []models.Transaction) {
+	sort.SliceStable(txs, func(i, j int) bool {
+		if !txs[i].TradeDate.Equal(txs[j].TradeDate) {
+			return txs[i].TradeDate.Before(txs[j].TradeDate)
+		}
+		return txs[i].ID < txs[j].ID
+	})
+}
+
+// ProjectPosition 按时间顺序重放流水，份额变为负数时返回错误
+func ProjectPosition(txs []models.Transaction, method CostMethod) (Position, error) {
+	sorted := append([]models.Transaction(nil), txs...)
+	SortTransactions(sorted)
+
+	p := Position{method: method}
+	for _, tx := range sorted {
+		date := tx.TradeDate.In(ChinaTZ).Format("2006-01-02")
+		switch tx.Type {
+		case models.TxBuy:
+			p.add(tx.TradeDate, tx.Shares, tx.Shares*tx.Price+tx.Fee)
+		case models.TxSell:
+			if tx.Shares > p.Shares+shareEpsilon {
+				return p, fmt.Errorf("%s 卖出 %.2f 份超过持有 %.2f 份", date, tx.Shares, p.Shares)
+			}
+			cost := p.remove(tx.Shares)
+			p.Realized += tx.Shares*tx.Price - tx.Fee - cost
+		case models.TxDividend:
+			// 现金分红计入已实现收益；红利再投份额增加，总成本不变
+			p.Realized += tx.Amount
+			if tx.Shares > 0 {
+				p.add(tx.TradeDate, tx.Shares, 0)
+			}
+		case models.TxFee:
+			if p.Shares > shareEpsilon {
+				p.addCost(tx.Amount)
+			} else {
+				p.Realized -= tx.Amount
+			}
+		case models.TxSplit:
+			if p.Shares <= shareEpsilon || p.Shares+tx.Shares < -shareEpsilon {
+				return p, fmt.Errorf("%s 折算前后份额必须为正", date)
+			}
+			p.scale((p.Shares + tx.Shares) / p.Shares)
+		case models.TxTransfer:
+			if tx.Shares > 0 {
+				p.add(tx.TradeDate, tx.Shares, tx.Shares*tx.Price+tx.Fee)
+			} else {
+				out := -tx.Shares
+				if out > p.Shares+shareEpsilon {
+					return p, fmt.Errorf("%s 转出 %.2f 份超过持有 %.2f 份", date, out, p.Shares)
+				}
+				p.remove(out)
+				p.Realized -= tx.Fee
+			}
+		}
+		if p.Shares < shareEpsilon {
+			p.Shares, p.CostBasis, p.Lots = 0, 0, nil
+		}
+	}
+	return p, nil
+}
+
+// add 增加份额及其成本
+func (p *Position) add(date time.Time, shares, cost float64) {
+	p.Shares += shares
+	p.CostBasis += cost
+	if p.method == CostFIFO {
+		p.Lots = append(p.Lots, Lot{TradeDate: date, Shares: shares, Cost: cost})
+	}
+}
+
+// remove 减少份额，返回结转的成本
+func (p *Position) remove(shares float64) float64 {
+	var cost float64
+	if p.method == CostFIFO {
+		left := shares
+		for len(p.Lots) > 0 && left > shareEpsilon {
+			lot := &p.Lots[0]
+			if lot.Shares <= left+shareEpsilon {
+				cost += lot.Cost
+				left -= lot.Shares
+				p.Lots = p.Lots[1:]
+				continue
+			}
+			part := lot.Cost / lot.Shares * left
+			cost += part
+			lot.Cost -= part
+			lot.Shares -= left
+			left = 0
+		}
+	} else {
+		cost = p.CostPrice() * shares
+	}
+	p.Shares -= shares
+	p.CostBasis -= cost
+	return cost
+}
+
+// addCost 成本按份额比例分摊到各批次
+func (p *Position) addCost(amount float64) {
+	for i := range p.Lots {
+		p.Lots[i].Cost += amount * p.Lots[i].Shares / p.Shares
+	}
+	p.CostBasis += amount
+}
+
+// scale 份额按比例折算，总成本不变
+func (p *Position) scale(ratio float64) {
+	for i := range p.Lots {
+		p.Lots[i].Shares *= ratio
+	}
+	p.Shares *= ratio
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"sync"
+)
+
+// QuoteKind 数据源提供的行情类型，FetchQuote 按类型合并
+type QuoteKind int
+
+const (
+	KindRealtime  QuoteKind = iota // 场内实时成交价
+	KindEstimate                   // 盘中估值
+	KindConfirmed                  // 已确认净值
+)
+
+// QuoteProvider 行情数据源。Fetch 返回的 Quote 未设置 Source 和 Status 时，
+// 分别按数据源名称和类型补上
+type QuoteProvider interface {
+	Name() string
+	Kind() QuoteKind
+	Supports(code string) bool
+	Fetch(code string) (*models.Quote, error)
+}
+
+// 各类型数据源给出的价格性质
+var kindStatus = map[QuoteKind]models.PriceStatus{
+	KindRealtime:  models.PriceRealtime,
+	KindEstimate:  models.PriceEstimated,
+	KindConfirmed: models.PriceConfirmed,
+}
+
+var (
+	providerMu    sync.RWMutex
+	providers     = make(map[string]QuoteProvider)
+	providerChain []string
+)
+
+func init() {
+	RegisterProvider(marketProvider{})
+	RegisterProvider(estimateProvider{})
+	RegisterProvider(finalProvider{})
+	providerChain = []string{
+		string(models.SourceEastmoneyMarket),
+		string(models.SourceFundGZ),
+		string(models.SourceEastmoneyF10),
+	}
+}
+
+// RegisterProvider 注册数据源，同名会覆盖 (测试可借此注入假数据源)
+func RegisterProvider(p QuoteProvider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// SetProviderChain 设置数据源优先级，同类型的数据源按顺序尝试
+func SetProviderChain(names ...string) error {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if err := checkChain(names); err != nil {
+		return err
+	}
+	providerChain = append([]string(nil), names...)
+	return nil
+}
+
+// CheckProviderChain 校验数据源优先级 (不能为空、不能重复、必须已注册)，不修改当前设置
+func CheckProviderChain(names []string) error {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+	return checkChain(names)
+}
+
+// checkChain 调用方需持有 providerMu
+func checkChain(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("数据源列表不能为空")
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := providers[name]; !ok {
+			return fmt.Errorf("未注册的数据源: %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("数据源重复: %s", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// ProviderChain 返回当前数据源优先级
+func ProviderChain() []string {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+	return append([]string(nil), providerChain...)
+}
+
+// fetchKind 按优先级依次尝试指定类型的数据源，返回第一个成功的结果
+func fetchKind(kind QuoteKind, code string) (*models.Quote, error) {
+	providerMu.RLock()
+	var candidates []QuoteProvider
+	for _, name := range providerChain {
+		if p, ok := providers[name]; ok && p.Kind() == kind && p.Supports(code) {
+			candidates = append(candidates, p)
+		}
+	}
+	providerMu.RUnlock()
+
+	err := fmt.Errorf("没有可用的数据源")
+	for _, p := range candidates {
+		data, e := p.Fetch(code)
+		if e == nil && data != nil {
+			if data.Source == "" {
+				data.Source = models.QuoteSource(p.Name())
+			}
+			if data.Status == "" {
+				data.Status = kindStatus[kind]
+			}
+			return data, nil
+		}
+		if e != nil {
+			err = fmt.Errorf("%s: %v", p.Name(), e)
+		}
+	}
+	return nil, err
+}
+
+// ---------------- 内置 Eastmoney 数据源 ----------------
+
+// marketProvider 场内基金实时成交价 (push2)
+type marketProvider struct{}
+
+func (marketProvider) Name() string                             { return string(models.SourceEastmoneyMarket) }
+func (marketProvider) Kind() QuoteKind                          { return KindRealtime }
+func (marketProvider) Supports(code string) bool                { return isExchangeTraded(code) }
+func (marketProvider) Fetch(code string) (*models.Quote, error) { return fetchMarketData(code) }
+
+// estimateProvider 天天基金盘中估值 (fundgz)
+type estimateProvider struct{}
+
+func (estimateProvider) Name() string                             { return string(models.SourceFundGZ) }
+func (estimateProvider) Kind() QuoteKind                          { return KindEstimate }
+func (estimateProvider) Supports(code string) bool                { return true }
+func (estimateProvider) Fetch(code string) (*models.Quote, error) { return fetchEstimateData(code) }
+
+// finalProvider F10 历史净值首行，即最新确认净值
+type finalProvider struct{}
+
+func (finalProvider) Name() string                             { return string(models.SourceEastmoneyF10) }
+func (finalProvider) Kind() QuoteKind                          { return KindConfirmed }
+func (finalProvider) Supports(code string) bool                { return true }
+func (finalProvider) Fetch(code string) (*models.Quote, error) { return fetchFinalData(code) }
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"fund-tracker-server/internal/models"
+	"testing"
+	"time"
+)
+
+// fakeProvider 返回固定行情的数据源，quote 为 nil 时返回错误
+type fakeProvider struct {
+	name     string
+	kind     QuoteKind
+	quote    *models.Quote
+	supports func(code string) bool
+}
+
+func (p fakeProvider) Name() string    { return p.name }
+func (p fakeProvider) Kind() QuoteKind { return p.kind }
+
+func (p fakeProvider) Supports(code string) bool {
+	return p.supports == nil || p.supports(code)
+}
+
+func (p fakeProvider) Fetch(code string) (*models.Quote, error) {
+	if p.quote == nil {
+		return nil, errors.New("upstream down")
+	}
+	q := *p.quote
+	q.FundCode = code
+	return &q, nil
+}
+
+// useProviders 注册假数据源并设置优先级，测试结束后恢复原来的优先级
+func useProviders(t *testing.T, chain []string, ps ...QuoteProvider) {
+	t.Helper()
+	prev := ProviderChain()
+	for _, p := range ps {
+		RegisterProvider(p)
+	}
+	if err := SetProviderChain(chain...); err != nil {
+		t.Fatalf("SetProviderChain: %v", err)
+	}
+	t.Cleanup(func() { SetProviderChain(prev...) })
+}
+
+func dec(t *testing.T, s string) models.Decimal {
+	t.Helper()
+	d, err := models.ParseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func at(day, clock string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, ChinaTZ)
+	return t
+}
+
+func TestProviderChainOrder(t *testing.T) {
+	a := fakeProvider{name: "test_est_a", kind: KindEstimate,
+		quote: &models.Quote{Name: "A", Price: dec(t, "1.1000"), Time: at("2026-10-16", "14:30")}}
+	b := fakeProvider{name: "test_est_b", kind: KindEstimate,
+		quote: &models.Quote{Name: "B", Price: dec(t, "2.2000"), Time: at("2026-10-16", "14:30")}}
+	down := fakeProvider{name: "test_est_down", kind: KindEstimate}
+
+	useProviders(t, []string{"test_est_down", "test_est_a", "test_est_b"}, a, b, down)
+	q, err := fetchMergedQuote("000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 失败的数据源被跳过，其后第一个成功的生效
+	if q.Source != "test_est_a" || q.Price.String() != "1.1000" || q.Status != models.PriceEstimated {
+		t.Fatalf("got source=%s price=%s status=%s, want test_est_a 1.1000 estimated", q.Source, q.Price, q.Status)
+	}
+
+	useProviders(t, []string{"test_est_b", "test_est_a"})
+	q, err = fetchMergedQuote("000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Source != "test_est_b" || q.Name != "B" {
+		t.Fatalf("got source=%s name=%s, want test_est_b B", q.Source, q.Name)
+	}
+}
+
+func TestMergeConfirmedReplacesEstimate(t *testing.T) {
+	est := fakeProvider{name: "test_est", kind: KindEstimate,
+		quote: &models.Quote{Name: "消费", Price: dec(t, "3.6355"), ChangeRate: dec(t, "0.65"), Time: at("2026-10-16", "15:00")}}
+	sameDay := fakeProvider{name: "test_conf_today", kind: KindConfirmed,
+		quote: &models.Quote{Price: dec(t, "3.6391"), ChangeRate: dec(t, "-0.70"), Time: at("2026-10-16", "00:00")}}
+	dayBefore := fakeProvider{name: "test_conf_old", kind: KindConfirmed,
+		quote: &models.Quote{Price: dec(t, "3.6610"), ChangeRate: dec(t, "0.12"), Time: at("2026-10-15", "00:00")}}
+
+	useProviders(t, []string{"test_est", "test_conf_today"}, est, sameDay, dayBefore)
+	q, err := fetchMergedQuote("110022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Status != models.PriceConfirmed || q.Price.String() != "3.6391" || q.Source != "test_conf_today" || q.Name != "消费" {
+		t.Fatalf("got %+v, want confirmed 3.6391 from test_conf_today keeping the name", q)
+	}
+	if got := q.FundInfo().GZTime; got != "2026-10-16 (确)" {
+		t.Fatalf("legacy gztime = %q", got)
+	}
+
+	// 确认净值早于估值日期时保留估值
+	useProviders(t, []string{"test_est", "test_conf_old"})
+	q, err = fetchMergedQuote("110022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Status != models.PriceEstimated || q.Price.String() != "3.6355" {
+		t.Fatalf("got %s %s, want estimated 3.6355", q.Status, q.Price)
+	}
+	if got := q.FundInfo().GZTime; got != "2026-10-16 15:00 (估)" {
+		t.Fatalf("legacy gztime = %q", got)
+	}
+
+	// 只有确认净值时直接返回
+	useProviders(t, []string{"test_conf_old"})
+	q, err = fetchMergedQuote("110022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Status != models.PriceConfirmed || q.Price.String() != "3.6610" {
+		t.Fatalf("got %s %s, want confirmed 3.6610", q.Status, q.Price)
+	}
+}
+
+func TestMergeRealtimeWithPremium(t *testing.T) {
+	market := fakeProvider{name: "test_market", kind: KindRealtime,
+		supports: func(code string) bool { return code == "510300" },
+		quote:    &models.Quote{Name: "沪深300ETF", Price: dec(t, "4.012"), ChangeRate: dec(t, "-0.27"), Time: at("2026-10-16", "14:59")}}
+	est := fakeProvider{name: "test_est_nav", kind: KindEstimate,
+		quote: &models.Quote{Price: dec(t, "4.0000"), Time: at("2026-10-16", "15:00")}}
+	conf := fakeProvider{name: "test_conf_nav", kind: KindConfirmed,
+		quote: &models.Quote{Price: dec(t, "4.0097"), Time: at("2026-10-16", "00:00")}}
+
+	useProviders(t, []string{"test_market", "test_est_nav", "test_conf_nav"}, market, est, conf)
+	q, err := fetchMergedQuote("510300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 实时价不被确认净值替换，溢价率相对估值计算
+	if q.Status != models.PriceRealtime || q.Price.String() != "4.012" || q.Source != "test_market" {
+		t.Fatalf("got %s %s from %s, want realtime 4.012 from test_market", q.Status, q.Price, q.Source)
+	}
+	if q.PremiumRate == nil || q.PremiumRate.String() != "0.30" {
+		t.Fatalf("premium = %v, want 0.30", q.PremiumRate)
+	}
+	if got := q.FundInfo().PremiumRate; got != "+0.30%" {
+		t.Fatalf("legacy premium = %q", got)
+	}
+
+	// 不支持该代码的实时数据源不会被尝试
+	q, err = fetchMergedQuote("110022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Status != models.PriceConfirmed || q.PremiumRate != nil {
+		t.Fatalf("got %s premium=%v, want confirmed without premium", q.Status, q.PremiumRate)
+	}
+}
+
+func TestSetProviderChainRejectsInvalid(t *testing.T) {
+	prev := ProviderChain()
+	for _, chain := range [][]string{nil, {"no_such_provider"}, {"fundgz", "fundgz"}} {
+		if err := SetProviderChain(chain...); err == nil {
+			t.Errorf("SetProviderChain(%v) should fail", chain)
+		}
+	}
+	if got := ProviderChain(); len(got) != len(prev) {
+		t.Fatalf("chain changed after failed set: %v", got)
+	}
+}
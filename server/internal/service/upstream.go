@@ -0,0 +1,56 @@
+package service
+
+import "sync"
+
+// Upstream 各上游接口的基础地址 (不含路径)，离线测试时可整体指向本地替身服务
+type Upstream struct {
+	Push2   string `json:"push2"`    // 行情: /api/qt/stock/get, /api/qt/ulist.np/get
+	FundGZ  string `json:"fundgz"`   // 估值 JSONP: /js/{code}.js
+	F10     string `json:"f10"`      // 历史净值: /f10/F10DataApi.aspx
+	Search  string `json:"search"`   // 搜索: /FundSearch/api/FundSearchAPI.ashx
+	FundMob string `json:"fund_mob"` // 持仓: /FundMNewApi/FundMNBasicInformation
+}
+
+// DefaultUpstream 线上 Eastmoney 地址
+func DefaultUpstream() Upstream {
+	return Upstream{
+		Push2:   "http://push2.eastmoney.com",
+		FundGZ:  "http://fundgz.1234567.com.cn",
+		F10:     "http://fund.eastmoney.com",
+		Search:  "http://fundsuggest.eastmoney.com",
+		FundMob: "https://fundmobapi.eastmoney.com",
+	}
+}
+
+var (
+	upstreamMu sync.RWMutex
+	upstream   = DefaultUpstream()
+)
+
+// SetUpstream 替换上游地址，空字段保留原值
+func SetUpstream(u Upstream) {
+	upstreamMu.Lock()
+	defer upstreamMu.Unlock()
+	if u.Push2 != "" {
+		upstream.Push2 = u.Push2
+	}
+	if u.FundGZ != "" {
+		upstream.FundGZ = u.FundGZ
+	}
+	if u.F10 != "" {
+		upstream.F10 = u.F10
+	}
+	if u.Search != "" {
+		upstream.Search = u.Search
+	}
+	if u.FundMob != "" {
+		upstream.FundMob = u.FundMob
+	}
+}
+
+// CurrentUpstream 返回当前生效的上游地址
+func CurrentUpstream() Upstream {
+	upstreamMu.RLock()
+	defer upstreamMu.RUnlock()
+	return upstream
+}
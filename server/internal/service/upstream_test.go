@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"fund-tracker-server/internal/fakeupstream"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/service"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// withFakeUpstream 把所有上游指向用 fixtures 启动的替身服务，测试结束后恢复
+func withFakeUpstream(t *testing.T, fixtures fs.FS) {
+	t.Helper()
+	srv := httptest.NewServer(fakeupstream.Handler(fixtures))
+	prev := service.CurrentUpstream()
+	service.SetUpstream(fakeupstream.UpstreamFor(srv.URL))
+	t.Cleanup(func() {
+		service.SetUpstream(prev)
+		srv.Close()
+	})
+}
+
+// extraFixtures 内置 fixtures 加上 extra 中的文件
+func extraFixtures(t *testing.T, extra fstest.MapFS) fs.FS {
+	t.Helper()
+	all := fstest.MapFS{}
+	err := fs.WalkDir(fakeupstream.Fixtures(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fakeupstream.Fixtures(), path)
+		all[path] = &fstest.MapFile{Data: data}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, f := range extra {
+		all[path] = f
+	}
+	return all
+}
+
+func TestFetchQuoteMergeFromFakeUpstream(t *testing.T) {
+	// 000001: 估值是 10-16 的，确认净值只到 10-15
+	withFakeUpstream(t, extraFixtures(t, fstest.MapFS{
+		"fundgz/000001.js": {Data: []byte(`jsonpgz({"fundcode":"000001","name":"华夏成长混合","jzrq":"2026-10-15","dwjz":"1.2010","gsz":"1.2130","gszzl":"1.00","gztime":"2026-10-16 14:30"});`)},
+		"f10/000001.json":  {Data: []byte(`[{"date":"2026-10-15","nav":"1.2010","acc_nav":"3.5010","rate":"0.25%"}]`)},
+	}))
+
+	tests := []struct {
+		code     string
+		status   models.PriceStatus
+		source   models.QuoteSource
+		price    string
+		rate     string
+		gztime   string
+		premium  string
+		wantName string
+	}{
+		{"510300", models.PriceRealtime, models.SourceEastmoneyMarket, "4.012", "-0.27", " (实时)", "+0.04%", "沪深300ETF"},
+		{"110022", models.PriceConfirmed, models.SourceEastmoneyF10, "3.6391", "-0.70", "2026-10-16 (确)", "", "易方达消费行业股票"},
+		{"000001", models.PriceEstimated, models.SourceFundGZ, "1.2130", "1.00", "2026-10-16 14:30 (估)", "", "华夏成长混合"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			q, err := service.FetchQuote(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.Status != tt.status || q.Source != tt.source || q.Price.String() != tt.price || q.ChangeRate.String() != tt.rate || q.Name != tt.wantName {
+				t.Fatalf("got status=%s source=%s price=%s rate=%s name=%s", q.Status, q.Source, q.Price, q.ChangeRate, q.Name)
+			}
+			info := q.FundInfo()
+			// 实时价的时间是抓取时刻，只比较后缀
+			if !strings.HasSuffix(info.GZTime, tt.gztime) {
+				t.Errorf("gztime = %q, want %q", info.GZTime, tt.gztime)
+			}
+			if info.PremiumRate != tt.premium {
+				t.Errorf("premium_rate = %q, want %q", info.PremiumRate, tt.premium)
+			}
+		})
+	}
+
+	if _, err := service.FetchQuote("999999"); err == nil {
+		t.Fatal("unknown code should fail")
+	}
+}
+
+func TestSearchFundFromFakeUpstream(t *testing.T) {
+	withFakeUpstream(t, fakeupstream.Fixtures())
+
+	list, err := service.SearchFund("白酒")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, r := range list {
+		got[r.Code] = r.Name
+	}
+	if len(list) != 2 || got["161725"] != "招商中证白酒指数(LOF)A" || got["012414"] != "招商中证白酒指数(LOF)C" {
+		t.Fatalf("search 白酒 = %+v", list)
+	}
+
+	list, err = service.SearchFund("不存在的基金")
+	if err != nil || len(list) != 0 {
+		t.Fatalf("search miss = %+v, %v", list, err)
+	}
+}
+
+func TestFetchFundDetailFromFakeUpstream(t *testing.T) {
+	withFakeUpstream(t, fakeupstream.Fixtures())
+
+	detail, err := service.FetchFundDetail("110022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.StockDetails) != 5 || detail.Stocks[0] != "贵州茅台" {
+		t.Fatalf("stocks = %+v", detail.Stocks)
+	}
+	first := detail.StockDetails[0]
+	if first.Code != "600519" || first.Price != "1485.50" || first.Change != "+0.82%" {
+		t.Fatalf("first stock = %+v", first)
+	}
+	if len(detail.Sectors) != 1 || detail.Sectors[0] != "关联持仓行业" {
+		t.Fatalf("sectors = %+v", detail.Sectors)
+	}
+
+	// 没有持仓数据的基金
+	detail, err = service.FetchFundDetail("999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.StockDetails) != 0 || detail.Sectors[0] != "暂无持仓数据" {
+		t.Fatalf("empty detail = %+v", detail)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fund-tracker-server/internal/models"
+	"strconv"
+	"sync"
+)
+
+// 净值状态，前三种即行情的 Quote.Status
+const (
+	NavConfirmed = string(models.PriceConfirmed) // 已公布的确认净值
+	NavEstimated = string(models.PriceEstimated) // 盘中估值
+	NavRealtime  = string(models.PriceRealtime)  // 场内实时成交价
+	NavCached    = "cached"                      // 未取到最新行情，使用数据库缓存的价格
+)
+
+// FetchConcurrency 批量抓取的最大并发，启动时由配置覆盖
+var FetchConcurrency = 5
+
+// FetchQuotes 并发获取多只基金行情，失败的代码不出现在结果中
+func FetchQuotes(codes []string) map[string]*models.Quote {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, FetchConcurrency)
+	var mu sync.Mutex
+	results := make(map[string]*models.Quote)
+
+	for _, code := range codes {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(targetCode string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			data, err := FetchQuote(targetCode)
+			if err == nil && data != nil {
+				mu.Lock()
+				results[targetCode] = data
+				mu.Unlock()
+			}
+		}(code)
+	}
+	wg.Wait()
+	return results
+}
+
+// ValueHolding 用最新行情计算持仓的市值和收益，q 为 nil 时使用 Holding 缓存的价格
+func ValueHolding(h *models.Holding, q *models.Quote) {
+	if q != nil {
+		if q.Name != "" {
+			h.FundName = q.Name
+		}
+		h.LastPrice = q.Price.String()
+		h.Change = q.ChangeRate.String()
+		h.NavStatus = string(q.Status)
+		h.QuoteTime = q.LegacyTime()
+	} else {
+		h.NavStatus = NavCached
+	}
+
+	price, _ := strconv.ParseFloat(h.LastPrice, 64)
+	rate, _ := strconv.ParseFloat(h.Change, 64)
+	if price <= 0 {
+		h.TotalValue, h.UnrealizedReturn, h.DayReturn = 0, 0, 0
+		h.TotalReturn = h.RealizedReturn
+		return
+	}
+
+	h.TotalValue = price * h.Shares
+	h.UnrealizedReturn = h.TotalValue - h.CostPrice*h.Shares
+	h.TotalReturn = h.UnrealizedReturn + h.RealizedReturn
+	// 当日收益 = 今日市值 - 昨日市值，昨日价格由涨跌幅反推
+	if rate > -100 {
+		h.DayReturn = h.TotalValue - h.TotalValue/(1+rate/100)
+	}
+}
+
+// QuoteWatch 把最新行情写入自选，q 为 nil 时标记为缓存 (只有名称)
+func QuoteWatch(w *models.Watchlist, q *models.Quote) {
+	if q == nil {
+		w.NavStatus = NavCached
+		return
+	}
+	if q.Name != "" {
+		w.FundName = q.Name
+	}
+	w.LastPrice = q.Price.String()
+	w.Change = q.ChangeRate.String()
+	w.NavStatus = string(q.Status)
+	w.QuoteTime = q.LegacyTime()
+}
+
+// PortfolioSummary 组合汇总
+type PortfolioSummary struct {
+	TotalValue       float64 `json:"total_value"`
+	TotalCost        float64 `json:"total_cost"`
+	TotalReturn      float64 `json:"total_return"`
+	TotalReturnRate  float64 `json:"total_return_rate"` // 百分比，未实现收益 / 持仓成本
+	DayReturn        float64 `json:"day_return"`
+	DayReturnRate    float64 `json:"day_return_rate"` // 百分比，当日收益 / 昨日市值
+	RealizedReturn   float64 `json:"realized_return"`
+	UnrealizedReturn float64 `json:"unrealized_return"`
+	HasEstimate      bool    `json:"has_estimate"` // 是否有持仓使用的是估值 (或缓存)，数字可能在晚间变化
+}
+
+// Summarize 汇总已经 ValueHolding 过的持仓
+func Summarize(holdings []models.Holding) PortfolioSummary {
+	var s PortfolioSummary
+	for _, h := range holdings {
+		s.TotalValue += h.TotalValue
+		s.TotalCost += h.CostPrice * h.Shares
+		s.TotalReturn += h.TotalReturn
+		s.DayReturn += h.DayReturn
+		s.RealizedReturn += h.RealizedReturn
+		s.UnrealizedReturn += h.UnrealizedReturn
+		if h.Shares > 0 && h.NavStatus != NavConfirmed && h.NavStatus != NavRealtime {
+			s.HasEstimate = true
+		}
+	}
+	if s.TotalCost > 0 {
+		s.TotalReturnRate = s.UnrealizedReturn / s.TotalCost * 100
+	}
+	if prev := s.TotalValue - s.DayReturn; prev > 0 {
+		s.DayReturnRate = s.DayReturn / prev * 100
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+package ws
+
+import (
+	"encoding/json"
+	"fund-tracker-server/internal/models"
+	"fund-tracker-server/internal/service"
+	"sync"
+	"time"
+)
+
+// 每只基金最近一次推送的行情，用于去重和新订阅时的首次推送
+var (
+	lastMu     sync.Mutex
+	lastQuotes = make(map[string]*models.Quote)
+)
+
+// changed 判断行情是否有变化 (只比较价格相关字段，行情时间每分钟都会变)，有变化时记录为最新
+func changed(q *models.Quote) bool {
+	lastMu.Lock()
+	defer lastMu.Unlock()
+	prev, ok := lastQuotes[q.FundCode]
+	if ok && prev.Price == q.Price && prev.ChangeRate == q.ChangeRate && prev.Status == q.Status &&
+		samePremium(prev.PremiumRate, q.PremiumRate) && prev.Name == q.Name {
+		return false
+	}
+	lastQuotes[q.FundCode] = q
+	return true
+}
+
+func samePremium(a, b *models.Decimal) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// encodedQuote 一条行情的两种编码，按连接选择的格式发送
+type encodedQuote struct {
+	legacy, typed []byte
+}
+
+func encodeQuote(q *models.Quote) (encodedQuote, error) {
+	legacy, err := json.Marshal(q.FundInfo())
+	if err != nil {
+		return encodedQuote{}, err
+	}
+	typed, err := json.Marshal(q)
+	if err != nil {
+		return encodedQuote{}, err
+	}
+	return encodedQuote{legacy: legacy, typed: typed}, nil
+}
+
+// deliverQuote 把行情按各连接的格式放入发送队列
+func (manager *WsManager) deliverQuote(targets []*Client, q *models.Quote) {
+	msg, err := encodeQuote(q)
+	if err != nil {
+		return
+	}
+	var legacy, typed []*Client
+	for _, client := range targets {
+		if client.typed {
+			typed = append(typed, client)
+		} else {
+			legacy = append(legacy, client)
+		}
+	}
+	if len(legacy) > 0 {
+		manager.deliver(legacy, msg.legacy)
+	}
+	if len(typed) > 0 {
+		manager.deliver(typed, msg.typed)
+	}
+}
+
+// publishQuote 发给订阅了该基金行情的连接
+func (manager *WsManager) publishQuote(q *models.Quote) {
+	topic := QuoteTopic(q.FundCode)
+	manager.lock.RLock()
+	var targets []*Client
+	for client := range manager.clients {
+		if client.topics[topic] {
+			targets = append(targets, client)
+		}
+	}
+	manager.lock.RUnlock()
+	manager.deliverQuote(targets, q)
+}
+
+// sendLatest 新订阅的代码立即推送一次，没有缓存的在后台抓取
+func sendLatest(client *Client, codes []string) {
+	var cached []*models.Quote
+	var missing []string
+	lastMu.Lock()
+	for _, code := range codes {
+		if q, ok := lastQuotes[code]; ok {
+			cached = append(cached, q)
+		} else {
+			missing = append(missing, code)
+		}
+	}
+	lastMu.Unlock()
+
+	for _, q := range cached {
+		Manager.deliverQuote([]*Client{client}, q)
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+	go func() {
+		for code, q := range service.FetchQuotes(missing) {
+			q.FundCode = code
+			changed(q)
+			Manager.deliverQuote([]*Client{client}, q)
+		}
+	}()
+}
+
+// StartQuotePoller 交易时段内每隔 interval 抓取所有被订阅的基金，只向订阅者推送有变化的行情
+func StartQuotePoller(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if !service.IsTradingHours(time.Now()) {
+			continue
+		}
+		codes := Manager.SubscribedCodes()
+		if len(codes) == 0 {
+			continue
+		}
+		for code, q := range service.FetchQuotes(codes) {
+			q.FundCode = code
+			if !changed(q) {
+				continue
+			}
+			Manager.publishQuote(q)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package ws
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,20 +13,49 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-// WsManager 管理所有 WebSocket 连接
+const (
+	sendBufferSize = 32               // 每个连接的待发送队列长度，写满视为慢连接
+	writeWait      = 10 * time.Second // 单次写超时
+	pongWait       = 60 * time.Second // 超过该时间没有收到 pong 视为断线
+	pingPeriod     = 30 * time.Second // 必须小于 pongWait
+	maxMessageSize = 4096             // 客户端消息只有订阅请求，不需要很大
+
+	maxTopicsPerClient = 50  // 单个连接最多订阅的主题数
+	maxTopicsPerUser   = 200 // 同一用户所有连接合计最多订阅的不同主题数
+)
+
+// topicPattern 客户端可以订阅的主题，目前只有基金行情
+var topicPattern = regexp.MustCompile(`^quote:[0-9]{6}$`)
+
+// QuoteTopic 基金行情主题
+func QuoteTopic(code string) string { return "quote:" + code }
+
+// Client 一个 WebSocket 连接，归属于一个用户并订阅若干主题
+type Client struct {
+	conn   *websocket.Conn
+	UserID uint
+	topics map[string]bool // 受 WsManager.lock 保护
+	send   chan []byte
+	closed bool // 受 WsManager.lock 保护
+	typed  bool // 连接时带 ?quote_format=typed 的客户端收到结构化的 Quote，其他收到旧版 FundInfo
+}
+
+// WsManager 管理所有 WebSocket 连接，按用户和主题路由消息。
+// 发送只是把消息放入各连接自己的队列，由每个连接的 writePump 写出，慢连接不会拖慢其他人
 type WsManager struct {
-	Clients    map[*websocket.Conn]bool
-	Register   chan *websocket.Conn
-	Unregister chan *websocket.Conn
-	Broadcast  chan []byte
-	Lock       sync.Mutex
+	lock    sync.RWMutex
+	clients map[*Client]bool
+	byUser  map[uint]map[*Client]bool
 }
 
-var Manager = WsManager{
-	Clients:    make(map[*websocket.Conn]bool),
-	Register:   make(chan *websocket.Conn),
-	Unregister: make(chan *websocket.Conn),
-	Broadcast:  make(chan []byte),
+var Manager = NewManager()
+
+// NewManager 创建空的连接管理器
+func NewManager() *WsManager {
+	return &WsManager{
+		clients: make(map[*Client]bool),
+		byUser:  make(map[uint]map[*Client]bool),
+	}
 }
 
 var upgrader = websocket.Upgrader{
@@ -32,60 +64,268 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func (manager *WsManager) Start() {
-	for {
+func (manager *WsManager) register(client *Client) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.clients[client] = true
+	if manager.byUser[client.UserID] == nil {
+		manager.byUser[client.UserID] = make(map[*Client]bool)
+	}
+	manager.byUser[client.UserID][client] = true
+	fmt.Println("新用户连接，当前在线:", len(manager.clients))
+}
+
+func (manager *WsManager) unregister(client *Client) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.drop(client)
+	fmt.Println("用户断开，当前在线:", len(manager.clients))
+}
+
+// drop 移除连接并关闭其发送队列 (writePump 随之退出并关闭连接)，调用方需持有写锁
+func (manager *WsManager) drop(client *Client) {
+	if client.closed {
+		return
+	}
+	client.closed = true
+	close(client.send)
+	delete(manager.clients, client)
+	if conns := manager.byUser[client.UserID]; conns != nil {
+		delete(conns, client)
+		if len(conns) == 0 {
+			delete(manager.byUser, client.UserID)
+		}
+	}
+}
+
+// deliver 非阻塞地放入发送队列，队列已满的慢连接被断开
+func (manager *WsManager) deliver(targets []*Client, message []byte) {
+	var slow []*Client
+	manager.lock.RLock()
+	for _, client := range targets {
+		if client.closed {
+			continue
+		}
 		select {
-		case conn := <-manager.Register:
-			manager.Lock.Lock()
-			manager.Clients[conn] = true
-			manager.Lock.Unlock()
-			fmt.Println("新用户连接，当前在线:", len(manager.Clients))
-
-		case conn := <-manager.Unregister:
-			manager.Lock.Lock()
-			if _, ok := manager.Clients[conn]; ok {
-				delete(manager.Clients, conn)
-				conn.Close()
-			}
-			manager.Lock.Unlock()
-			fmt.Println("用户断开，当前在线:", len(manager.Clients))
-
-		case message := <-manager.Broadcast:
-			manager.Lock.Lock()
-			for conn := range manager.Clients {
-				err := conn.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					conn.Close()
-					delete(manager.Clients, conn)
-				}
+		case client.send <- message:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	manager.lock.RUnlock()
+
+	if len(slow) > 0 {
+		manager.lock.Lock()
+		for _, client := range slow {
+			manager.drop(client)
+		}
+		manager.lock.Unlock()
+		fmt.Println("⚠️ 断开慢连接:", len(slow))
+	}
+}
+
+// Broadcast 发给所有连接
+func (manager *WsManager) Broadcast(message []byte) {
+	manager.lock.RLock()
+	targets := make([]*Client, 0, len(manager.clients))
+	for client := range manager.clients {
+		targets = append(targets, client)
+	}
+	manager.lock.RUnlock()
+	manager.deliver(targets, message)
+}
+
+// Publish 发给订阅了 topic 的连接
+func (manager *WsManager) Publish(topic string, message []byte) {
+	manager.lock.RLock()
+	var targets []*Client
+	for client := range manager.clients {
+		if client.topics[topic] {
+			targets = append(targets, client)
+		}
+	}
+	manager.lock.RUnlock()
+	manager.deliver(targets, message)
+}
+
+// SendToUser 发给某个用户的所有连接 (如持仓变动)
+func (manager *WsManager) SendToUser(userID uint, message []byte) {
+	manager.lock.RLock()
+	targets := make([]*Client, 0, len(manager.byUser[userID]))
+	for client := range manager.byUser[userID] {
+		targets = append(targets, client)
+	}
+	manager.lock.RUnlock()
+	manager.deliver(targets, message)
+}
+
+// SendJSON 序列化后发给某个用户
+func (manager *WsManager) SendJSON(userID uint, v interface{}) {
+	if data, err := json.Marshal(v); err == nil {
+		manager.SendToUser(userID, data)
+	}
+}
+
+// SubscribedCodes 当前被订阅行情的基金代码 (去重)
+func (manager *WsManager) SubscribedCodes() []string {
+	manager.lock.RLock()
+	defer manager.lock.RUnlock()
+	seen := make(map[string]bool)
+	var codes []string
+	for client := range manager.clients {
+		for topic := range client.topics {
+			code, ok := strings.CutPrefix(topic, "quote:")
+			if ok && !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
 			}
-			manager.Lock.Unlock()
 		}
 	}
+	return codes
+}
+
+// subscribe 订阅或退订主题，返回实际新订阅的主题和被拒绝的主题。
+// 不合法的主题以及超出连接或用户上限的部分被拒绝
+func (manager *WsManager) subscribe(client *Client, topics []string, on bool) (added, rejected []string) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	if !on {
+		for _, topic := range topics {
+			delete(client.topics, topic)
+		}
+		return nil, nil
+	}
+
+	// 用户其他连接已订阅的主题，同一主题在多个连接上只计一次
+	userTopics := make(map[string]bool)
+	for c := range manager.byUser[client.UserID] {
+		for topic := range c.topics {
+			userTopics[topic] = true
+		}
+	}
+	for _, topic := range topics {
+		if client.topics[topic] {
+			continue
+		}
+		if !topicPattern.MatchString(topic) || len(client.topics) >= maxTopicsPerClient ||
+			(!userTopics[topic] && len(userTopics) >= maxTopicsPerUser) {
+			rejected = append(rejected, topic)
+			continue
+		}
+		client.topics[topic] = true
+		userTopics[topic] = true
+		added = append(added, topic)
+	}
+	return added, rejected
 }
 
+// 客户端发来的订阅消息: {"action": "subscribe" | "unsubscribe", "codes": ["510300"], "topics": ["quote:510300"]}
+// codes 是 quote:{code} 主题的简写
+type clientMessage struct {
+	Action string   `json:"action"`
+	Codes  []string `json:"codes"`
+	Topics []string `json:"topics"`
+}
+
+// WsHandler 需挂在 AuthMiddleware 之后 (浏览器可用 ?token= 传递 Token)。
+// ?quote_format=typed 时行情按 models.Quote 推送，默认保持旧版 FundInfo 格式
 func WsHandler(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return
 	}
-	Manager.Register <- conn
+	client := &Client{
+		conn:   conn,
+		UserID: c.GetUint("user_id"),
+		topics: make(map[string]bool),
+		send:   make(chan []byte, sendBufferSize),
+		typed:  c.Query("quote_format") == "typed",
+	}
+	Manager.register(client)
+
+	go client.writePump()
+	go client.readPump()
+}
 
-	go func() {
-		defer func() {
-			Manager.Unregister <- conn
-		}()
-		for {
-			if _, _, err := conn.ReadMessage(); err != nil {
-				break
+// readPump 读取订阅请求，连接断开时注销
+func (client *Client) readPump() {
+	defer Manager.unregister(client)
+	client.conn.SetReadLimit(maxMessageSize)
+	client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg clientMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		topics := append([]string(nil), msg.Topics...)
+		for _, code := range msg.Codes {
+			topics = append(topics, QuoteTopic(code))
+		}
+		switch msg.Action {
+		case "subscribe":
+			added, rejected := Manager.subscribe(client, topics, true)
+			if len(rejected) > 0 {
+				client.reject(rejected)
 			}
+			var codes []string
+			for _, topic := range added {
+				if code, ok := strings.CutPrefix(topic, "quote:"); ok {
+					codes = append(codes, code)
+				}
+			}
+			sendLatest(client, codes)
+		case "unsubscribe":
+			Manager.subscribe(client, topics, false)
 		}
-	}()
+	}
 }
 
-func SendHeartbeat() {
+// reject 告知客户端哪些主题没有订阅成功
+func (client *Client) reject(topics []string) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":   "subscribe",
+		"event":  "rejected",
+		"topics": topics,
+		"error":  fmt.Sprintf("只能订阅 quote:{6 位基金代码}，每个连接最多 %d 个、每个用户最多 %d 个", maxTopicsPerClient, maxTopicsPerUser),
+	})
+	if err == nil {
+		Manager.deliver([]*Client{client}, data)
+	}
+}
+
+// writePump 该连接唯一的写者：写出队列中的消息并定时发送 ping
+func (client *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
 	for {
-		time.Sleep(30 * time.Second)
-		Manager.Broadcast <- []byte("ping")
+		select {
+		case message, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// 队列被关闭: 已注销或被判定为慢连接
+				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
 	}
 }
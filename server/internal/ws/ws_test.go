@@ -0,0 +1,200 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"fund-tracker-server/internal/models"
+	"testing"
+)
+
+func newTestClient(manager *WsManager, userID uint) *Client {
+	client := &Client{UserID: userID, topics: make(map[string]bool), send: make(chan []byte, sendBufferSize)}
+	manager.register(client)
+	return client
+}
+
+// drain 取出队列中已有的消息，队列已关闭时 open 为 false
+func drain(client *Client) (msgs []string, open bool) {
+	for {
+		select {
+		case msg, ok := <-client.send:
+			if !ok {
+				return msgs, false
+			}
+			msgs = append(msgs, string(msg))
+		default:
+			return msgs, true
+		}
+	}
+}
+
+func expectMessages(t *testing.T, name string, client *Client, want ...string) {
+	t.Helper()
+	got, open := drain(client)
+	if !open {
+		t.Fatalf("%s: queue closed", name)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("%s: got %v, want %v", name, got, want)
+	}
+}
+
+func TestSubscribeRejectsInvalidTopics(t *testing.T) {
+	manager := NewManager()
+	client := newTestClient(manager, 1)
+
+	added, rejected := manager.subscribe(client, []string{"quote:510300", "quote:51030", "quote:abcdef", "alert:1", "quote:510300x"}, true)
+	if len(added) != 1 || added[0] != "quote:510300" || len(rejected) != 4 {
+		t.Fatalf("added=%v rejected=%v", added, rejected)
+	}
+	// 重复订阅既不新增也不算拒绝
+	added, rejected = manager.subscribe(client, []string{"quote:510300"}, true)
+	if len(added) != 0 || len(rejected) != 0 {
+		t.Fatalf("resubscribe: added=%v rejected=%v", added, rejected)
+	}
+}
+
+func TestSubscribeCaps(t *testing.T) {
+	manager := NewManager()
+	topics := func(from, n int) []string {
+		var list []string
+		for i := from; i < from+n; i++ {
+			list = append(list, QuoteTopic(fmt.Sprintf("%06d", i)))
+		}
+		return list
+	}
+
+	first := newTestClient(manager, 1)
+	added, rejected := manager.subscribe(first, topics(0, maxTopicsPerClient+10), true)
+	if len(added) != maxTopicsPerClient || len(rejected) != 10 {
+		t.Fatalf("per client: added %d rejected %d", len(added), len(rejected))
+	}
+
+	// 用户的其他连接填满用户上限，已被订阅的主题不重复计数
+	for i := 1; i*maxTopicsPerClient < maxTopicsPerUser; i++ {
+		c := newTestClient(manager, 1)
+		added, _ = manager.subscribe(c, topics(i*maxTopicsPerClient, maxTopicsPerClient), true)
+		if len(added) != maxTopicsPerClient {
+			t.Fatalf("connection %d: added %d", i, len(added))
+		}
+	}
+	last := newTestClient(manager, 1)
+	added, rejected = manager.subscribe(last, append(topics(0, 1), topics(maxTopicsPerUser, 1)...), true)
+	if len(added) != 1 || added[0] != QuoteTopic("000000") || len(rejected) != 1 {
+		t.Fatalf("per user: added=%v rejected=%v", added, rejected)
+	}
+
+	// 其他用户不受影响
+	other := newTestClient(manager, 2)
+	if added, _ = manager.subscribe(other, topics(maxTopicsPerUser, 1), true); len(added) != 1 {
+		t.Fatalf("other user: added=%v", added)
+	}
+}
+
+func TestSendToUserReachesAllDevices(t *testing.T) {
+	manager := NewManager()
+	phone := newTestClient(manager, 1)
+	desktop := newTestClient(manager, 1)
+	other := newTestClient(manager, 2)
+
+	manager.SendToUser(1, []byte("holdings"))
+	manager.SendJSON(1, map[string]string{"type": "alert"})
+	expectMessages(t, "phone", phone, "holdings", `{"type":"alert"}`)
+	expectMessages(t, "desktop", desktop, "holdings", `{"type":"alert"}`)
+	expectMessages(t, "other user", other)
+
+	// 断开的设备不再收到，也不影响同一用户的其他设备
+	manager.unregister(phone)
+	manager.SendToUser(1, []byte("after"))
+	expectMessages(t, "desktop", desktop, "after")
+	manager.SendToUser(3, []byte("nobody"))
+}
+
+func TestTopicRouting(t *testing.T) {
+	manager := NewManager()
+	etf := newTestClient(manager, 1)
+	fund := newTestClient(manager, 1)
+	typed := newTestClient(manager, 2)
+	typed.typed = true
+	idle := newTestClient(manager, 3)
+	manager.subscribe(etf, []string{QuoteTopic("510300")}, true)
+	manager.subscribe(fund, []string{QuoteTopic("161725")}, true)
+	manager.subscribe(typed, []string{QuoteTopic("510300"), QuoteTopic("161725")}, true)
+
+	manager.Publish(QuoteTopic("510300"), []byte("etf"))
+	expectMessages(t, "etf subscriber", etf, "etf")
+	expectMessages(t, "typed subscriber", typed, "etf")
+	expectMessages(t, "other topic", fund)
+	expectMessages(t, "no topics", idle)
+
+	// 行情按连接选择的格式编码
+	q := &models.Quote{FundCode: "161725", Name: "白酒", Price: models.NewDecimal(1.2345, 4), Status: models.PriceEstimated}
+	manager.publishQuote(q)
+	expectMessages(t, "etf subscriber", etf)
+	expectMessages(t, "no topics", idle)
+	got, _ := drain(fund)
+	var legacy models.FundInfo
+	if len(got) != 1 || json.Unmarshal([]byte(got[0]), &legacy) != nil || legacy.FundCode != "161725" || legacy.GSZ != "1.2345" {
+		t.Fatalf("legacy quote: %v", got)
+	}
+	got, _ = drain(typed)
+	var quote models.Quote
+	if len(got) != 1 || json.Unmarshal([]byte(got[0]), &quote) != nil || quote.FundCode != "161725" || quote.Price != q.Price {
+		t.Fatalf("typed quote: %v", got)
+	}
+
+	// 退订后不再收到，广播不看主题
+	manager.subscribe(etf, []string{QuoteTopic("510300")}, false)
+	manager.Publish(QuoteTopic("510300"), []byte("etf"))
+	expectMessages(t, "unsubscribed", etf)
+	expectMessages(t, "typed subscriber", typed, "etf")
+	manager.Broadcast([]byte("all"))
+	for name, client := range map[string]*Client{"etf": etf, "fund": fund, "typed": typed, "idle": idle} {
+		expectMessages(t, name, client, "all")
+	}
+}
+
+func TestSlowConsumerIsDropped(t *testing.T) {
+	manager := NewManager()
+	slow := newTestClient(manager, 1)
+	fast := newTestClient(manager, 1)
+	manager.subscribe(slow, []string{QuoteTopic("510300")}, true)
+
+	// 队列写满前不丢消息
+	for i := 0; i < sendBufferSize; i++ {
+		manager.SendToUser(1, []byte(fmt.Sprint(i)))
+		drain(fast)
+	}
+	if len(slow.send) != sendBufferSize || slow.closed {
+		t.Fatalf("queued %d closed=%v", len(slow.send), slow.closed)
+	}
+
+	// 再多一条，慢连接被断开，同一用户的其他连接照常收到
+	manager.SendToUser(1, []byte("overflow"))
+	expectMessages(t, "fast", fast, "overflow")
+	if !slow.closed {
+		t.Fatal("slow consumer not dropped")
+	}
+	manager.lock.RLock()
+	_, registered := manager.clients[slow]
+	_, byUser := manager.byUser[1][slow]
+	manager.lock.RUnlock()
+	if registered || byUser {
+		t.Fatalf("dropped client still registered: clients=%v byUser=%v", registered, byUser)
+	}
+	if codes := manager.SubscribedCodes(); len(codes) != 0 {
+		t.Fatalf("dropped client still counted as subscriber: %v", codes)
+	}
+
+	// 队列中已有的消息仍可写出，之后队列关闭 (writePump 随之退出)
+	msgs, open := drain(slow)
+	if len(msgs) != sendBufferSize || open {
+		t.Fatalf("drained %d open=%v", len(msgs), open)
+	}
+
+	// 之后的发送跳过已断开的连接，重复断开也不会 panic
+	manager.SendToUser(1, []byte("later"))
+	manager.Broadcast([]byte("later"))
+	manager.unregister(slow)
+	expectMessages(t, "fast", fast, "later", "later")
+}